@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/containerd/console"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+const defaultDetachKeys = "ctrl-p,ctrl-q"
+
+var attachCommand = cli.Command{
+	Name:  "attach",
+	Usage: "attach to the stdio (or terminal) of a container",
+	ArgsUsage: `<container-id>
+
+Where "<container-id>" is the name for the instance of the container.`,
+	Description: `The attach command connects to the IO broker of a container created (or run)
+with --attachable, which holds the stdio (or terminal) of its init process.
+The recent output of the container is replayed first. Several clients can be
+attached at the same time.
+
+Typing the detach key sequence (` + defaultDetachKeys + ` by default) detaches from the
+container, leaving it running. runc attach also exits once the container
+process has closed its output (usually once it has exited).`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "detach-keys",
+			Value: defaultDetachKeys,
+			Usage: `key sequence to detach from the container: comma-separated characters or "ctrl-<char>"`,
+		},
+		cli.BoolFlag{
+			Name:  "no-stdin",
+			Usage: "do not attach stdin",
+		},
+		cli.BoolFlag{
+			Name:  "close-stdin",
+			Usage: "close the stdin of the container once ours is closed (if it has no terminal)",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 1, exactArgs); err != nil {
+			return err
+		}
+		detachKeys, err := parseDetachKeys(context.String("detach-keys"))
+		if err != nil {
+			return err
+		}
+		container, err := getContainer(context)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(context.GlobalString("root"), container.ID(), attachSocketName)
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.ECONNREFUSED) {
+				return fmt.Errorf("container %s is not attachable (not created with --attachable, or stopped)", container.ID())
+			}
+			return err
+		}
+		defer conn.Close()
+		return attach(conn, detachKeys, !context.Bool("no-stdin"), context.Bool("close-stdin"))
+	},
+}
+
+// attach relays the stdio of the process held by the IO broker conn until
+// either it closes it, or the detach keys are typed.
+func attach(conn net.Conn, detachKeys []byte, stdin, closeStdin bool) error {
+	r := bufio.NewReader(conn)
+	typ, mode, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if typ != frameMode || len(mode) != 1 {
+		return errors.New("unexpected frame from the io broker")
+	}
+
+	var wmu sync.Mutex
+	send := func(typ byte, payload []byte) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		return writeFrame(conn, typ, payload)
+	}
+	if mode[0] == 1 {
+		t := &tty{}
+		if err := t.initHostConsole(); err != nil {
+			return err
+		}
+		if err := t.hostConsole.SetRaw(); err != nil {
+			return fmt.Errorf("failed to set the terminal from the stdin: %w", err)
+		}
+		defer t.hostConsole.Reset() //nolint:errcheck
+		t.resizeTo = func(ws console.WinSize) error {
+			payload := make([]byte, 4)
+			binary.BigEndian.PutUint16(payload, ws.Height)
+			binary.BigEndian.PutUint16(payload[2:], ws.Width)
+			return send(frameResize, payload)
+		}
+		sigwinch := make(chan os.Signal, 1)
+		signal.Notify(sigwinch, unix.SIGWINCH)
+		defer signal.Stop(sigwinch)
+		go func() {
+			_ = t.resize()
+			for range sigwinch {
+				_ = t.resize()
+			}
+		}()
+	}
+
+	detached := make(chan struct{})
+	if stdin {
+		go func() {
+			err := copyInput(os.Stdin, detachKeys, func(b []byte) error {
+				return send(frameStdin, b)
+			})
+			if errors.Is(err, errDetached) {
+				close(detached)
+				return
+			}
+			if closeStdin {
+				_ = send(frameStdinClose, nil)
+			}
+		}()
+	}
+
+	output := make(chan error, 1)
+	go func() {
+		for {
+			typ, payload, err := readFrame(r)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				output <- err
+				return
+			}
+			switch typ {
+			case frameStdout:
+				_, _ = os.Stdout.Write(payload)
+			case frameStderr:
+				_, _ = os.Stderr.Write(payload)
+			}
+		}
+	}()
+	select {
+	case err := <-output:
+		return err
+	case <-detached:
+		return nil
+	}
+}
+
+var errDetached = errors.New("detached")
+
+// copyInput sends what is read from r, until EOF or the detachKeys sequence
+// is read (in which case errDetached is returned), which is not sent.
+func copyInput(r io.Reader, detachKeys []byte, send func([]byte) error) error {
+	buf := make([]byte, 32*1024)
+	// matched is the number of bytes of detachKeys held back so far, and
+	// border[i] the length of the longest proper prefix of detachKeys[:i+1]
+	// which is also a suffix of it, to fall back to on a mismatch (as in the
+	// Knuth-Morris-Pratt algorithm).
+	matched := 0
+	border := detachKeysBorders(detachKeys)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			var out []byte
+			for _, c := range buf[:n] {
+				if len(detachKeys) == 0 {
+					out = append(out, c)
+					continue
+				}
+				for matched > 0 && c != detachKeys[matched] {
+					// Not a detach sequence after all, at least not
+					// from where it seemed to start: send the bytes
+					// which can't be part of one anymore.
+					next := border[matched-1]
+					out = append(out, detachKeys[:matched-next]...)
+					matched = next
+				}
+				if c != detachKeys[matched] {
+					out = append(out, c)
+					continue
+				}
+				matched++
+				if matched == len(detachKeys) {
+					if len(out) > 0 {
+						_ = send(out)
+					}
+					return errDetached
+				}
+			}
+			if len(out) > 0 {
+				if err := send(out); err != nil {
+					return err
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// What looked like the start of a detach sequence is
+				// input after all.
+				if matched > 0 {
+					return send(detachKeys[:matched])
+				}
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// detachKeysBorders returns the failure function of the Knuth-Morris-Pratt
+// algorithm for keys, see copyInput.
+func detachKeysBorders(keys []byte) []int {
+	border := make([]int, len(keys))
+	k := 0
+	for i := 1; i < len(keys); i++ {
+		for k > 0 && keys[i] != keys[k] {
+			k = border[k-1]
+		}
+		if keys[i] == keys[k] {
+			k++
+		}
+		border[i] = k
+	}
+	return border
+}
+
+// parseDetachKeys parses a detach key sequence such as "ctrl-p,ctrl-q" into
+// the bytes typing it produces. An empty string disables detaching.
+func parseDetachKeys(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var keys []byte
+	for _, key := range strings.Split(s, ",") {
+		switch {
+		case len(key) == 1:
+			keys = append(keys, key[0])
+		case strings.HasPrefix(key, "ctrl-") && len(key) == len("ctrl-")+1:
+			c := key[len("ctrl-")]
+			switch {
+			case c >= 'a' && c <= 'z':
+				keys = append(keys, c-'a'+1)
+			case c >= '@' && c <= '_':
+				// ctrl-@ (NUL), ctrl-[ (ESC), and so on.
+				keys = append(keys, c-'@')
+			default:
+				return nil, fmt.Errorf("invalid detach key %q", key)
+			}
+		default:
+			return nil, fmt.Errorf("invalid detach key %q", key)
+		}
+	}
+	if bytes.IndexByte(keys, 0) >= 0 && len(keys) == 1 {
+		return nil, errors.New("the detach keys can't be just ctrl-@")
+	}
+	return keys, nil
+}
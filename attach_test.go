@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseDetachKeys(t *testing.T) {
+	for _, tc := range []struct {
+		keys     string
+		expected []byte
+		fail     bool
+	}{
+		{keys: "", expected: nil},
+		{keys: "ctrl-p,ctrl-q", expected: []byte{16, 17}},
+		{keys: "a,b,c", expected: []byte("abc")},
+		{keys: "ctrl-a,x", expected: []byte{1, 'x'}},
+		{keys: "ctrl-[", expected: []byte{27}},
+		{keys: "ctrl-@,a", expected: []byte{0, 'a'}},
+		{keys: "ctrl-@", fail: true},
+		{keys: "ctrl-", fail: true},
+		{keys: "ctrl-pq", fail: true},
+		{keys: "ctrl-~", fail: true},
+		{keys: "ab", fail: true},
+		{keys: "a,,b", fail: true},
+	} {
+		keys, err := parseDetachKeys(tc.keys)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", tc.keys, keys)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.keys, err)
+			continue
+		}
+		if !bytes.Equal(keys, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.keys, tc.expected, keys)
+		}
+	}
+}
+
+func TestCopyInput(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		keys     string
+		input    string
+		expected string
+		detached bool
+	}{
+		{name: "no detach keys", keys: "", input: "hello\x10\x11", expected: "hello\x10\x11"},
+		{name: "plain input", keys: "\x10\x11", input: "hello", expected: "hello"},
+		{name: "detach", keys: "\x10\x11", input: "ab\x10\x11cd", expected: "ab", detached: true},
+		{name: "repeated first key", keys: "\x10\x11", input: "\x10\x10\x11", expected: "\x10", detached: true},
+		{name: "broken sequence", keys: "\x10\x11", input: "\x10a\x11", expected: "\x10a\x11"},
+		{name: "held back at eof", keys: "\x10\x11", input: "a\x10", expected: "a\x10"},
+		{name: "overlapping sequence", keys: "aab", input: "xaaab", expected: "xa", detached: true},
+		{name: "border fallback", keys: "abac", input: "ababac", expected: "ab", detached: true},
+		{name: "no match", keys: "abac", input: "ababab", expected: "ababab"},
+	} {
+		for _, oneByte := range []bool{false, true} {
+			r := iotest.OneByteReader(strings.NewReader(tc.input))
+			if !oneByte {
+				r = strings.NewReader(tc.input)
+			}
+			var out bytes.Buffer
+			err := copyInput(r, []byte(tc.keys), func(b []byte) error {
+				out.Write(b)
+				return nil
+			})
+			if detached := errors.Is(err, errDetached); detached != tc.detached || (err != nil && !detached) {
+				t.Errorf("%s (one byte reads: %v): expected detached %v, got error %v", tc.name, oneByte, tc.detached, err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("%s (one byte reads: %v): expected %q to be sent, got %q", tc.name, oneByte, tc.expected, out.String())
+			}
+		}
+	}
+}
@@ -1,16 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 
-	criu "github.com/checkpoint-restore/go-criu/v5/rpc"
+	criu "github.com/checkpoint-restore/go-criu/v7/rpc"
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runc/libcontainer/userns"
+	"github.com/opencontainers/runc/types"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -41,14 +44,18 @@ checkpointed.`,
 		cli.StringFlag{Name: "manage-cgroups-mode", Value: "", Usage: "cgroups mode: 'soft' (default), 'full' and 'strict'"},
 		cli.StringSliceFlag{Name: "empty-ns", Usage: "create a namespace, but don't restore its properties"},
 		cli.BoolFlag{Name: "auto-dedup", Usage: "enable auto deduplication of memory images"},
+		cli.BoolFlag{Name: "iterative", Usage: "pre-dump the container until its memory changes converge, then dump it, printing the progress as JSON events"},
+		cli.IntFlag{Name: "max-iterations", Value: 8, Usage: "maximum number of pre-dumps with --iterative"},
+		cli.Uint64Flag{Name: "convergence-pages", Value: 64, Usage: "with --iterative, stop pre-dumping once a pre-dump writes no more than this number of pages"},
+		cli.StringFlag{Name: "export", Value: "", Usage: "write the checkpoint, with the container's spec, state and rootfs changes, to a tar archive"},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
-		// XXX: Currently this is untested with rootless containers.
+		// This needs CRIU 3.17+ with CAP_CHECKPOINT_RESTORE, see runc-checkpoint(8).
 		if os.Geteuid() != 0 || userns.RunningInUserNS() {
-			logrus.Warn("runc checkpoint is untested with rootless containers")
+			logrus.Debug("running CRIU in its unprivileged mode")
 		}
 
 		container, err := getContainer(context)
@@ -62,7 +69,33 @@ checkpointed.`,
 		if status == libcontainer.Created || status == libcontainer.Stopped {
 			fatal(fmt.Errorf("Container cannot be checkpointed in %s state", status.String()))
 		}
+		export := context.String("export")
+		if export != "" {
+			if context.Bool("pre-dump") || context.Bool("lazy-pages") || context.String("page-server") != "" {
+				return errors.New("--export can't be used with --pre-dump, --lazy-pages or --page-server")
+			}
+			// Without --image-path, the images are only written to the archive.
+			if context.String("image-path") == "" {
+				dir, err := ioutil.TempDir("", "runc-checkpoint-")
+				if err != nil {
+					return err
+				}
+				defer os.RemoveAll(dir)
+				if err := context.Set("image-path", dir); err != nil {
+					return err
+				}
+			}
+		}
+		state, err := container.State()
+		if err != nil {
+			return err
+		}
 		options := criuOptions(context)
+		if context.Bool("iterative") {
+			if err := setIterative(context, container.ID(), options); err != nil {
+				return err
+			}
+		}
 		if !(options.LeaveRunning || options.PreDump) {
 			// destroy container unless we tell CRIU to keep it
 			defer destroy(container)
@@ -73,7 +106,13 @@ checkpointed.`,
 		if err := setEmptyNsMask(context, options); err != nil {
 			return err
 		}
-		return container.Checkpoint(options)
+		if err := container.Checkpoint(options); err != nil {
+			return err
+		}
+		if export != "" {
+			return exportCheckpoint(export, state, options.ImagesDirectory, context.GlobalString("criu"))
+		}
+		return nil
 	},
 }
 
@@ -144,6 +183,41 @@ func setManageCgroupsMode(context *cli.Context, options *libcontainer.CriuOpts)
 	}
 }
 
+// setIterative sets up the options of an iterative checkpoint, printing the
+// statistics of each pre-dump (and of the final dump) as JSON events.
+func setIterative(context *cli.Context, id string, options *libcontainer.CriuOpts) error {
+	if options.PreDump || options.LazyPages || context.String("page-server") != "" {
+		return errors.New("--iterative can't be used with --pre-dump, --lazy-pages or --page-server")
+	}
+	options.PreDumpIterations = context.Int("max-iterations")
+	if options.PreDumpIterations < 1 {
+		return errors.New("--max-iterations must be at least 1")
+	}
+	options.PreDumpConvergence = context.Uint64("convergence-pages")
+	enc := json.NewEncoder(os.Stdout)
+	options.PreDumpProgress = func(s libcontainer.CriuDumpStats) {
+		e := &types.Event{Type: "pre-dump", ID: id}
+		if s.Iteration == 0 {
+			e.Type = "dump"
+		}
+		e.Data = types.DumpStats{
+			Iteration:          s.Iteration,
+			PagesScanned:       s.PagesScanned,
+			PagesSkippedParent: s.PagesSkippedParent,
+			PagesWritten:       s.PagesWritten,
+			FreezingTime:       s.FreezingTime,
+			FrozenTime:         s.FrozenTime,
+			MemdumpTime:        s.MemdumpTime,
+			MemwriteTime:       s.MemwriteTime,
+			Converged:          s.Iteration != 0 && s.PagesWritten <= options.PreDumpConvergence,
+		}
+		if err := enc.Encode(e); err != nil {
+			logrus.Error(err)
+		}
+	}
+	return nil
+}
+
 var namespaceMapping = map[specs.LinuxNamespaceType]int{
 	specs.NetworkNamespace: unix.CLONE_NEWNET,
 }
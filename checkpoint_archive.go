@@ -0,0 +1,641 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/checkpoint-restore/go-criu/v7"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+// checkpointArchiveVersion is the version of the layout of the archives
+// written by runc checkpoint --export.
+const checkpointArchiveVersion = 1
+
+// The entries of a checkpoint archive. The manifest is the last one, as it
+// holds the digests of all the others.
+const (
+	archiveManifest  = "manifest.json"
+	archiveSpec      = "config.json"
+	archiveState     = "state.json"
+	archiveImagesDir = "checkpoint"
+	archiveRootfsDir = "rootfs-diff"
+)
+
+// checkpointImportPrefix is the prefix of the names of the directories, in
+// the root directory, which checkpoint archives are extracted to. It is not
+// a valid container ID.
+const checkpointImportPrefix = "checkpoint-import@"
+
+// checkpointManifest describes a checkpoint archive, and the host it was
+// made on.
+type checkpointManifest struct {
+	Version     int       `json:"version"`
+	ContainerID string    `json:"containerId"`
+	Created     time.Time `json:"created"`
+	RuncVersion string    `json:"runcVersion"`
+	CriuVersion int       `json:"criuVersion"`
+	Kernel      string    `json:"kernel"`
+	Arch        string    `json:"arch"`
+	// Files are the SHA-256 digests of the regular files of the archive.
+	Files map[string]string `json:"files"`
+	// RootfsEntries are the names of the entries of the directories of the
+	// rootfs changed since the container was created, by their path in the
+	// rootfs ("." for its root). They are the whiteouts of the rootfs
+	// changes: the other entries of these directories have been removed.
+	RootfsEntries map[string][]string `json:"rootfsEntries,omitempty"`
+}
+
+// exportCheckpoint writes an archive of the checkpoint of a container to
+// path, with the CRIU images from imagesDir, the container's state (as it
+// was before the checkpoint), its OCI spec and the files of its rootfs
+// changed since it was created.
+func exportCheckpoint(path string, state *libcontainer.State, imagesDir, criuPath string) (retErr error) {
+	bundle := utils.SearchLabels(state.Config.Labels, "bundle")
+	spec, err := ioutil.ReadFile(filepath.Join(bundle, specConfig))
+	if err != nil {
+		return err
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	manifest := checkpointManifest{
+		Version:     checkpointArchiveVersion,
+		ContainerID: state.ID,
+		Created:     time.Now().UTC(),
+		RuncVersion: version,
+		Kernel:      kernelRelease(),
+		Arch:        runtime.GOARCH,
+	}
+	if manifest.CriuVersion, err = criuVersion(criuPath); err != nil {
+		return fmt.Errorf("unable to get the CRIU version: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+		if retErr != nil {
+			_ = os.Remove(path)
+		}
+	}()
+	a := &archiveWriter{
+		tw:            tar.NewWriter(f),
+		files:         make(map[string]string),
+		rootfsEntries: make(map[string][]string),
+	}
+	if err := a.addBytes(archiveSpec, spec); err != nil {
+		return err
+	}
+	if err := a.addBytes(archiveState, stateJSON); err != nil {
+		return err
+	}
+	if err := a.addDir(archiveImagesDir, imagesDir, imagesDir); err != nil {
+		return fmt.Errorf("unable to archive the CRIU images: %w", err)
+	}
+	// A read-only rootfs can't have been changed.
+	if !state.Config.Readonlyfs {
+		if err := a.addRootfsChanges(archiveRootfsDir, state.Config.Rootfs, state.Created); err != nil {
+			return fmt.Errorf("unable to archive the rootfs changes: %w", err)
+		}
+	}
+	manifest.Files = a.files
+	manifest.RootfsEntries = a.rootfsEntries
+	manifestJSON, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := a.addBytes(archiveManifest, manifestJSON); err != nil {
+		return err
+	}
+	return a.tw.Close()
+}
+
+type archiveWriter struct {
+	tw            *tar.Writer
+	files         map[string]string
+	rootfsEntries map[string][]string
+}
+
+func (a *archiveWriter) addBytes(name string, data []byte) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o600,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := a.tw.Write(data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	a.files[name] = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// add adds the file at path (a directory, regular file or symlink) to the
+// archive as name.
+func (a *archiveWriter) add(name, path string, fi os.FileInfo) error {
+	link := ""
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uname, hdr.Gname = "", ""
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(a.tw, h), f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	a.files[name] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// addDir adds the directory dir as name, recursively. The symlinks to
+// directories out of root, such as the "parent" link to the images of a
+// pre-dump made with --parent-path, are replaced by the directories they
+// point to, while the ones in root (as made by --iterative) are kept.
+func (a *archiveWriter) addDir(name, dir, root string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if err := a.add(name, dir, fi); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		n := name + "/" + e.Name()
+		if e.Mode()&os.ModeSymlink != 0 {
+			if fi, err := os.Stat(p); err == nil && fi.IsDir() && !linkedInside(p, root) {
+				e = fi
+			}
+		}
+		if e.IsDir() {
+			err = a.addDir(n, p, root)
+		} else {
+			err = a.add(n, p, e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkedInside returns whether the symlink link is relative, and points
+// inside of root.
+func linkedInside(link, root string) bool {
+	target, err := os.Readlink(link)
+	if err != nil || filepath.IsAbs(target) {
+		return false
+	}
+	rel, err := filepath.Rel(root, filepath.Join(filepath.Dir(link), target))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// addRootfsChanges adds the directories, regular files and symlinks of rootfs
+// changed since the given time, as found by their ctime, under name. The
+// other filesystems mounted in rootfs are skipped. As removing a file changes
+// the directory it was in, the entries of the changed directories are listed
+// in a.rootfsEntries, for the removed files to be found on import.
+func (a *archiveWriter) addRootfsChanges(name, rootfs string, since time.Time) error {
+	root, err := os.Stat(rootfs)
+	if err != nil {
+		return err
+	}
+	dev := root.Sys().(*syscall.Stat_t).Dev
+	if err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0o755,
+		ModTime:  time.Now(),
+	}); err != nil {
+		return err
+	}
+	return filepath.Walk(rootfs, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		if st.Dev != dev {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if time.Unix(st.Ctim.Unix()).Before(since) {
+			return nil
+		}
+		if !fi.IsDir() && !fi.Mode().IsRegular() && fi.Mode()&os.ModeSymlink == 0 {
+			logrus.Debugf("not archiving %s: unsupported file type %s", path, fi.Mode()&os.ModeType)
+			return nil
+		}
+		rel, err := filepath.Rel(rootfs, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			names, err := f.Readdirnames(-1)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			sort.Strings(names)
+			a.rootfsEntries[rel] = names
+		}
+		if path == rootfs {
+			return nil
+		}
+		return a.add(name+"/"+rel, path, fi)
+	})
+}
+
+// checkpointImport is a checkpoint archive extracted by importCheckpoint.
+type checkpointImport struct {
+	// dir is the directory the archive is extracted to.
+	dir      string
+	manifest checkpointManifest
+	spec     *specs.Spec
+	state    libcontainer.State
+	// rootfsDiff are the entries of the changes of the rootfs, in the order
+	// of the archive, and staged the paths they are extracted to.
+	rootfsDiff []*tar.Header
+	staged     []string
+}
+
+// importCheckpoint extracts the checkpoint archive file to a new directory
+// in root, the root directory of runc, which the caller has to remove, and
+// validates it.
+func importCheckpoint(file, criuPath, root string) (_ *checkpointImport, retErr error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(root, checkpointImportPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+	imp := &checkpointImport{dir: dir}
+	files := make(map[string]string)
+	var manifest []byte
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+		}
+		name := path.Clean(hdr.Name)
+		if name == "." {
+			continue
+		}
+		top := strings.SplitN(name, "/", 2)[0]
+		if name == archiveManifest {
+			if manifest, err = ioutil.ReadAll(io.LimitReader(tr, 1<<20)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		switch top {
+		case archiveSpec, archiveState, archiveImagesDir, archiveRootfsDir:
+		default:
+			return nil, fmt.Errorf("invalid checkpoint archive %s: unexpected entry %q", file, hdr.Name)
+		}
+		target, err := securejoin.SecureJoin(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0o700)
+		case tar.TypeReg:
+			files[name], err = extractFile(target, tr)
+		case tar.TypeSymlink:
+			// The images can only link to one another (such as the
+			// "parent" links of pre-dumps).
+			if top != archiveRootfsDir && (path.IsAbs(hdr.Linkname) ||
+				!strings.HasPrefix(path.Join(path.Dir(name), hdr.Linkname), archiveImagesDir+"/")) {
+				return nil, fmt.Errorf("invalid checkpoint archive %s: unexpected symlink %q", file, hdr.Name)
+			}
+			if err = os.MkdirAll(filepath.Dir(target), 0o700); err == nil {
+				err = os.Symlink(hdr.Linkname, target)
+			}
+		default:
+			return nil, fmt.Errorf("invalid checkpoint archive %s: unsupported type of %q", file, hdr.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if top == archiveRootfsDir && name != archiveRootfsDir {
+			hdr.Name = strings.TrimPrefix(name, archiveRootfsDir+"/")
+			imp.rootfsDiff = append(imp.rootfsDiff, hdr)
+			imp.staged = append(imp.staged, target)
+		}
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: no %s", file, archiveManifest)
+	}
+	if err := json.Unmarshal(manifest, &imp.manifest); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+	}
+	if err := imp.manifest.validate(files, criuPath); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+	}
+	stateJSON, err := ioutil.ReadFile(filepath.Join(dir, archiveState))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stateJSON, &imp.state); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+	}
+	if imp.spec, err = loadSpec(filepath.Join(dir, archiveSpec)); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+	}
+	if err := imp.validateState(); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint archive %s: %w", file, err)
+	}
+	return imp, nil
+}
+
+// validateState checks that the state of the archive is the one of the
+// container of its manifest and spec.
+func (imp *checkpointImport) validateState() error {
+	if imp.state.ID != imp.manifest.ContainerID {
+		return fmt.Errorf("%s is the state of container %q, not %q", archiveState, imp.state.ID, imp.manifest.ContainerID)
+	}
+	if imp.spec.Root != nil && imp.spec.Root.Readonly != imp.state.Config.Readonlyfs {
+		return fmt.Errorf("%s does not match %s: the rootfs is read-only in only one of them", archiveState, archiveSpec)
+	}
+	if imp.state.Config.Readonlyfs && (len(imp.rootfsDiff) > 0 || len(imp.manifest.RootfsEntries) > 0) {
+		return errors.New("changes of a read-only rootfs")
+	}
+	return nil
+}
+
+// extractFile writes the contents of r to the new file path, and returns
+// their SHA-256 digest.
+func extractFile(path string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), f.Close()
+}
+
+// validate checks that the files of the archive are the ones of the
+// manifest, and that the checkpoint can be restored on this host.
+func (m *checkpointManifest) validate(files map[string]string, criuPath string) error {
+	if m.Version != checkpointArchiveVersion {
+		return fmt.Errorf("unsupported version %d", m.Version)
+	}
+	for name, sum := range m.Files {
+		if files[name] != sum {
+			return fmt.Errorf("%s is missing or corrupted", name)
+		}
+	}
+	for name := range files {
+		if _, ok := m.Files[name]; !ok {
+			return fmt.Errorf("%s is not in the manifest", name)
+		}
+	}
+	for _, name := range []string{archiveSpec, archiveState, archiveImagesDir + "/inventory.img"} {
+		if _, ok := files[name]; !ok {
+			return fmt.Errorf("no %s", name)
+		}
+	}
+	if m.Arch != runtime.GOARCH {
+		return fmt.Errorf("the checkpoint was made on %s, not %s", m.Arch, runtime.GOARCH)
+	}
+	version, err := criuVersion(criuPath)
+	if err != nil {
+		return fmt.Errorf("unable to get the CRIU version: %w", err)
+	}
+	if version < m.CriuVersion {
+		return fmt.Errorf("the checkpoint was made with CRIU %d, newer than CRIU %d", m.CriuVersion, version)
+	}
+	if kernel := kernelRelease(); kernel != m.Kernel {
+		logrus.Warnf("the checkpoint was made on kernel %s, restoring it on kernel %s", m.Kernel, kernel)
+	}
+	return nil
+}
+
+// setupSpec changes to the bundle directory, as setupSpec does, and applies
+// the changes of the rootfs of the archive to the rootfs of the imported
+// spec (or to the one set with --rootfs), unless it is the rootfs of the
+// checkpointed container, which has them already.
+func (imp *checkpointImport) setupSpec(context *cli.Context) (*specs.Spec, error) {
+	if bundle := context.String("bundle"); bundle != "" {
+		if err := os.Chdir(bundle); err != nil {
+			return nil, err
+		}
+	}
+	setRestoreRootfs(context, imp.spec)
+	if imp.spec.Root == nil {
+		return nil, errors.New("root must be specified")
+	}
+	rootfs, err := filepath.Abs(imp.spec.Root.Path)
+	if err != nil {
+		return nil, err
+	}
+	if rootfs == imp.state.Config.Rootfs {
+		logrus.Debugf("restoring into the rootfs of the checkpointed container %s, not applying its changes", rootfs)
+		return imp.spec, nil
+	}
+	for i, hdr := range imp.rootfsDiff {
+		if err := applyRootfsChange(rootfs, hdr, imp.staged[i]); err != nil {
+			return nil, fmt.Errorf("unable to apply the rootfs changes of the checkpoint: %w", err)
+		}
+	}
+	for dir, names := range imp.manifest.RootfsEntries {
+		if err := removeRootfsEntries(rootfs, dir, names); err != nil {
+			return nil, fmt.Errorf("unable to apply the rootfs changes of the checkpoint: %w", err)
+		}
+	}
+	return imp.spec, nil
+}
+
+// removeRootfsEntries removes the entries of the directory dir of rootfs which
+// are not in names, as they were removed from the checkpointed container's.
+func removeRootfsEntries(rootfs, dir string, names []string) error {
+	keep := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid entry %q of %s", name, dir)
+		}
+		keep[name] = struct{}{}
+	}
+	target, err := securejoin.SecureJoin(rootfs, dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	entries, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	for _, name := range entries {
+		if _, ok := keep[name]; ok {
+			continue
+		}
+		logrus.Debugf("removing %s, removed from the checkpointed container", filepath.Join(target, name))
+		if err := os.RemoveAll(filepath.Join(target, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRootfsChange creates (or replaces) the file of hdr in rootfs, with the
+// contents of staged, if it is a regular file.
+func applyRootfsChange(rootfs string, hdr *tar.Header, staged string) error {
+	target, err := securejoin.SecureJoin(rootfs, hdr.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeDir {
+		if fi, err := os.Lstat(target); err == nil && fi.IsDir() {
+			return fmt.Errorf("%s is a directory", target)
+		}
+		if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		err = os.MkdirAll(target, 0o755)
+	case tar.TypeReg:
+		err = copyFile(target, staged)
+	case tar.TypeSymlink:
+		err = os.Symlink(hdr.Linkname, target)
+	}
+	if err != nil {
+		return err
+	}
+	if err := unix.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+		return &os.PathError{Op: "lchown", Path: target, Err: err}
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+	// The permissions are set after the owner, which clears the
+	// set-user-ID and set-group-ID bits.
+	if err := os.Chmod(target, hdr.FileInfo().Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func criuVersion(criuPath string) (int, error) {
+	c := criu.MakeCriu()
+	c.SetCriuPath(criuPath)
+	return c.GetCriuVersion()
+}
+
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return ""
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
@@ -22,7 +22,7 @@ The specification file includes an args parameter. The args parameter is used
 to specify command(s) that get run when the container is started. To change the
 command(s) that get executed on start, edit the args parameter of the spec. See
 "runc spec --help" for more explanation.`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "bundle, b",
 			Value: "",
@@ -33,6 +33,14 @@ command(s) that get executed on start, edit the args parameter of the spec. See
 			Value: "",
 			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal",
 		},
+		cli.StringFlag{
+			Name:  "pidfd-socket",
+			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the container's init process (a pidfd)",
+		},
+		cli.BoolFlag{
+			Name:  "attachable",
+			Usage: "start an IO broker holding the container's stdio (or terminal), for runc attach to connect to",
+		},
 		cli.StringFlag{
 			Name:  "pid-file",
 			Value: "",
@@ -50,11 +58,14 @@ command(s) that get executed on start, edit the args parameter of the spec. See
 			Name:  "preserve-fds",
 			Usage: "Pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total)",
 		},
-	},
+	}, stdioLogFlags...),
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
+		if err := runDetached(); err != nil {
+			return err
+		}
 		if err := revisePidFile(context); err != nil {
 			return err
 		}
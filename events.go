@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fs2"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/libcontainer/openmetrics"
 	"github.com/opencontainers/runc/types"
 
 	"github.com/sirupsen/logrus"
@@ -24,12 +28,39 @@ var eventsCommand = cli.Command{
 
 Where "<container-id>" is the name for the instance of the container.`,
 	Description: `The events command displays information about the container. By default the
-information is displayed once every 5 seconds.`,
+information is displayed once every 5 seconds.
+
+With --listen, the events of any of the containers are instead served over a
+unix socket, to the clients subscribed to them.`,
 	Flags: []cli.Flag{
 		cli.DurationFlag{Name: "interval", Value: 5 * time.Second, Usage: "set the stats collection interval"},
 		cli.BoolFlag{Name: "stats", Usage: "display the container's stats then exit"},
+		cli.StringFlag{Name: "listen", Usage: "serve the containers' events over the unix socket at this path"},
+		cli.StringFlag{Name: "format", Value: "json", Usage: "select one of: " + eventsFormatOptions + " (openmetrics only displays stats)"},
 	},
 	Action: func(context *cli.Context) error {
+		duration := context.Duration("interval")
+		if duration <= 0 {
+			return errors.New("duration interval must be greater than 0")
+		}
+		format := context.String("format")
+		switch format {
+		case "json", "openmetrics":
+		default:
+			return fmt.Errorf("invalid format option %q, must be one of: %s", format, eventsFormatOptions)
+		}
+		if path := context.String("listen"); path != "" {
+			if err := checkArgs(context, 0, exactArgs); err != nil {
+				return err
+			}
+			if context.Bool("stats") {
+				return errors.New("--stats can't be used with --listen")
+			}
+			if format != "json" {
+				return errors.New("--listen only supports the json format")
+			}
+			return listenEvents(context, path, duration)
+		}
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
@@ -37,10 +68,6 @@ information is displayed once every 5 seconds.`,
 		if err != nil {
 			return err
 		}
-		duration := context.Duration("interval")
-		if duration <= 0 {
-			return errors.New("duration interval must be greater than 0")
-		}
 		status, err := container.Status()
 		if err != nil {
 			return err
@@ -48,8 +75,10 @@ information is displayed once every 5 seconds.`,
 		if status == libcontainer.Stopped {
 			return fmt.Errorf("container with id %s is not running", container.ID())
 		}
+		if format == "openmetrics" {
+			return openMetricsEvents(container, duration, context.Bool("stats"))
+		}
 		var (
-			stats  = make(chan *libcontainer.Stats, 1)
 			events = make(chan *types.Event, 1024)
 			group  = &sync.WaitGroup{}
 		)
@@ -73,42 +102,150 @@ information is displayed once every 5 seconds.`,
 			group.Wait()
 			return nil
 		}
-		go func() {
-			for range time.Tick(context.Duration("interval")) {
-				s, err := container.Stats()
-				if err != nil {
-					logrus.Error(err)
-					continue
-				}
-				stats <- s
-			}
-		}()
-		n, err := container.NotifyOOM()
+		err = streamEvents(container, duration, events, nil)
+		close(events)
+		group.Wait()
+		return err
+	},
+}
+
+const eventsFormatOptions = `json or openmetrics`
+
+// openMetricsEvents displays the container's stats in the OpenMetrics text
+// format, either once, or every interval until the container is stopped.
+func openMetricsEvents(container libcontainer.Container, interval time.Duration, once bool) error {
+	labels := openmetrics.ContainerLabels(container.ID(), cgroupPathLabel(container))
+	write := func() error {
+		s, err := container.Stats()
 		if err != nil {
 			return err
 		}
+		m := openmetrics.New()
+		m.AddCgroupStats(s.CgroupStats, labels...)
+		m.AddIntelRdtStats(s.IntelRdtStats, labels...)
+		m.AddNetworkInterfaces(s.Interfaces, labels...)
+		_, err = m.WriteTo(os.Stdout)
+		return err
+	}
+	if once {
+		return write()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		status, err := container.Status()
+		if err != nil || status == libcontainer.Stopped {
+			return nil
+		}
+		if err := write(); err != nil {
+			logrus.Error(err)
+		}
+	}
+	return nil
+}
+
+// cgroupPathLabel returns the container's cgroup path, relative to the cgroup
+// mountpoint, for the metrics to be labeled with.
+func cgroupPathLabel(container libcontainer.Container) string {
+	state, err := container.State()
+	if err != nil {
+		return ""
+	}
+	if cgroups.IsCgroup2UnifiedMode() {
+		return strings.TrimPrefix(state.CgroupPaths[""], fs2.UnifiedMountpoint)
+	}
+	for _, subsystem := range []string{"memory", "cpu", "pids"} {
+		path, ok := state.CgroupPaths[subsystem]
+		if !ok {
+			continue
+		}
+		mnt, err := cgroups.FindCgroupMountpoint("", subsystem)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(mnt, path); err == nil {
+			return "/" + rel
+		}
+	}
+	return ""
+}
+
+// streamEvents sends the container's events to the events channel: its stats
+// every interval, as well as OOM (and, for cgroup v2, memory.events) events
+// as they occur. It returns once the container's cgroup no longer exists,
+// or once done is closed.
+func streamEvents(container libcontainer.Container, interval time.Duration, events chan<- *types.Event, done <-chan struct{}) error {
+	var (
+		oom       <-chan struct{}
+		memEvents <-chan libcontainer.MemoryEvent
+		err       error
+		// quit stops both the notifiers and the stats goroutine below.
+		quit = make(chan struct{})
+	)
+	defer close(quit)
+	if cgroups.IsCgroup2UnifiedMode() {
+		memEvents, err = container.NotifyMemoryEventsUntil(quit)
+	} else {
+		oom, err = container.NotifyOOMUntil(quit)
+	}
+	if err != nil {
+		return err
+	}
+
+	var (
+		stats  = make(chan *libcontainer.Stats, 1)
+		ticker = time.NewTicker(interval)
+	)
+	defer ticker.Stop()
+	go func() {
 		for {
 			select {
-			case _, ok := <-n:
-				if ok {
-					// this means an oom event was received, if it is !ok then
-					// the channel was closed because the container stopped and
-					// the cgroups no longer exist.
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+			s, err := container.Stats()
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			select {
+			case stats <- s:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case _, ok := <-oom:
+			if ok {
+				// this means an oom event was received, if it is !ok then
+				// the channel was closed because the container stopped and
+				// the cgroups no longer exist.
+				events <- &types.Event{Type: "oom", ID: container.ID()}
+			} else {
+				oom = nil
+			}
+		case e, ok := <-memEvents:
+			if ok {
+				events <- &types.Event{Type: "memory." + e.Name, ID: container.ID(), Data: types.MemoryEvent{Count: e.Count, Local: e.Local}}
+				// Report OOM kills the same way as with cgroup v1.
+				if e.Name == "oom_kill" && !e.Local {
 					events <- &types.Event{Type: "oom", ID: container.ID()}
-				} else {
-					n = nil
 				}
-			case s := <-stats:
-				events <- &types.Event{Type: "stats", ID: container.ID(), Data: convertLibcontainerStats(s)}
-			}
-			if n == nil {
-				close(events)
-				break
+			} else {
+				memEvents = nil
 			}
+		case s := <-stats:
+			events <- &types.Event{Type: "stats", ID: container.ID(), Data: convertLibcontainerStats(s)}
+		case <-done:
+			return nil
 		}
-		group.Wait()
-		return nil
-	},
+		if oom == nil && memEvents == nil {
+			return nil
+		}
+	}
 }
 
 func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
@@ -129,6 +266,7 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.CPU.Throttling.Periods = cg.CpuStats.ThrottlingData.Periods
 	s.CPU.Throttling.ThrottledPeriods = cg.CpuStats.ThrottlingData.ThrottledPeriods
 	s.CPU.Throttling.ThrottledTime = cg.CpuStats.ThrottlingData.ThrottledTime
+	s.CPU.PSI = convertPSI(cg.CpuStats.PSI)
 
 	s.CPUSet = types.CPUSet(cg.CPUSetStats)
 
@@ -138,6 +276,7 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.Memory.Swap = convertMemoryEntry(cg.MemoryStats.SwapUsage)
 	s.Memory.Usage = convertMemoryEntry(cg.MemoryStats.Usage)
 	s.Memory.Raw = cg.MemoryStats.Stats
+	s.Memory.PSI = convertPSI(cg.MemoryStats.PSI)
 
 	s.Blkio.IoServiceBytesRecursive = convertBlkioEntry(cg.BlkioStats.IoServiceBytesRecursive)
 	s.Blkio.IoServicedRecursive = convertBlkioEntry(cg.BlkioStats.IoServicedRecursive)
@@ -147,6 +286,7 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.Blkio.IoMergedRecursive = convertBlkioEntry(cg.BlkioStats.IoMergedRecursive)
 	s.Blkio.IoTimeRecursive = convertBlkioEntry(cg.BlkioStats.IoTimeRecursive)
 	s.Blkio.SectorsRecursive = convertBlkioEntry(cg.BlkioStats.SectorsRecursive)
+	s.Blkio.PSI = convertPSI(cg.BlkioStats.PSI)
 
 	s.Hugetlb = make(map[string]types.Hugetlb)
 	for k, v := range cg.HugetlbStats {
@@ -201,6 +341,16 @@ func convertBlkioEntry(c []cgroups.BlkioStatEntry) []types.BlkioEntry {
 	return out
 }
 
+func convertPSI(p *cgroups.PSIStats) *types.PSIStats {
+	if p == nil {
+		return nil
+	}
+	return &types.PSIStats{
+		Some: types.PSIData(p.Some),
+		Full: types.PSIData(p.Full),
+	}
+}
+
 func convertL3CacheInfo(i *intelrdt.L3CacheInfo) *types.L3CacheInfo {
 	ci := types.L3CacheInfo(*i)
 	return &ci
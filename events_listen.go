@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/types"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+// eventsServer serves the events of the containers of a factory over a unix
+// socket. Each client sends types.EventsRequest objects to subscribe to (and
+// unsubscribe from) containers, and receives their events as types.Event
+// objects, one per line:
+//
+//   - a "subscribe" event once subscribed to a container;
+//   - the container events, as in `runc events`;
+//   - an "unsubscribe" event once unsubscribed from a container, either on
+//     request or because the container has stopped;
+//   - an "error" event, with the error message as data, if a request failed.
+type eventsServer struct {
+	factory  libcontainer.Factory
+	interval time.Duration
+}
+
+func listenEvents(context *cli.Context, path string, interval time.Duration) error {
+	factory, err := loadFactory(context)
+	if err != nil {
+		return err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// Remove the socket once we are terminated.
+	sigc := make(chan os.Signal, 1)
+	stopping := make(chan struct{})
+	signal.Notify(sigc, unix.SIGINT, unix.SIGTERM)
+	go func() {
+		<-sigc
+		close(stopping)
+		l.Close()
+	}()
+
+	s := &eventsServer{factory: factory, interval: interval}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-stopping:
+				return nil
+			default:
+			}
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// serve handles the requests of a client until it disconnects.
+func (s *eventsServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var (
+		events = make(chan *types.Event, 1024)
+		group  = &sync.WaitGroup{}
+		mu     sync.Mutex
+		subs   = make(map[string]chan struct{})
+	)
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		enc := json.NewEncoder(conn)
+		failed := false
+		for e := range events {
+			// Keep on consuming the events until all the
+			// subscriptions are gone.
+			if failed {
+				continue
+			}
+			if err := enc.Encode(e); err != nil {
+				logrus.Debugf("unable to send event to events client: %v", err)
+				failed = true
+			}
+		}
+	}()
+	sendError := func(id string, err error) {
+		events <- &types.Event{Type: "error", ID: id, Data: err.Error()}
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req types.EventsRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			sendError("", fmt.Errorf("invalid request: %w", err))
+			continue
+		}
+		switch req.Action {
+		case types.EventsSubscribe:
+			mu.Lock()
+			_, ok := subs[req.ID]
+			mu.Unlock()
+			if ok {
+				sendError(req.ID, errors.New("already subscribed"))
+				continue
+			}
+			container, err := s.load(req.ID)
+			if err != nil {
+				sendError(req.ID, err)
+				continue
+			}
+			stop := make(chan struct{})
+			mu.Lock()
+			subs[req.ID] = stop
+			mu.Unlock()
+			events <- &types.Event{Type: types.EventsSubscribe, ID: req.ID}
+			group.Add(1)
+			go func(id string) {
+				defer group.Done()
+				if err := streamEvents(container, s.interval, events, stop); err != nil {
+					sendError(id, err)
+				}
+				mu.Lock()
+				if subs[id] == stop {
+					delete(subs, id)
+				}
+				mu.Unlock()
+				events <- &types.Event{Type: types.EventsUnsubscribe, ID: id}
+			}(req.ID)
+		case types.EventsUnsubscribe:
+			mu.Lock()
+			stop, ok := subs[req.ID]
+			delete(subs, req.ID)
+			mu.Unlock()
+			if !ok {
+				sendError(req.ID, errors.New("not subscribed"))
+				continue
+			}
+			close(stop)
+		default:
+			sendError(req.ID, fmt.Errorf("invalid request action %q", req.Action))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logrus.Debugf("unable to read events client request: %v", err)
+	}
+
+	// The client is gone, unsubscribe from everything.
+	mu.Lock()
+	for id, stop := range subs {
+		delete(subs, id)
+		close(stop)
+	}
+	mu.Unlock()
+	group.Wait()
+	close(events)
+	<-writerDone
+}
+
+// load returns the running container with the given ID.
+func (s *eventsServer) load(id string) (libcontainer.Container, error) {
+	if id == "" {
+		return nil, errEmptyID
+	}
+	container, err := s.factory.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	status, err := container.Status()
+	if err != nil {
+		return nil, err
+	}
+	if status == libcontainer.Stopped {
+		return nil, fmt.Errorf("container with id %s is not running", id)
+	}
+	return container, nil
+}
@@ -9,6 +9,9 @@ import (
 	"strings"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/specconv"
 	"github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/urfave/cli"
@@ -28,11 +31,15 @@ For example, if the container is configured to run the linux ps command the
 following will output a list of processes running in the container:
 
        # runc exec <container-id> ps`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name:  "console-socket",
 			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal",
 		},
+		cli.StringFlag{
+			Name:  "pidfd-socket",
+			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the exec'd process (a pidfd)",
+		},
 		cli.StringFlag{
 			Name:  "cwd",
 			Usage: "current working directory in the container",
@@ -87,7 +94,15 @@ following will output a list of processes running in the container:
 			Name:  "preserve-fds",
 			Usage: "Pass N additional file descriptors to the container (stdio + $LISTEN_FDS + N in total)",
 		},
-	},
+		cli.StringSliceFlag{
+			Name:  "cgroup",
+			Usage: "run the process in a sub-cgroup of the container's cgroup (created if needed), as [<controller>[,<controller>...]:]<path> (may be repeated)",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-resources",
+			Usage: "path to a file with the resources of the sub-cgroup, in the format of runc update --resources (cgroup v1 only)",
+		},
+	}, stdioLogFlags...),
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, minArgs); err != nil {
 			return err
@@ -126,6 +141,33 @@ func execProcess(context *cli.Context) (int, error) {
 	if err != nil {
 		return -1, err
 	}
+	// This has to be done before getProcess changes the working directory,
+	// for a relative log path to be relative to ours.
+	logConfig, err := newStdioLog(context)
+	if err != nil {
+		return -1, err
+	}
+	subCgroupPaths, err := parseSubCgroupPaths(context.StringSlice("cgroup"))
+	if err != nil {
+		return -1, err
+	}
+	var subCgroupResources *configs.Resources
+	if path := context.String("cgroup-resources"); path != "" {
+		if len(subCgroupPaths) == 0 {
+			return -1, errors.New("--cgroup-resources requires --cgroup")
+		}
+		// The controllers can't be enabled for the sub-cgroups, as the
+		// container's init is in the container's cgroup.
+		if cgroups.IsCgroup2UnifiedMode() {
+			return -1, errors.New("--cgroup-resources is not supported with cgroup v2")
+		}
+		// This has to be done before getProcess changes the working
+		// directory too.
+		subCgroupResources, err = loadSubCgroupResources(path)
+		if err != nil {
+			return -1, err
+		}
+	}
 	bundle := utils.SearchLabels(state.Config.Labels, "bundle")
 	p, err := getProcess(context, bundle)
 	if err != nil {
@@ -142,16 +184,68 @@ func execProcess(context *cli.Context) (int, error) {
 		shouldDestroy:   false,
 		container:       container,
 		consoleSocket:   context.String("console-socket"),
+		pidfdSocket:     context.String("pidfd-socket"),
 		detach:          detach,
 		pidFile:         context.String("pid-file"),
 		action:          CT_ACT_RUN,
 		init:            false,
 		preserveFDs:     context.Int("preserve-fds"),
 		logLevel:        logLevel,
+		globalArgs:      globalArgs(context),
+		stdioLog:        logConfig,
+
+		subCgroupPaths:     subCgroupPaths,
+		subCgroupResources: subCgroupResources,
 	}
 	return r.run(p)
 }
 
+// parseSubCgroupPaths parses the --cgroup options into the sub-cgroup paths of
+// a libcontainer.Process.
+func parseSubCgroupPaths(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	paths := make(map[string]string, len(args))
+	for _, arg := range args {
+		controllers, path := "", arg
+		if i := strings.IndexByte(arg, ':'); i >= 0 {
+			controllers, path = arg[:i], arg[i+1:]
+		}
+		if path == "" {
+			return nil, fmt.Errorf("invalid --cgroup %q: empty path", arg)
+		}
+		for _, controller := range strings.Split(controllers, ",") {
+			if _, ok := paths[controller]; ok {
+				return nil, fmt.Errorf("invalid --cgroup %q: duplicate controller %q", arg, controller)
+			}
+			paths[controller] = path
+		}
+	}
+	return paths, nil
+}
+
+// loadSubCgroupResources reads the resources of a sub-cgroup from path, in the
+// format of the linux.resources of the runtime spec.
+func loadSubCgroupResources(path string) (*configs.Resources, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r specs.LinuxResources
+	if err := json.NewDecoder(f).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid cgroup resources %s: %w", path, err)
+	}
+	cg, err := specconv.CreateCgroupConfig(&specconv.CreateOpts{
+		Spec: &specs.Spec{Linux: &specs.Linux{Resources: &r}},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cg.Resources, nil
+}
+
 func getProcess(context *cli.Context, bundle string) (*specs.Process, error) {
 	if path := context.String("process"); path != "" {
 		f, err := os.Open(path)
@@ -175,6 +269,9 @@ func getProcess(context *cli.Context, bundle string) (*specs.Process, error) {
 	}
 	p := spec.Process
 	p.Args = context.Args()[1:]
+	// Use the rlimits of the container, which runc update may have changed
+	// since it was created.
+	p.Rlimits = nil
 	// override the cwd, if passed
 	if context.String("cwd") != "" {
 		p.Cwd = context.String("cwd")
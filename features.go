@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/opencontainers/runc/libcontainer/apparmor"
+	"github.com/opencontainers/runc/libcontainer/capabilities"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/libcontainer/seccomp"
+	"github.com/opencontainers/runc/libcontainer/specconv"
+	runcfeatures "github.com/opencontainers/runc/types/features"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/runtime-spec/specs-go/features"
+	"github.com/opencontainers/selinux/go-selinux"
+	"github.com/urfave/cli"
+)
+
+var featuresCommand = cli.Command{
+	Name:      "features",
+	Usage:     "show the enabled features",
+	ArgsUsage: "",
+	Description: `Show the enabled features.
+   The result is parsable as a JSON.
+   See https://pkg.go.dev/github.com/opencontainers/runtime-spec/specs-go/features
+   for the type definition, and types/features for the runc specific annotations.
+`,
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 0, exactArgs); err != nil {
+			return err
+		}
+
+		tru := true
+		unified := cgroups.IsCgroup2UnifiedMode()
+		systemdRunning := systemd.IsRunningSystemd()
+
+		feat := features.Features{
+			OCIVersionMin: "1.0.0",
+			OCIVersionMax: specs.Version,
+			Annotations: map[string]string{
+				runcfeatures.AnnotationRuncVersion:           version,
+				runcfeatures.AnnotationRuncCommit:            gitCommit,
+				runcfeatures.AnnotationRuncCheckpointEnabled: "true",
+				runcfeatures.AnnotationCgroupUnified:         strconv.FormatBool(unified),
+				runcfeatures.AnnotationIntelRdtCATEnabled:    strconv.FormatBool(intelrdt.IsCATEnabled()),
+				runcfeatures.AnnotationIntelRdtMBAEnabled:    strconv.FormatBool(intelrdt.IsMBAEnabled()),
+			},
+			Hooks:        configs.KnownHookNames(),
+			MountOptions: specconv.KnownMountOptions(),
+			Linux: &features.Linux{
+				Namespaces:   specconv.KnownNamespaces(),
+				Capabilities: capabilities.KnownCapabilities(),
+				Cgroup: &features.Cgroup{
+					V1:          boolPtr(!unified),
+					V2:          boolPtr(unified),
+					Systemd:     boolPtr(systemdRunning),
+					SystemdUser: boolPtr(systemdRunning),
+					Rdma:        boolPtr(hasCgroupSubsystem("rdma")),
+				},
+				Apparmor: &features.Apparmor{
+					Enabled: boolPtr(apparmor.IsEnabled()),
+				},
+				Selinux: &features.Selinux{
+					Enabled: boolPtr(selinux.GetEnabled()),
+				},
+				IntelRdt: &features.IntelRdt{
+					Enabled: boolPtr(intelrdt.IsCATEnabled() || intelrdt.IsMBAEnabled()),
+				},
+			},
+		}
+
+		if seccomp.Enabled {
+			feat.Linux.Seccomp = &features.Seccomp{
+				Enabled:        &tru,
+				Actions:        seccomp.KnownActions(),
+				Operators:      seccomp.KnownOperators(),
+				Archs:          seccomp.KnownArchs(),
+				KnownFlags:     seccomp.KnownFlags(),
+				SupportedFlags: seccomp.SupportedFlags(),
+			}
+			major, minor, patch := seccomp.Version()
+			feat.Annotations[runcfeatures.AnnotationLibseccompVersion] = fmt.Sprintf("%d.%d.%d", major, minor, patch)
+		}
+
+		enc := json.NewEncoder(context.App.Writer)
+		enc.SetIndent("", "    ")
+		return enc.Encode(feat)
+	},
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// hasCgroupSubsystem reports whether the host kernel provides the named
+// cgroup controller.
+func hasCgroupSubsystem(name string) bool {
+	subsystems, err := cgroups.GetAllSubsystems()
+	if err != nil {
+		return false
+	}
+	for _, s := range subsystems {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/containerd/console"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+// The IO broker and runc attach talk over the attach socket using frames
+// made of a type, the length of the payload (as a big endian uint32) and the
+// payload.
+const (
+	// frameMode is sent by the broker once connected, with a payload of 1
+	// if the process has a terminal, 0 otherwise.
+	frameMode = 'm'
+	// frameStdout and frameStderr carry the output of the process, from
+	// the broker (frameStdout only, if the process has a terminal).
+	frameStdout = 'o'
+	frameStderr = 'e'
+	// frameStdin carries input for the process, from the client.
+	frameStdin = 'i'
+	// frameStdinClose closes the stdin of the process (if it has no
+	// terminal), from the client.
+	frameStdinClose = 'c'
+	// frameResize resizes the terminal of the process, from the client,
+	// with the number of rows and columns as big endian uint16s.
+	frameResize = 'r'
+
+	maxFrameSize = 1 << 20
+)
+
+const (
+	// attachSocketName is the name of the attach socket of the IO broker,
+	// in the state directory of the container.
+	attachSocketName = "attach.sock"
+
+	// scrollbackSize is the amount of output that is kept and replayed to
+	// clients once connected.
+	scrollbackSize = 64 * 1024
+
+	// clientBacklog is the number of frames queued for a client, which is
+	// disconnected once it can't keep up, rather than stalling the process.
+	clientBacklog = 256
+)
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return err
+}
+
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame too large (%d bytes)", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], payload, nil
+}
+
+// startIOBroker sets up the stdio of the process (either a terminal, or
+// pipes) to be held by a background runc process, the IO broker, which runc
+// attach connects to through attachPath.
+func startIOBroker(p *libcontainer.Process, rootuid, rootgid int, createTTY bool, attachPath string, globalArgs []string) (*tty, error) {
+	// Only the owner of the container may attach to it, so the socket must
+	// never be accessible to anyone else, not even briefly.
+	oldMask := unix.Umask(0o177)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: attachPath, Net: "unix"})
+	unix.Umask(oldMask)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is the broker's to remove.
+	l.SetUnlinkOnClose(false)
+	defer l.Close()
+	lf, err := l.File()
+	if err != nil {
+		return nil, err
+	}
+	defer lf.Close()
+
+	var t *tty
+	files := []*os.File{lf}
+	args := []string{"io-broker"}
+	if createTTY {
+		parent, child, err := utils.NewSockPair("console")
+		if err != nil {
+			return nil, err
+		}
+		p.Stdin, p.Stdout, p.Stderr = nil, nil, nil
+		p.ConsoleSocket = child
+		t = &tty{postStart: []io.Closer{parent, child}}
+		files = append(files, parent)
+		args = append(args, "--terminal")
+	} else {
+		var i *libcontainer.IO
+		t, i, err = processPipes(p, rootuid, rootgid)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, i.Stdin.(*os.File), i.Stdout.(*os.File), i.Stderr.(*os.File))
+	}
+	cmd := backgroundCommand(globalArgs, args...)
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		_ = os.Remove(attachPath)
+		_ = t.ClosePostStart()
+		t.Close()
+		return nil, fmt.Errorf("unable to start the IO broker: %w", err)
+	}
+	if err := cmd.Process.Release(); err != nil {
+		logrus.Warn(err)
+	}
+	return t, nil
+}
+
+// ioBrokerCommand is the background process started by startIOBroker,
+// getting the attach socket as fd 3 and either, with --terminal, a socket
+// to receive the console master from as fd 4, or our ends of the stdin,
+// stdout and stderr pipes as fds 4, 5 and 6.
+var ioBrokerCommand = cli.Command{
+	Name:   "io-broker",
+	Hidden: true,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "terminal"},
+	},
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 0, exactArgs); err != nil {
+			return err
+		}
+		lf := os.NewFile(3, "attach-socket")
+		l, err := net.FileListener(lf)
+		lf.Close()
+		if err != nil {
+			return err
+		}
+		listener, ok := l.(*net.UnixListener)
+		if !ok {
+			return errors.New("casting to UnixListener failed")
+		}
+		b := &ioBroker{clients: make(map[*brokerClient]struct{})}
+		var outputs []io.Reader
+		if context.Bool("terminal") {
+			socket := os.NewFile(4, "console-socket")
+			master, err := utils.RecvFd(socket)
+			socket.Close()
+			if err != nil {
+				return err
+			}
+			c, err := console.ConsoleFromFile(master)
+			if err != nil {
+				return err
+			}
+			if err := console.ClearONLCR(c.Fd()); err != nil {
+				return err
+			}
+			b.console = c
+			b.stdin = c
+			outputs = []io.Reader{c}
+		} else {
+			b.stdin = os.NewFile(4, "stdin")
+			outputs = []io.Reader{os.NewFile(5, "stdout"), os.NewFile(6, "stderr")}
+		}
+		go b.serve(listener)
+		b.pump(outputs)
+		// The process is gone along with its output. The attach socket is
+		// removed along with the state directory of the container.
+		_ = listener.Close()
+		b.closeClients()
+		return nil
+	},
+}
+
+type ioBroker struct {
+	mu         sync.Mutex
+	clients    map[*brokerClient]struct{}
+	scrollback []brokerFrame
+	size       int
+	done       bool
+	writers    sync.WaitGroup
+
+	stdinMu sync.Mutex
+	stdin   io.WriteCloser
+	console console.Console
+}
+
+type brokerFrame struct {
+	typ  byte
+	data []byte
+}
+
+type brokerClient struct {
+	conn   net.Conn
+	frames chan brokerFrame
+}
+
+// pump copies the outputs (stdout and, if any, stderr) of the process to
+// the clients until EOF.
+func (b *ioBroker) pump(outputs []io.Reader) {
+	var wg sync.WaitGroup
+	for n, r := range outputs {
+		typ := byte(frameStdout)
+		if n == 1 {
+			typ = frameStderr
+		}
+		wg.Add(1)
+		go func(r io.Reader, typ byte) {
+			defer wg.Done()
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					b.broadcast(brokerFrame{typ: typ, data: append([]byte(nil), buf[:n]...)})
+				}
+				if err != nil {
+					// A terminal whose other end is closed reports EIO.
+					return
+				}
+			}
+		}(r, typ)
+	}
+	wg.Wait()
+}
+
+// broadcast records f in the scrollback buffer, and sends it to clients.
+func (b *ioBroker) broadcast(f brokerFrame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scrollback = append(b.scrollback, f)
+	b.size += len(f.data)
+	for b.size > scrollbackSize && len(b.scrollback) > 1 {
+		b.size -= len(b.scrollback[0].data)
+		b.scrollback = b.scrollback[1:]
+	}
+	for c := range b.clients {
+		select {
+		case c.frames <- f:
+		default:
+			logrus.Debugf("io broker: dropping a client which can't keep up")
+			b.dropLocked(c)
+		}
+	}
+}
+
+func (b *ioBroker) dropLocked(c *brokerClient) {
+	if _, ok := b.clients[c]; ok {
+		delete(b.clients, c)
+		close(c.frames)
+	}
+}
+
+func (b *ioBroker) closeClients() {
+	b.mu.Lock()
+	b.done = true
+	for c := range b.clients {
+		b.dropLocked(c)
+	}
+	b.mu.Unlock()
+	// Let the clients get the remaining output, unless they are stuck.
+	done := make(chan struct{})
+	go func() {
+		b.writers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+func (b *ioBroker) serve(l *net.UnixListener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+// handle sends the scrollback buffer and then the output of the process to
+// the client conn, and handles the frames it sends.
+func (b *ioBroker) handle(conn net.Conn) {
+	mode := []byte{0}
+	if b.console != nil {
+		mode[0] = 1
+	}
+	c := &brokerClient{conn: conn, frames: make(chan brokerFrame, clientBacklog)}
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		conn.Close()
+		return
+	}
+	scrollback := append([]brokerFrame(nil), b.scrollback...)
+	b.clients[c] = struct{}{}
+	b.writers.Add(1)
+	b.mu.Unlock()
+
+	go func() {
+		defer b.writers.Done()
+		defer conn.Close()
+		if err := writeFrame(conn, frameMode, mode); err != nil {
+			return
+		}
+		for _, f := range scrollback {
+			if err := writeFrame(conn, f.typ, f.data); err != nil {
+				return
+			}
+		}
+		for f := range c.frames {
+			if err := writeFrame(conn, f.typ, f.data); err != nil {
+				return
+			}
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		typ, payload, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logrus.Debugf("io broker: %v", err)
+			}
+			break
+		}
+		if err := b.input(typ, payload); err != nil {
+			logrus.Debugf("io broker: %v", err)
+		}
+	}
+	b.mu.Lock()
+	b.dropLocked(c)
+	b.mu.Unlock()
+}
+
+func (b *ioBroker) input(typ byte, payload []byte) error {
+	b.stdinMu.Lock()
+	defer b.stdinMu.Unlock()
+	switch typ {
+	case frameStdin:
+		_, err := b.stdin.Write(payload)
+		return err
+	case frameStdinClose:
+		if b.console != nil {
+			return nil
+		}
+		return b.stdin.Close()
+	case frameResize:
+		if b.console == nil || len(payload) != 4 {
+			return nil
+		}
+		return b.console.Resize(console.WinSize{
+			Height: binary.BigEndian.Uint16(payload),
+			Width:  binary.BigEndian.Uint16(payload[2:]),
+		})
+	}
+	return fmt.Errorf("unknown frame type %q", typ)
+}
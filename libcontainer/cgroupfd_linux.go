@@ -0,0 +1,25 @@
+//go:build go1.20
+// +build go1.20
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// canStartInCgroup tells whether exec.Cmd supports starting a process
+// directly in a cgroup, which requires Go 1.20.
+const canStartInCgroup = true
+
+// startWithCgroupFD starts cmd in the cgroup referred to by cgroupFD, using
+// clone3(2) with CLONE_INTO_CGROUP.
+func startWithCgroupFD(cmd *exec.Cmd, cgroupFD *os.File) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroupFD.Fd())
+	return cmd.Start()
+}
@@ -0,0 +1,18 @@
+//go:build !go1.20
+// +build !go1.20
+
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// canStartInCgroup tells whether exec.Cmd supports starting a process
+// directly in a cgroup, which requires Go 1.20.
+const canStartInCgroup = false
+
+func startWithCgroupFD(_ *exec.Cmd, _ *os.File) error {
+	return errors.New("starting a process in a cgroup requires Go 1.20")
+}
@@ -1,6 +1,8 @@
 package cgroups
 
 import (
+	"os"
+
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
@@ -56,4 +58,25 @@ type Manager interface {
 
 	// OOMKillCount reports OOM kill count for the cgroup.
 	OOMKillCount() (uint64, error)
+
+	// CgroupFD returns an O_PATH file descriptor for the cgroup, creating
+	// the cgroup first if needed (and possible before any process is added
+	// to it), so that processes can be created directly in it by clone3(2)
+	// with CLONE_INTO_CGROUP. It is only supported for cgroup v2, and
+	// ErrNoCgroupFD is returned otherwise, as well as by the systemd driver
+	// before Apply has created the unit.
+	CgroupFD() (*os.File, error)
+}
+
+// SubManager is implemented by the managers which can run processes in
+// sub-cgroups of their cgroups.
+type SubManager interface {
+	// NewSubManager creates sub-cgroups of the cgroups of the manager, if
+	// they do not exist, to run processes in, and sets their resources to r
+	// if it is not nil. The keys of paths are controller names (as in
+	// GetPaths), or "" for all of them (the only one supported with cgroup
+	// v2), and the values paths relative to the manager's cgroup for the
+	// controller. The returned manager is for the sub-cgroups, and the
+	// manager's cgroups for the controllers without a sub-cgroup.
+	NewSubManager(paths map[string]string, r *configs.Resources) (Manager, error)
 }
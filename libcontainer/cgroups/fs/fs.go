@@ -414,3 +414,53 @@ func (m *manager) OOMKillCount() (uint64, error) {
 
 	return c, err
 }
+
+func (m *manager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
+
+func (m *manager) NewSubManager(subPaths map[string]string, r *configs.Resources) (cgroups.Manager, error) {
+	cg := &configs.Cgroup{Resources: r}
+	if r == nil {
+		cg.Resources = &configs.Resources{SkipDevices: true}
+	}
+	for controller := range subPaths {
+		if controller != "" && m.Path(controller) == "" {
+			return nil, fmt.Errorf("the container has no %s cgroup", controller)
+		}
+	}
+	// The processes stay in the manager's cgroups for the controllers
+	// without a sub-cgroup.
+	paths := make(map[string]string)
+	created := make(map[string]string)
+	for controller, path := range m.GetPaths() {
+		paths[controller] = path
+		sub, ok := subPaths[controller]
+		if !ok {
+			sub, ok = subPaths[""]
+		}
+		if !ok {
+			continue
+		}
+		path = filepath.Join(path, sub)
+		if controller == "cpuset" {
+			// The cpus and mems of a new cpuset cgroup are empty.
+			if err := (&CpusetGroup{}).ApplyDir(path, cg.Resources, -1); err != nil {
+				return nil, err
+			}
+		} else if err := os.MkdirAll(path, 0o755); err != nil {
+			return nil, err
+		}
+		paths[controller] = path
+		created[controller] = path
+	}
+	if len(created) == 0 {
+		return nil, errors.New("the container has no cgroup")
+	}
+	if r != nil {
+		if err := NewManager(cg, created, m.rootless).Set(r); err != nil {
+			return nil, err
+		}
+	}
+	return NewManager(cg, paths, m.rootless), nil
+}
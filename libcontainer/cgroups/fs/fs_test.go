@@ -141,3 +141,36 @@ func BenchmarkGetStats(b *testing.B) {
 		b.Fatalf("stats: %+v", st)
 	}
 }
+
+func TestNewSubManager(t *testing.T) {
+	paths := map[string]string{
+		"memory": t.TempDir(),
+		"pids":   t.TempDir(),
+	}
+	m := NewManager(&configs.Cgroup{Resources: &configs.Resources{}}, paths, false).(cgroups.SubManager)
+	sm, err := m.NewSubManager(map[string]string{"pids": "debug"}, &configs.Resources{PidsLimit: 10, SkipDevices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The process stays in the memory cgroup of the container.
+	subPaths := sm.GetPaths()
+	if subPaths["memory"] != paths["memory"] {
+		t.Errorf("expected the memory cgroup to be %s, got %s", paths["memory"], subPaths["memory"])
+	}
+	pidsPath := filepath.Join(paths["pids"], "debug")
+	if subPaths["pids"] != pidsPath {
+		t.Fatalf("expected the pids cgroup to be %s, got %s", pidsPath, subPaths["pids"])
+	}
+	value, err := cgroups.ReadFile(pidsPath, "pids.max")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "10" {
+		t.Errorf("expected pids.max of the sub-cgroup to be 10, got %q", value)
+	}
+
+	if _, err := m.NewSubManager(map[string]string{"cpu": "debug"}, nil); err == nil {
+		t.Error("expected an error for a controller without a cgroup")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
@@ -127,6 +128,17 @@ func (m *manager) GetStats() (*cgroups.Stats, error) {
 	if err := fscommon.RdmaGetStats(m.dirPath, st); err != nil && !os.IsNotExist(err) {
 		errs = append(errs, err)
 	}
+	// psi (since kernel 4.20)
+	var err error
+	if st.CpuStats.PSI, err = statPSI(m.dirPath, "cpu.pressure"); err != nil {
+		errs = append(errs, err)
+	}
+	if st.MemoryStats.PSI, err = statPSI(m.dirPath, "memory.pressure"); err != nil {
+		errs = append(errs, err)
+	}
+	if st.BlkioStats.PSI, err = statPSI(m.dirPath, "io.pressure"); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) > 0 && !m.rootless {
 		return st, fmt.Errorf("error while statting cgroup v2: %+v", errs)
 	}
@@ -210,11 +222,10 @@ func (m *manager) setUnified(res map[string]string) error {
 			if errors.Is(err, os.ErrPermission) || errors.Is(err, os.ErrNotExist) {
 				// Check if a controller is available,
 				// to give more specific error if not.
-				sk := strings.SplitN(k, ".", 2)
-				if len(sk) != 2 {
-					return fmt.Errorf("unified resource %q must be in the form CONTROLLER.PARAMETER", k)
+				c, err := cgroups.ParseUnifiedKey(k)
+				if err != nil {
+					return err
 				}
-				c := sk[0]
 				if _, ok := m.controllers[c]; !ok && c != "cgroup" {
 					return fmt.Errorf("unified resource %q can't be set: controller %q not available", k, c)
 				}
@@ -256,3 +267,34 @@ func (m *manager) OOMKillCount() (uint64, error) {
 
 	return c, err
 }
+
+func (m *manager) CgroupFD() (*os.File, error) {
+	if !cgroups.PathExists(m.dirPath) {
+		if err := CreateCgroupPath(m.dirPath, m.config); err != nil {
+			return nil, err
+		}
+	}
+	return cgroups.OpenCgroupFD(m.dirPath)
+}
+
+func (m *manager) NewSubManager(paths map[string]string, r *configs.Resources) (cgroups.Manager, error) {
+	sub, ok := paths[""]
+	if !ok || len(paths) != 1 {
+		return nil, errors.New("per-controller sub-cgroups are not supported with cgroup v2")
+	}
+	if r != nil {
+		// The controllers can't be enabled for the sub-cgroup, as the
+		// container's init is in the container's cgroup (see "no internal
+		// process constraint" in the kernel's cgroup-v2 documentation).
+		return nil, errors.New("the resources of a sub-cgroup can't be set with cgroup v2, as the container's init is in the container's cgroup")
+	}
+	cg := &configs.Cgroup{Resources: &configs.Resources{SkipDevices: true}}
+	sm, err := NewManager(cg, filepath.Join(m.dirPath, sub), m.rootless)
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.Apply(-1); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
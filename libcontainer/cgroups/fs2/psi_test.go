@@ -0,0 +1,77 @@
+package fs2
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+const examplePSIData = `some avg10=1.71 avg60=2.36 avg300=2.57 total=230548833
+full avg10=1.00 avg60=1.01 avg300=1.00 total=157622356`
+
+func TestStatCPUPSI(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	statPath := filepath.Join(fakeCgroupDir, "cpu.pressure")
+
+	if err := ioutil.WriteFile(statPath, []byte(examplePSIData), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	psi, err := statPSI(fakeCgroupDir, "cpu.pressure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &cgroups.PSIStats{
+		Some: cgroups.PSIData{
+			Avg10:  1.71,
+			Avg60:  2.36,
+			Avg300: 2.57,
+			Total:  230548833,
+		},
+		Full: cgroups.PSIData{
+			Avg10:  1.00,
+			Avg60:  1.01,
+			Avg300: 1.00,
+			Total:  157622356,
+		},
+	}
+	if !reflect.DeepEqual(psi, expected) {
+		t.Errorf("parsed cgroupv2 cpu.pressure doesn't match expected result: \ngot %#v\nexpected %#v\n", psi, expected)
+	}
+}
+
+func TestStatPSINotExist(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	psi, err := statPSI(t.TempDir(), "memory.pressure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if psi != nil {
+		t.Errorf("expected nil psi stats for missing memory.pressure, got %#v", psi)
+	}
+}
+
+func TestStatPSIInvalid(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	statPath := filepath.Join(fakeCgroupDir, "io.pressure")
+
+	if err := ioutil.WriteFile(statPath, []byte("some avg10=x avg60=0.00 avg300=0.00 total=0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := statPSI(fakeCgroupDir, "io.pressure"); err == nil {
+		t.Error("expected error for invalid io.pressure data, got nil")
+	}
+}
@@ -32,9 +32,31 @@ type CpuUsage struct {
 	UsageInUsermode uint64 `json:"usage_in_usermode"`
 }
 
+// PSIData denotes the pressure stall information for either "some" or "full"
+// stalls (see Documentation/accounting/psi.rst in the kernel sources).
+type PSIData struct {
+	// Percentage of time stalled, averaged over 10s, 60s and 300s windows.
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	// Total stall time.
+	// Units: microseconds.
+	Total uint64 `json:"total"`
+}
+
+// PSIStats are the pressure stall information of a resource (cgroup v2 only).
+type PSIStats struct {
+	// Some tasks are stalled on the resource.
+	Some PSIData `json:"some,omitempty"`
+	// All non-idle tasks are stalled on the resource.
+	// Not reported for the cpu resource on older kernels.
+	Full PSIData `json:"full,omitempty"`
+}
+
 type CpuStats struct {
 	CpuUsage       CpuUsage       `json:"cpu_usage,omitempty"`
 	ThrottlingData ThrottlingData `json:"throttling_data,omitempty"`
+	PSI            *PSIStats      `json:"psi,omitempty"`
 }
 
 type CPUSetStats struct {
@@ -89,6 +111,8 @@ type MemoryStats struct {
 	UseHierarchy bool `json:"use_hierarchy"`
 
 	Stats map[string]uint64 `json:"stats,omitempty"`
+
+	PSI *PSIStats `json:"psi,omitempty"`
 }
 
 type PageUsageByNUMA struct {
@@ -133,6 +157,7 @@ type BlkioStats struct {
 	IoMergedRecursive       []BlkioStatEntry `json:"io_merged_recursive,omitempty"`
 	IoTimeRecursive         []BlkioStatEntry `json:"io_time_recursive,omitempty"`
 	SectorsRecursive        []BlkioStatEntry `json:"sectors_recursive,omitempty"`
+	PSI                     *PSIStats        `json:"psi,omitempty"`
 }
 
 type HugetlbStats struct {
@@ -311,6 +311,15 @@ func getUnitName(c *configs.Cgroup) string {
 	return c.Name
 }
 
+// checkDelegated checks that the cgroups of the unit of c are delegated (see
+// Apply), for sub-cgroups to be created in them.
+func checkDelegated(c *configs.Cgroup) error {
+	if unitName := getUnitName(c); getUnitType(unitName) == "Slice" {
+		return fmt.Errorf("sub-cgroups can't be created in the cgroup of %s, which is not delegated", unitName)
+	}
+	return nil
+}
+
 // This code should be in sync with getUnitName.
 func getUnitType(unitName string) string {
 	if strings.HasSuffix(unitName, ".slice") {
@@ -450,3 +450,16 @@ func (m *legacyManager) Exists() bool {
 func (m *legacyManager) OOMKillCount() (uint64, error) {
 	return fs.OOMKillCount(m.Path("memory"))
 }
+
+func (m *legacyManager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
+
+// NewSubManager creates the sub-cgroups in the cgroups of the unit, which are
+// delegated to runc: systemd does not manage them.
+func (m *legacyManager) NewSubManager(paths map[string]string, r *configs.Resources) (cgroups.Manager, error) {
+	if err := checkDelegated(m.cgroups); err != nil {
+		return nil, err
+	}
+	return fs.NewManager(m.cgroups, m.GetPaths(), false).(cgroups.SubManager).NewSubManager(paths, r)
+}
@@ -2,7 +2,6 @@ package systemd
 
 import (
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -46,15 +45,9 @@ func NewUnifiedManager(config *configs.Cgroup, path string, rootless bool) cgrou
 //
 // For the list of systemd unit properties, see systemd.resource-control(5).
 func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props []systemdDbus.Property, _ error) {
-	var err error
-
 	for k, v := range res {
-		if strings.Contains(k, "/") {
-			return nil, fmt.Errorf("unified resource %q must be a file name (no slashes)", k)
-		}
-		sk := strings.SplitN(k, ".", 2)
-		if len(sk) != 2 {
-			return nil, fmt.Errorf("unified resource %q must be in the form CONTROLLER.PARAMETER", k)
+		if _, err := cgroups.ParseUnifiedKey(k); err != nil {
+			return nil, err
 		}
 		// Kernel is quite forgiving to extra whitespace
 		// around the value, and so should we.
@@ -63,25 +56,13 @@ func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props
 		switch k {
 		case "cpu.max":
 			// value: quota [period]
-			quota := int64(0) // 0 means "unlimited" for addCpuQuota, if period is set
-			period := defCPUQuotaPeriod
-			sv := strings.Fields(v)
-			if len(sv) < 1 || len(sv) > 2 {
-				return nil, fmt.Errorf("unified resource %q value invalid: %q", k, v)
-			}
-			// quota
-			if sv[0] != "max" {
-				quota, err = strconv.ParseInt(sv[0], 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("unified resource %q period value conversion error: %w", k, err)
-				}
+			// A quota of 0 means "unlimited" for addCpuQuota, if period is set.
+			quota, period, err := cgroups.ParseCPUMax(v)
+			if err != nil {
+				return nil, fmt.Errorf("unified resource %q value conversion error: %w", k, err)
 			}
-			// period
-			if len(sv) == 2 {
-				period, err = strconv.ParseUint(sv[1], 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("unified resource %q quota value conversion error: %w", k, err)
-				}
+			if period == 0 {
+				period = defCPUQuotaPeriod
 			}
 			addCpuQuota(cm, &props, quota, period)
 
@@ -114,12 +95,9 @@ func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props
 			}
 
 		case "memory.high", "memory.low", "memory.min", "memory.max", "memory.swap.max":
-			num := uint64(math.MaxUint64)
-			if v != "max" {
-				num, err = strconv.ParseUint(v, 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("unified resource %q value conversion error: %w", k, err)
-				}
+			num, err := cgroups.ParseUintOrMax(v)
+			if err != nil {
+				return nil, fmt.Errorf("unified resource %q value conversion error: %w", k, err)
 			}
 			m := map[string]string{
 				"memory.high":     "MemoryHigh",
@@ -132,13 +110,9 @@ func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props
 				newProp(m[k], num))
 
 		case "pids.max":
-			num := uint64(math.MaxUint64)
-			if v != "max" {
-				var err error
-				num, err = strconv.ParseUint(v, 10, 64)
-				if err != nil {
-					return nil, fmt.Errorf("unified resource %q value conversion error: %w", k, err)
-				}
+			num, err := cgroups.ParseUintOrMax(v)
+			if err != nil {
+				return nil, fmt.Errorf("unified resource %q value conversion error: %w", k, err)
 			}
 			props = append(props,
 				newProp("TasksMax", num))
@@ -449,3 +423,31 @@ func (m *unifiedManager) OOMKillCount() (uint64, error) {
 	}
 	return fsMgr.OOMKillCount()
 }
+
+// CgroupFD opens the cgroup of the unit, which only exists once the unit is
+// started, as systemd can't start a scope with no processes in it. This means
+// that the container's init process can't be created in its cgroup (the unit
+// is only started by Apply, from its pid), and ErrNoCgroupFD is returned until
+// then. Processes started later on, i.e. by runc exec, can.
+func (m *unifiedManager) CgroupFD() (*os.File, error) {
+	if err := m.initPath(); err != nil {
+		return nil, err
+	}
+	if !cgroups.PathExists(m.path) {
+		return nil, fmt.Errorf("unit %s not started yet: %w", getUnitName(m.cgroups), cgroups.ErrNoCgroupFD)
+	}
+	return cgroups.OpenCgroupFD(m.path)
+}
+
+// NewSubManager creates the sub-cgroup in the cgroup of the unit, which is
+// delegated to runc: systemd does not manage it.
+func (m *unifiedManager) NewSubManager(paths map[string]string, r *configs.Resources) (cgroups.Manager, error) {
+	if err := checkDelegated(m.cgroups); err != nil {
+		return nil, err
+	}
+	fsMgr, err := m.fsManager()
+	if err != nil {
+		return nil, err
+	}
+	return fsMgr.(cgroups.SubManager).NewSubManager(paths, r)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -26,6 +27,10 @@ const (
 var (
 	isUnifiedOnce sync.Once
 	isUnified     bool
+
+	// ErrNoCgroupFD is returned by Manager.CgroupFD when the cgroup can't
+	// be opened for processes to be created directly in it.
+	ErrNoCgroupFD = errors.New("cgroup fd not supported")
 )
 
 // IsCgroup2UnifiedMode returns whether we are running in cgroup v2 unified mode.
@@ -374,6 +379,16 @@ func WriteCgroupProc(dir string, pid int) error {
 	return err
 }
 
+// OpenCgroupFD opens the cgroup v2 directory dir with O_PATH, as needed
+// for clone3(2) with CLONE_INTO_CGROUP.
+func OpenCgroupFD(dir string) (*os.File, error) {
+	fd, err := unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	return os.NewFile(uintptr(fd), dir), nil
+}
+
 // Since the OCI spec is designed for cgroup v1, in some cases
 // there is need to convert from the cgroup v1 configuration to cgroup v2
 // the formula for cpuShares is y = (1 + ((x - 2) * 9999) / 262142)
@@ -424,3 +439,79 @@ func ConvertBlkIOToIOWeightValue(blkIoWeight uint16) uint64 {
 	}
 	return 1 + (uint64(blkIoWeight)-10)*9999/990
 }
+
+// ParseUnifiedKey checks that k, the name of a unified resource (that is, of
+// a cgroup v2 file), is in the form CONTROLLER.PARAMETER, and returns its
+// controller.
+func ParseUnifiedKey(k string) (string, error) {
+	if strings.Contains(k, "/") {
+		return "", fmt.Errorf("unified resource %q must be a file name (no slashes)", k)
+	}
+	sk := strings.SplitN(k, ".", 2)
+	if len(sk) != 2 || sk[0] == "" || sk[1] == "" {
+		return "", fmt.Errorf("unified resource %q must be in the form CONTROLLER.PARAMETER", k)
+	}
+	return sk[0], nil
+}
+
+// ParseCPUMax parses the value of cpu.max, "$MAX [$PERIOD]", $MAX being
+// either a number or "max". The quota returned is 0 for "max", and the
+// period is 0 if it is not set.
+func ParseCPUMax(v string) (quota int64, period uint64, err error) {
+	sv := strings.Fields(v)
+	if len(sv) < 1 || len(sv) > 2 {
+		return 0, 0, fmt.Errorf("invalid cpu.max value %q", v)
+	}
+	if sv[0] != "max" {
+		quota, err = strconv.ParseInt(sv[0], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid cpu.max quota: %w", err)
+		}
+	}
+	if len(sv) == 2 {
+		period, err = strconv.ParseUint(sv[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid cpu.max period: %w", err)
+		}
+	}
+	return quota, period, nil
+}
+
+// ParseUintOrMax parses v, either a number or "max", which is returned as
+// math.MaxUint64, such as the value of memory.max or pids.max.
+func ParseUintOrMax(v string) (uint64, error) {
+	if v == "max" {
+		return math.MaxUint64, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// ValidateUnified checks that the unified resource k can be set to v: that
+// its name is valid, and that its value is valid for the resources whose
+// format is known (the ones converted to unit properties by the systemd
+// cgroup manager).
+func ValidateUnified(k, v string) error {
+	if _, err := ParseUnifiedKey(k); err != nil {
+		return err
+	}
+	// Kernel is quite forgiving to extra whitespace around the value,
+	// and so should we.
+	v = strings.TrimSpace(v)
+	var err error
+	switch k {
+	case "cpu.max":
+		_, _, err = ParseCPUMax(v)
+	case "cpu.weight":
+		var w uint64
+		w, err = strconv.ParseUint(v, 10, 64)
+		if err == nil && (w < 1 || w > 10000) {
+			err = errors.New("must be from 1 to 10000")
+		}
+	case "memory.high", "memory.low", "memory.min", "memory.max", "memory.swap.max", "pids.max":
+		_, err = ParseUintOrMax(v)
+	}
+	if err != nil {
+		return fmt.Errorf("unified resource %q value invalid: %w", k, err)
+	}
+	return nil
+}
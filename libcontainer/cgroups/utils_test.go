@@ -653,3 +653,67 @@ func TestConvertBlkIOToIOWeightValue(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCPUMax(t *testing.T) {
+	cases := []struct {
+		value  string
+		quota  int64
+		period uint64
+		isErr  bool
+	}{
+		{value: "max", quota: 0, period: 0},
+		{value: "max 100000", quota: 0, period: 100000},
+		{value: "50000 100000", quota: 50000, period: 100000},
+		{value: "50000", quota: 50000, period: 0},
+		{value: "", isErr: true},
+		{value: "foo 100000", isErr: true},
+		{value: "50000 max", isErr: true},
+		{value: "50000 100000 1", isErr: true},
+	}
+	for _, c := range cases {
+		quota, period, err := ParseCPUMax(c.value)
+		if c.isErr {
+			if err == nil {
+				t.Errorf("ParseCPUMax(%q): expected error, got nil", c.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseCPUMax(%q): unexpected error: %v", c.value, err)
+			continue
+		}
+		if quota != c.quota || period != c.period {
+			t.Errorf("ParseCPUMax(%q): expected %d %d, got %d %d", c.value, c.quota, c.period, quota, period)
+		}
+	}
+}
+
+func TestValidateUnified(t *testing.T) {
+	cases := []struct {
+		key, value string
+		isErr      bool
+	}{
+		{key: "memory.high", value: "max"},
+		{key: "memory.high", value: " 1000\n"},
+		{key: "pids.max", value: "100"},
+		{key: "cpu.max", value: "max 100000"},
+		{key: "cpu.weight", value: "100"},
+		{key: "io.weight", value: "default 100"},
+		{key: "memory.high", value: "foo", isErr: true},
+		{key: "pids.max", value: "-1", isErr: true},
+		{key: "cpu.max", value: "100000 foo", isErr: true},
+		{key: "cpu.weight", value: "0", isErr: true},
+		{key: "cpu.weight", value: "10001", isErr: true},
+		{key: "../memory.high", value: "max", isErr: true},
+		{key: "memory", value: "max", isErr: true},
+		{key: ".high", value: "max", isErr: true},
+	}
+	for _, c := range cases {
+		err := ValidateUnified(c.key, c.value)
+		if c.isErr && err == nil {
+			t.Errorf("ValidateUnified(%q, %q): expected error, got nil", c.key, c.value)
+		} else if !c.isErr && err != nil {
+			t.Errorf("ValidateUnified(%q, %q): unexpected error: %v", c.key, c.value, err)
+		}
+	}
+}
@@ -13,6 +13,13 @@ import (
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
+// Scheduler is a CPU scheduling policy (such as system.SCHED_FIFO) and the static
+// priority for it, see sched_setscheduler(2).
+type Scheduler struct {
+	Policy   int `json:"policy"`
+	Priority int `json:"priority"`
+}
+
 type Rlimit struct {
 	Type int    `json:"type"`
 	Hard uint64 `json:"hard"`
@@ -35,6 +42,17 @@ type Seccomp struct {
 	Architectures   []string   `json:"architectures"`
 	Syscalls        []*Syscall `json:"syscalls"`
 	DefaultErrnoRet *uint      `json:"default_errno_ret"`
+
+	// Flags are the seccomp(2) filter flags to load the filter with.
+	Flags []specs.LinuxSeccompFlag `json:"flags,omitempty"`
+
+	// ListenerPath is the path of a unix socket the seccomp notify fd is
+	// sent to, if any of the syscalls use the Notify action.
+	ListenerPath string `json:"listener_path,omitempty"`
+
+	// ListenerMetadata is opaque data passed to the seccomp agent along
+	// with the container process state.
+	ListenerMetadata string `json:"listener_metadata,omitempty"`
 }
 
 // Action is taken upon rule match in Seccomp
@@ -47,6 +65,7 @@ const (
 	Allow
 	Trace
 	Log
+	Notify
 )
 
 // Operator is a comparison operator to be used when matching syscall arguments in Seccomp
@@ -153,6 +172,10 @@ type Config struct {
 	// More information about kernel oom score calculation here: https://lwn.net/Articles/317814/
 	OomScoreAdj *int `json:"oom_score_adj,omitempty"`
 
+	// Scheduler specifies the CPU scheduling policy and priority of the processes in the
+	// container. If it is unset then they are inherited from the parent process.
+	Scheduler *Scheduler `json:"scheduler,omitempty"`
+
 	// UidMappings is an array of User ID mappings for User Namespaces
 	UidMappings []IDMap `json:"uid_mappings"`
 
@@ -206,6 +229,10 @@ type Config struct {
 	// RootlessCgroups is set when unlikely to have the full access to cgroups.
 	// When RootlessCgroups is set, cgroups errors are ignored.
 	RootlessCgroups bool `json:"rootless_cgroups,omitempty"`
+
+	// TimeOffsets specifies the offsets of the monotonic and boottime clocks
+	// in a new time namespace, keyed by clock name ("monotonic" or "boottime").
+	TimeOffsets map[string]specs.LinuxTimeOffset `json:"time_offsets,omitempty"`
 }
 
 type (
@@ -246,6 +273,19 @@ const (
 	Poststop HookName = "poststop"
 )
 
+// KnownHookNames returns the known hook names.
+// Used by `runc features`.
+func KnownHookNames() []string {
+	return []string{
+		string(Prestart), // deprecated
+		string(CreateRuntime),
+		string(CreateContainer),
+		string(StartContainer),
+		string(Poststart),
+		string(Poststop),
+	}
+}
+
 type Capabilities struct {
 	// Bounding is the set of capabilities checked by the kernel.
 	Bounding []string
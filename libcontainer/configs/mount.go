@@ -36,4 +36,23 @@ type Mount struct {
 
 	// Optional Command to be run after Source is mounted.
 	PostmountCmds []Command `json:"postmount_cmds"`
+
+	// UIDMappings and GIDMappings, if set, turn a bind mount into an
+	// id-mapped mount, so that file ownership is translated according to
+	// these mappings instead of the container's user namespace having to
+	// match the on-disk owners. The underlying filesystem must support
+	// id-mapped mounts.
+	UIDMappings []IDMap `json:"uid_mappings,omitempty"`
+	GIDMappings []IDMap `json:"gid_mappings,omitempty"`
+}
+
+// IsBind returns whether the mount is a bind mount.
+func (m *Mount) IsBind() bool {
+	return m.Device == "bind"
+}
+
+// IsIDMapped returns whether the mount has uid or gid mappings and thus
+// needs to be set up as an id-mapped mount.
+func (m *Mount) IsIDMapped() bool {
+	return len(m.UIDMappings) > 0 || len(m.GIDMappings) > 0
 }
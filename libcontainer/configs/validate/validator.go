@@ -35,8 +35,11 @@ func (v *ConfigValidator) Validate(config *configs.Config) error {
 		v.network,
 		v.hostname,
 		v.security,
+		v.seccomp,
 		v.usernamespace,
+		v.idmappedMounts,
 		v.cgroupnamespace,
+		v.timenamespace,
 		v.sysctl,
 		v.intelrdt,
 		v.rootlessEUID,
@@ -106,6 +109,23 @@ func (v *ConfigValidator) security(config *configs.Config) error {
 	return nil
 }
 
+// seccomp validates that a seccomp agent is configured if any of the
+// seccomp rules use the Notify action.
+func (v *ConfigValidator) seccomp(config *configs.Config) error {
+	if config.Seccomp == nil {
+		return nil
+	}
+	if config.Seccomp.DefaultAction == configs.Notify {
+		return errors.New("seccomp notify cannot be used as the default action")
+	}
+	for _, call := range config.Seccomp.Syscalls {
+		if call != nil && call.Action == configs.Notify && config.Seccomp.ListenerPath == "" {
+			return fmt.Errorf("seccomp notify is used for syscall %q, but listenerPath is not set", call.Name)
+		}
+	}
+	return nil
+}
+
 func (v *ConfigValidator) usernamespace(config *configs.Config) error {
 	if config.Namespaces.Contains(configs.NEWUSER) {
 		if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
@@ -119,6 +139,36 @@ func (v *ConfigValidator) usernamespace(config *configs.Config) error {
 	return nil
 }
 
+// idmappedMounts validates that id-mapped mounts are only requested for bind
+// mounts of containers with a user namespace, and that their mappings are sane.
+func (v *ConfigValidator) idmappedMounts(config *configs.Config) error {
+	for _, m := range config.Mounts {
+		if !m.IsIDMapped() {
+			continue
+		}
+		if !m.IsBind() {
+			return fmt.Errorf("invalid mount %s: id-mapped mounts are only supported for bind mounts", m.Destination)
+		}
+		if !config.Namespaces.Contains(configs.NEWUSER) {
+			return fmt.Errorf("invalid mount %s: id-mapped mounts require a user namespace", m.Destination)
+		}
+		if config.RootlessEUID {
+			return fmt.Errorf("invalid mount %s: id-mapped mounts are not supported for rootless containers", m.Destination)
+		}
+		if len(m.UIDMappings) == 0 || len(m.GIDMappings) == 0 {
+			return fmt.Errorf("invalid mount %s: id-mapped mounts must have both uid and gid mappings", m.Destination)
+		}
+		for _, idMaps := range [][]configs.IDMap{m.UIDMappings, m.GIDMappings} {
+			for _, idMap := range idMaps {
+				if idMap.ContainerID < 0 || idMap.HostID < 0 || idMap.Size <= 0 {
+					return fmt.Errorf("invalid mount %s: invalid id mapping %+v", m.Destination, idMap)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (v *ConfigValidator) cgroupnamespace(config *configs.Config) error {
 	if config.Namespaces.Contains(configs.NEWCGROUP) {
 		if _, err := os.Stat("/proc/self/ns/cgroup"); os.IsNotExist(err) {
@@ -128,6 +178,27 @@ func (v *ConfigValidator) cgroupnamespace(config *configs.Config) error {
 	return nil
 }
 
+// timenamespace validates that the time namespace is supported, and that the
+// clock offsets are only set for a new time namespace.
+func (v *ConfigValidator) timenamespace(config *configs.Config) error {
+	if config.Namespaces.Contains(configs.NEWTIME) {
+		if _, err := os.Stat("/proc/self/ns/time"); os.IsNotExist(err) {
+			return errors.New("time namespaces aren't enabled in the kernel")
+		}
+		if config.Namespaces.PathOf(configs.NEWTIME) != "" && len(config.TimeOffsets) > 0 {
+			return errors.New("time namespace offsets specified, but joining an existing time namespace")
+		}
+	} else if len(config.TimeOffsets) > 0 {
+		return errors.New("time namespace offsets specified, but time namespace isn't enabled in the config")
+	}
+	for clock := range config.TimeOffsets {
+		if clock != "monotonic" && clock != "boottime" {
+			return fmt.Errorf("invalid time namespace clock %q: only monotonic and boottime can be offset", clock)
+		}
+	}
+	return nil
+}
+
 // sysctl validates that the specified sysctl keys are valid or not.
 // /proc/sys isn't completely namespaced and depending on which namespaces
 // are specified, a subset of sysctls are permitted.
@@ -7,6 +7,7 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
 )
 
@@ -151,6 +152,36 @@ func TestValidateSecurityWithoutNEWNS(t *testing.T) {
 	}
 }
 
+func TestValidateSeccompNotify(t *testing.T) {
+	config := &configs.Config{
+		Rootfs: "/var",
+		Seccomp: &configs.Seccomp{
+			DefaultAction: configs.Allow,
+			Syscalls: []*configs.Syscall{
+				{Name: "mount", Action: configs.Notify},
+			},
+		},
+	}
+
+	validator := validate.New()
+	err := validator.Validate(config)
+	if err == nil {
+		t.Error("Expected error to occur but it was nil")
+	}
+
+	config.Seccomp.ListenerPath = "/run/seccomp-agent.sock"
+	err = validator.Validate(config)
+	if err != nil {
+		t.Errorf("Expected error to not occur: %+v", err)
+	}
+
+	config.Seccomp.DefaultAction = configs.Notify
+	err = validator.Validate(config)
+	if err == nil {
+		t.Error("Expected error to occur but it was nil")
+	}
+}
+
 func TestValidateUsernamespace(t *testing.T) {
 	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
 		t.Skip("Test requires userns.")
@@ -185,6 +216,40 @@ func TestValidateUsernamespaceWithoutUserNS(t *testing.T) {
 	}
 }
 
+func TestValidateTimeNamespaceOffsets(t *testing.T) {
+	if _, err := os.Stat("/proc/self/ns/time"); os.IsNotExist(err) {
+		t.Skip("Test requires timens.")
+	}
+	config := &configs.Config{
+		Rootfs: "/var",
+		TimeOffsets: map[string]specs.LinuxTimeOffset{
+			"monotonic": {Secs: 3600},
+		},
+	}
+
+	validator := validate.New()
+	err := validator.Validate(config)
+	if err == nil {
+		t.Error("Expected error to occur but it was nil")
+	}
+
+	config.Namespaces = configs.Namespaces(
+		[]configs.Namespace{
+			{Type: configs.NEWTIME},
+		},
+	)
+	err = validator.Validate(config)
+	if err != nil {
+		t.Errorf("Expected error to not occur: %+v", err)
+	}
+
+	config.TimeOffsets["realtime"] = specs.LinuxTimeOffset{Secs: 1}
+	err = validator.Validate(config)
+	if err == nil {
+		t.Error("Expected error to occur but it was nil")
+	}
+}
+
 func TestValidateSysctl(t *testing.T) {
 	sysctl := map[string]string{
 		"fs.mqueue.ctl": "ctl",
@@ -345,3 +410,69 @@ func TestValidateMounts(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateIDMapMounts(t *testing.T) {
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("Test requires userns.")
+	}
+	idMap := []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	userns := configs.Namespaces([]configs.Namespace{{Type: configs.NEWUSER}})
+	testCases := []struct {
+		name  string
+		isErr bool
+		ns    configs.Namespaces
+		mount *configs.Mount
+	}{
+		{
+			name:  "bind mount",
+			ns:    userns,
+			mount: &configs.Mount{Device: "bind", Source: "/abs/path", Destination: "/abs/path", UIDMappings: idMap, GIDMappings: idMap},
+		},
+		{
+			name:  "not a bind mount",
+			isErr: true,
+			ns:    userns,
+			mount: &configs.Mount{Device: "tmpfs", Source: "tmpfs", Destination: "/abs/path", UIDMappings: idMap, GIDMappings: idMap},
+		},
+		{
+			name:  "no user namespace",
+			isErr: true,
+			mount: &configs.Mount{Device: "bind", Source: "/abs/path", Destination: "/abs/path", UIDMappings: idMap, GIDMappings: idMap},
+		},
+		{
+			name:  "no gid mappings",
+			isErr: true,
+			ns:    userns,
+			mount: &configs.Mount{Device: "bind", Source: "/abs/path", Destination: "/abs/path", UIDMappings: idMap},
+		},
+		{
+			name:  "empty mapping",
+			isErr: true,
+			ns:    userns,
+			mount: &configs.Mount{Device: "bind", Source: "/abs/path", Destination: "/abs/path", UIDMappings: []configs.IDMap{{}}, GIDMappings: idMap},
+		},
+	}
+
+	validator := validate.New()
+
+	for _, tc := range testCases {
+		config := &configs.Config{
+			Rootfs:      "/var",
+			Namespaces:  tc.ns,
+			Mounts:      []*configs.Mount{tc.mount},
+			UidMappings: idMap,
+			GidMappings: idMap,
+		}
+		if tc.ns == nil {
+			config.UidMappings, config.GidMappings = nil, nil
+		}
+
+		err := validator.Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("%s: expected nil, got error %v", tc.name, err)
+		}
+	}
+}
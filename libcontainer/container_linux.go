@@ -2,6 +2,7 @@ package libcontainer
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,16 +13,19 @@ import (
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/checkpoint-restore/go-criu/v5"
-	criurpc "github.com/checkpoint-restore/go-criu/v5/rpc"
+	"github.com/checkpoint-restore/go-criu/v7"
+	criurpc "github.com/checkpoint-restore/go-criu/v7/rpc"
+	criustats "github.com/checkpoint-restore/go-criu/v7/stats"
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
+	"github.com/syndtr/gocapability/capability"
 	"github.com/vishvananda/netlink/nl"
 	"golang.org/x/sys/unix"
 	"google.golang.org/protobuf/proto"
@@ -30,6 +34,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
 	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/userns"
 	"github.com/opencontainers/runc/libcontainer/utils"
 )
 
@@ -53,6 +58,7 @@ type linuxContainer struct {
 	state                containerState
 	created              time.Time
 	fifo                 *os.File
+	exitStatus           *ExitStatus
 }
 
 // State represents a running container's state
@@ -82,6 +88,15 @@ type State struct {
 
 	// Intel RDT "resource control" filesystem path
 	IntelRdtPath string `json:"intel_rdt_path"`
+
+	// InitProcessPidfdInode is the inode number of the init process's
+	// pidfds, which identifies it more reliably than its start time, if
+	// supported by the kernel (Linux >= 6.9), or 0.
+	InitProcessPidfdInode uint64 `json:"init_process_pidfd_inode,omitempty"`
+
+	// ExitStatus is the exit status of the init process, once it has been
+	// recorded by Wait.
+	ExitStatus *ExitStatus `json:"exit_status,omitempty"`
 }
 
 // Container is a libcontainer container object.
@@ -114,8 +129,30 @@ type Container interface {
 	// NotifyOOM returns a read-only channel signaling when the container receives an OOM notification.
 	NotifyOOM() (<-chan struct{}, error)
 
+	// NotifyOOMUntil is like NotifyOOM, except that the notifier is stopped, and the channel
+	// closed, once done is closed.
+	NotifyOOMUntil(done <-chan struct{}) (<-chan struct{}, error)
+
 	// NotifyMemoryPressure returns a read-only channel signaling when the container reaches a given pressure level
+	// (on cgroup v2, the levels are implemented as PSI triggers on memory.pressure).
 	NotifyMemoryPressure(level PressureLevel) (<-chan struct{}, error)
+
+	// NotifyMemoryEvents returns a read-only channel signaling every change of the container's
+	// memory.events counters (cgroup v2 only). The channel is closed once the container has no
+	// processes left.
+	NotifyMemoryEvents() (<-chan MemoryEvent, error)
+
+	// NotifyMemoryEventsUntil is like NotifyMemoryEvents, except that the notifier is stopped,
+	// and the channel closed, once done is closed.
+	NotifyMemoryEventsUntil(done <-chan struct{}) (<-chan MemoryEvent, error)
+
+	// Wait blocks until the container's init process has exited, and returns
+	// its exit status. If the caller is the parent of the init process, it
+	// reaps it and records its exit status in the container's state; otherwise
+	// the exit status recorded by the parent is returned. If the exit status
+	// can't be obtained (for example because the parent is not recording it),
+	// ErrExitStatusUnknown is returned.
+	Wait() (*ExitStatus, error)
 }
 
 // ID returns the container's unique ID
@@ -219,12 +256,102 @@ func (c *linuxContainer) Set(config configs.Config) error {
 			return err
 		}
 	}
+	if err := c.setProcesses(&config, c.config); err != nil {
+		// Set configs back, so that they are consistent with state.json.
+		if err2 := c.cgroupManager.Set(c.config.Cgroups.Resources); err2 != nil {
+			logrus.Warnf("Setting back cgroup configs failed due to error: %v, your state.json and actual configs might be inconsistent.", err2)
+		}
+		if c.intelRdtManager != nil {
+			if err2 := c.intelRdtManager.Set(c.config); err2 != nil {
+				logrus.Warnf("Setting back intelrdt configs failed due to error: %v, your state.json and actual configs might be inconsistent.", err2)
+			}
+		}
+		if err2 := c.setProcesses(c.config, &config); err2 != nil {
+			logrus.Warnf("Setting back process configs failed due to error: %v, your state.json and actual configs might be inconsistent.", err2)
+		}
+		return err
+	}
 	// After config setting succeed, update config and states
 	c.config = &config
 	_, err = c.updateState(nil)
 	return err
 }
 
+// setProcesses applies the rlimits, OOM score adjustment and scheduler of
+// config which differ from those of cur (the ones currently applied) to the
+// running processes of the container, as they are otherwise only set when a
+// process is started.
+func (c *linuxContainer) setProcesses(config, cur *configs.Config) error {
+	var rlimits []configs.Rlimit
+	for _, rl := range config.Rlimits {
+		changed := true
+		for _, r := range cur.Rlimits {
+			if r == rl {
+				changed = false
+				break
+			}
+		}
+		if changed {
+			rlimits = append(rlimits, rl)
+		}
+	}
+	oomScoreAdj := config.OomScoreAdj
+	if oomScoreAdj != nil && cur.OomScoreAdj != nil && *oomScoreAdj == *cur.OomScoreAdj {
+		oomScoreAdj = nil
+	}
+	sched := config.Scheduler
+	if sched != nil && cur.Scheduler != nil && *sched == *cur.Scheduler {
+		sched = nil
+	}
+	if len(rlimits) == 0 && oomScoreAdj == nil && sched == nil {
+		return nil
+	}
+
+	pids, err := c.cgroupManager.GetAllPids()
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		if err := setProcess(pid, rlimits, oomScoreAdj, sched); err != nil {
+			// The process may have exited meanwhile.
+			if errors.Is(err, unix.ESRCH) || errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("unable to update process %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+func setProcess(pid int, rlimits []configs.Rlimit, oomScoreAdj *int, sched *configs.Scheduler) error {
+	if err := setupRlimits(rlimits, pid); err != nil {
+		return err
+	}
+	if oomScoreAdj != nil {
+		path := "/proc/" + strconv.Itoa(pid) + "/oom_score_adj"
+		if err := ioutil.WriteFile(path, []byte(strconv.Itoa(*oomScoreAdj)), 0o644); err != nil {
+			return err
+		}
+	}
+	if sched != nil {
+		// The scheduling policy is per thread.
+		tasks, err := ioutil.ReadDir("/proc/" + strconv.Itoa(pid) + "/task")
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			tid, err := strconv.Atoi(task.Name())
+			if err != nil {
+				continue
+			}
+			if err := system.SchedSetscheduler(tid, sched.Policy, sched.Priority); err != nil && !errors.Is(err, unix.ESRCH) {
+				return fmt.Errorf("error setting the scheduler of thread %d: %w", tid, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (c *linuxContainer) Start(process *Process) error {
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -444,6 +571,47 @@ func (c *linuxContainer) includeExecFifo(cmd *exec.Cmd) error {
 	return nil
 }
 
+// includeIdmapMounts creates the id-mapped mounts requested by the config,
+// and passes them to the init process, with _LIBCONTAINER_MOUNT_FDS set to a
+// JSON array of their fd numbers (one per config.Mounts entry, -1 for mounts
+// which are not id-mapped). The returned files are to be closed by the caller
+// once the init process is started.
+func (c *linuxContainer) includeIdmapMounts(cmd *exec.Cmd) (_ []*os.File, retErr error) {
+	var files []*os.File
+	defer func() {
+		if retErr != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+		}
+	}()
+
+	mountFds := make([]int, len(c.config.Mounts))
+	for i, m := range c.config.Mounts {
+		mountFds[i] = -1
+		if !m.IsIDMapped() {
+			continue
+		}
+		f, err := idmappedMount(m)
+		if err != nil {
+			return nil, fmt.Errorf("error creating id-mapped mount for %s: %w", m.Destination, err)
+		}
+		files = append(files, f)
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+		mountFds[i] = stdioFdCount + len(cmd.ExtraFiles) - 1
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	mountFdsJSON, err := json.Marshal(mountFds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal mount fds: %w", err)
+	}
+	cmd.Env = append(cmd.Env, "_LIBCONTAINER_MOUNT_FDS="+string(mountFdsJSON))
+	return files, nil
+}
+
 func (c *linuxContainer) newParentProcess(p *Process) (parentProcess, error) {
 	parentInitPipe, childInitPipe, err := utils.NewSockPair("init")
 	if err != nil {
@@ -461,6 +629,9 @@ func (c *linuxContainer) newParentProcess(p *Process) (parentProcess, error) {
 	if !p.Init {
 		return c.newSetnsProcess(p, cmd, messageSockPair, logFilePair)
 	}
+	if len(p.SubCgroupPaths) > 0 {
+		return nil, errors.New("sub-cgroups can't be used for the init process")
+	}
 
 	// We only set up fifoFd if we're not doing a `runc exec`. The historic
 	// reason for this is that previously we would pass a dirfd that allowed
@@ -525,6 +696,10 @@ func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, messageSockPa
 	if err != nil {
 		return nil, err
 	}
+	mountFiles, err := c.includeIdmapMounts(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to setup id-mapped mounts: %w", err)
+	}
 	init := &initProcess{
 		cmd:             cmd,
 		messageSockPair: messageSockPair,
@@ -536,6 +711,7 @@ func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, messageSockPa
 		process:         p,
 		bootstrapData:   data,
 		sharePidns:      sharePidns,
+		mountFiles:      mountFiles,
 	}
 	c.initProcess = init
 	return init, nil
@@ -553,14 +729,23 @@ func (c *linuxContainer) newSetnsProcess(p *Process, cmd *exec.Cmd, messageSockP
 	if err != nil {
 		return nil, err
 	}
+	cgroupPaths, manager := state.CgroupPaths, c.cgroupManager
+	if len(p.SubCgroupPaths) > 0 {
+		manager, err = c.subCgroups(p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to set up sub-cgroups: %w", err)
+		}
+		cgroupPaths = manager.GetPaths()
+	}
 	return &setnsProcess{
 		cmd:             cmd,
-		cgroupPaths:     state.CgroupPaths,
+		cgroupPaths:     cgroupPaths,
+		subCgroups:      len(p.SubCgroupPaths) > 0,
 		rootlessCgroups: c.config.RootlessCgroups,
 		intelRdtPath:    state.IntelRdtPath,
 		messageSockPair: messageSockPair,
 		logFilePair:     logFilePair,
-		manager:         c.cgroupManager,
+		manager:         manager,
 		config:          c.newInitConfig(p),
 		process:         p,
 		bootstrapData:   data,
@@ -568,6 +753,29 @@ func (c *linuxContainer) newSetnsProcess(p *Process, cmd *exec.Cmd, messageSockP
 	}, nil
 }
 
+// subCgroups creates the sub-cgroups of p.SubCgroupPaths in the container's
+// cgroups if needed, through the container's cgroup manager, and sets their
+// resources. It returns the manager of the cgroups to start the process in.
+func (c *linuxContainer) subCgroups(p *Process) (cgroups.Manager, error) {
+	sm, ok := c.cgroupManager.(cgroups.SubManager)
+	if !ok {
+		return nil, errors.New("sub-cgroups are not supported by the cgroup manager")
+	}
+	for _, sub := range p.SubCgroupPaths {
+		if clean := filepath.Clean(sub); clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("invalid sub-cgroup %q: must be a path relative to the container's cgroup", sub)
+		}
+	}
+	var res *configs.Resources
+	if p.SubCgroupResources != nil {
+		r := *p.SubCgroupResources
+		// The devices of the container apply to its sub-cgroups.
+		r.SkipDevices = true
+		res = &r
+	}
+	return sm.NewSubManager(p.SubCgroupPaths, res)
+}
+
 func (c *linuxContainer) newInitConfig(process *Process) *initConfig {
 	cfg := &initConfig{
 		Config:           c.config,
@@ -652,15 +860,19 @@ func (c *linuxContainer) Resume() error {
 }
 
 func (c *linuxContainer) NotifyOOM() (<-chan struct{}, error) {
+	return c.NotifyOOMUntil(nil)
+}
+
+func (c *linuxContainer) NotifyOOMUntil(done <-chan struct{}) (<-chan struct{}, error) {
 	// XXX(cyphar): This requires cgroups.
 	if c.config.RootlessCgroups {
 		logrus.Warn("getting OOM notifications may fail if you don't have the full access to cgroups")
 	}
 	path := c.cgroupManager.Path("memory")
 	if cgroups.IsCgroup2UnifiedMode() {
-		return notifyOnOOMV2(path)
+		return notifyOnOOMV2(path, done)
 	}
-	return notifyOnOOM(path)
+	return notifyOnOOM(path, done)
 }
 
 func (c *linuxContainer) NotifyMemoryPressure(level PressureLevel) (<-chan struct{}, error) {
@@ -668,7 +880,26 @@ func (c *linuxContainer) NotifyMemoryPressure(level PressureLevel) (<-chan struc
 	if c.config.RootlessCgroups {
 		logrus.Warn("getting memory pressure notifications may fail if you don't have the full access to cgroups")
 	}
-	return notifyMemoryPressure(c.cgroupManager.Path("memory"), level)
+	path := c.cgroupManager.Path("memory")
+	if cgroups.IsCgroup2UnifiedMode() {
+		return notifyMemoryPressureV2(path, level)
+	}
+	return notifyMemoryPressure(path, level)
+}
+
+func (c *linuxContainer) NotifyMemoryEvents() (<-chan MemoryEvent, error) {
+	return c.NotifyMemoryEventsUntil(nil)
+}
+
+func (c *linuxContainer) NotifyMemoryEventsUntil(done <-chan struct{}) (<-chan MemoryEvent, error) {
+	if !cgroups.IsCgroup2UnifiedMode() {
+		return nil, errors.New("memory events notifications are only supported with cgroup v2")
+	}
+	// XXX(cyphar): This requires cgroups.
+	if c.config.RootlessCgroups {
+		logrus.Warn("getting memory events notifications may fail if you don't have the full access to cgroups")
+	}
+	return notifyMemoryEventsV2(c.cgroupManager.Path("memory"), done)
 }
 
 var criuFeatures *criurpc.CriuFeatures
@@ -676,6 +907,16 @@ var criuFeatures *criurpc.CriuFeatures
 func (c *linuxContainer) checkCriuFeatures(criuOpts *CriuOpts, rpcOpts *criurpc.CriuOpts, criuFeat *criurpc.CriuFeatures) error {
 	t := criurpc.CriuReqType_FEATURE_CHECK
 
+	// CRIU does not report whether it can run unprivileged, so
+	// check for it here, and mark rpcOpts (which the caller goes
+	// on to use for the actual request) accordingly.
+	if c.criuUnprivileged() {
+		if err := c.checkCriuUnprivileged(); err != nil {
+			return err
+		}
+		rpcOpts.Unprivileged = proto.Bool(true)
+	}
+
 	// make sure the features we are looking for are really not from
 	// some previous check
 	criuFeatures = nil
@@ -724,6 +965,56 @@ func (c *linuxContainer) checkCriuFeatures(criuOpts *CriuOpts, rpcOpts *criurpc.
 	return nil
 }
 
+// criuUnprivileged returns whether CRIU is to be run in its unprivileged
+// mode, i.e. without being (real) root.
+func (c *linuxContainer) criuUnprivileged() bool {
+	return os.Geteuid() != 0 || userns.RunningInUserNS()
+}
+
+// checkCriuUnprivileged checks that CRIU supports the unprivileged mode,
+// and that it gets CAP_CHECKPOINT_RESTORE (Linux 5.9+) when run, which
+// the unprivileged mode relies upon instead of CAP_SYS_ADMIN.
+func (c *linuxContainer) checkCriuUnprivileged() error {
+	if err := c.checkCriuVersion(31700); err != nil {
+		return fmt.Errorf("unable to run CRIU unprivileged: %w", err)
+	}
+	if capability.CAP_LAST_CAP < capability.CAP_CHECKPOINT_RESTORE {
+		return errors.New("unable to run CRIU unprivileged: the kernel does not support CAP_CHECKPOINT_RESTORE")
+	}
+	self, err := capability.NewPid2(0)
+	if err != nil {
+		return err
+	}
+	if err := self.Load(); err != nil {
+		return err
+	}
+	if !self.Get(capability.BOUNDING, capability.CAP_CHECKPOINT_RESTORE) {
+		return errors.New("unable to run CRIU unprivileged: CAP_CHECKPOINT_RESTORE is not in the bounding set")
+	}
+	// Root (in a user namespace) passes the capability on to CRIU,
+	// and so does an ambient capability.
+	if os.Geteuid() == 0 || self.Get(capability.AMBIENT, capability.CAP_CHECKPOINT_RESTORE) {
+		return nil
+	}
+	// Otherwise, the CRIU binary needs to have it as a file capability,
+	// as in "setcap cap_checkpoint_restore+eip /usr/sbin/criu".
+	criuPath, err := exec.LookPath(c.criuPath)
+	if err != nil {
+		return err
+	}
+	file, err := capability.NewFile2(criuPath)
+	if err != nil {
+		return err
+	}
+	if err := file.Load(); err != nil {
+		return fmt.Errorf("unable to read the capabilities of %s: %w", criuPath, err)
+	}
+	if !file.Get(capability.PERMITTED, capability.CAP_CHECKPOINT_RESTORE) {
+		return fmt.Errorf("unable to run CRIU unprivileged: %s does not have the CAP_CHECKPOINT_RESTORE file capability", criuPath)
+	}
+	return nil
+}
+
 func compareCriuVersion(criuVersion int, minVersion int) error {
 	// simple function to perform the actual version compare
 	if criuVersion < minVersion {
@@ -752,7 +1043,186 @@ func (c *linuxContainer) checkCriuVersion(minVersion int) error {
 	return compareCriuVersion(c.criuVersion, minVersion)
 }
 
-const descriptorsFilename = "descriptors.json"
+const (
+	descriptorsFilename     = "descriptors.json"
+	execDescriptorsFilename = "exec-descriptors.json"
+	containerFilename       = "container.json"
+)
+
+// criuContainer records the container a checkpoint was made of, to restore
+// it as a different container (with a different ID, or cgroups) if needed.
+type criuContainer struct {
+	ID          string            `json:"id"`
+	CgroupPaths map[string]string `json:"cgroup_paths,omitempty"`
+}
+
+// readCriuContainer reads the container recorded in the images directory, or
+// returns nil for a checkpoint made by an older runc, which did not record it.
+func readCriuContainer(imagesDir string) (*criuContainer, error) {
+	data, err := ioutil.ReadFile(filepath.Join(imagesDir, containerFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ct := &criuContainer{}
+	if err := json.Unmarshal(data, ct); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", containerFilename, err)
+	}
+	return ct, nil
+}
+
+// CheckpointedID returns the ID of the container checkpointed in imagesDir,
+// or "" for a checkpoint made by an older runc, which did not record it.
+func CheckpointedID(imagesDir string) (string, error) {
+	ct, err := readCriuContainer(imagesDir)
+	if err != nil || ct == nil {
+		return "", err
+	}
+	return ct.ID, nil
+}
+
+// criuExecProcess describes the stdio of a process of the container other
+// than the init, such as one started by "runc exec", for it to be restored.
+type criuExecProcess struct {
+	// Pid is the pid of the process in the container's pid namespace,
+	// which CRIU restores it with.
+	Pid int `json:"pid"`
+	// Descriptors are the targets of the stdio of the process, in the
+	// same form as in descriptors.json for the init.
+	Descriptors []string `json:"descriptors"`
+	// Pts is the index of the pseudo-terminal the stdio of the process is
+	// connected to, or -1.
+	Pts int `json:"pts"`
+}
+
+// criuExecProcesses returns the processes of the container which are children
+// of the init with their own stdio, as the processes started by "runc exec"
+// are once runc exits (and they are reparented to the init of the container's
+// pid namespace). As CRIU only dumps the init and its descendants, it fails
+// if there are other processes, such as the one of a "runc exec" which is
+// still running.
+func (c *linuxContainer) criuExecProcesses() ([]criuExecProcess, error) {
+	pids, err := c.cgroupManager.GetAllPids()
+	if err != nil {
+		// Rootless containers may have no cgroup to find them in.
+		if c.config.RootlessCgroups {
+			logrus.Debugf("unable to get the processes of the container: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Ints(pids)
+
+	parents := make(map[int]int, len(pids))
+	for _, pid := range pids {
+		stat, err := system.Stat(pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		parents[pid] = int(stat.PPID)
+	}
+
+	initPid := c.initProcess.pid()
+	initPts := -1
+	for i := 0; i < 3 && initPts == -1; i++ {
+		initPts = ptsIndex(initPid, i)
+	}
+	initFds := c.initProcess.externalDescriptors()
+
+	var procs []criuExecProcess
+	for _, pid := range pids {
+		ppid, ok := parents[pid]
+		if !ok || pid == initPid {
+			continue
+		}
+		for p := ppid; p != initPid; {
+			if p, ok = parents[p]; !ok {
+				return nil, fmt.Errorf("unable to checkpoint process %d: it is not a descendant of the container's init (such as the one of a runc exec which is still running)", pid)
+			}
+		}
+		if ppid != initPid {
+			// Its stdio is the one of (or comes from) its parent.
+			continue
+		}
+		fds, err := getPipeFds(pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if reflect.DeepEqual(fds, initFds) {
+			continue
+		}
+		nsPid, err := getNsPid(pid)
+		if err != nil {
+			return nil, err
+		}
+		pts := -1
+		for i := 0; i < len(fds) && pts == -1; i++ {
+			if pts = ptsIndex(pid, i); pts == initPts {
+				pts = -1
+			}
+		}
+		procs = append(procs, criuExecProcess{Pid: nsPid, Descriptors: fds, Pts: pts})
+	}
+	return procs, nil
+}
+
+// readCriuExecProcesses reads the processes written by criuExecProcesses to
+// the images directory, if any.
+func readCriuExecProcesses(imagesDir string) ([]criuExecProcess, error) {
+	data, err := ioutil.ReadFile(filepath.Join(imagesDir, execDescriptorsFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var procs []criuExecProcess
+	if err := json.Unmarshal(data, &procs); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", execDescriptorsFilename, err)
+	}
+	return procs, nil
+}
+
+// ptsIndex returns the index of the pseudo-terminal which the fd of the
+// process refers to, or -1 if it does not refer to one.
+func ptsIndex(pid, fd int) int {
+	var st unix.Stat_t
+	if err := unix.Stat(fmt.Sprintf("/proc/%d/fd/%d", pid, fd), &st); err != nil {
+		return -1
+	}
+	// The pseudo-terminal slaves are the devices with
+	// the majors 136 to 143 (UNIX98_PTY_SLAVE_MAJOR).
+	rdev := uint64(st.Rdev) //nolint:unconvert // Rdev is uint32 on e.g. MIPS.
+	major := unix.Major(rdev)
+	if st.Mode&unix.S_IFMT != unix.S_IFCHR || major < 136 || major > 143 {
+		return -1
+	}
+	return int((major-136)<<8 | unix.Minor(rdev))
+}
+
+// getNsPid returns the pid of the process in its own pid namespace.
+func getNsPid(pid int) (int, error) {
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if f := strings.Fields(line); len(f) > 1 && f[0] == "NSpid:" {
+			return strconv.Atoi(f[len(f)-1])
+		}
+	}
+	// Before Linux 4.1, there is no NSpid, and CRIU
+	// does not support nested pid namespaces either.
+	return pid, nil
+}
 
 func (c *linuxContainer) addCriuDumpMount(req *criurpc.CriuReq, m *configs.Mount) {
 	mountDest := strings.TrimPrefix(m.Destination, c.config.Rootfs)
@@ -877,7 +1347,6 @@ func (c *linuxContainer) handleRestoringNamespaces(rpcOpts *criurpc.CriuOpts, ex
 				// CRIU has no code to handle NEWCGROUP
 				return fmt.Errorf("Do not know how to handle namespace %v", ns.Type)
 			}
-			// CRIU has code to handle NEWTIME, but it does not seem to be defined in runc
 
 			// CRIU will issue a warning for NEWUSER:
 			// criu/namespaces.c: 'join-ns with user-namespace is not fully tested and dangerous'
@@ -926,17 +1395,112 @@ func (c *linuxContainer) Checkpoint(criuOpts *CriuOpts) error {
 	c.m.Lock()
 	defer c.m.Unlock()
 
-	// Checkpoint is unlikely to work if os.Geteuid() != 0 || system.RunningInUserNS().
-	// (CLI prints a warning)
-	// TODO(avagin): Figure out how to make this work nicely. CRIU 2.0 has
-	//               support for doing unprivileged dumps, but the setup of
-	//               rootless containers might make this complicated.
+	if criuOpts.PreDumpIterations == 0 || criuOpts.PreDump {
+		return c.checkpoint(criuOpts)
+	}
+	if criuOpts.ImagesDirectory == "" {
+		return errors.New("invalid directory to save checkpoint")
+	}
+	if err := os.Mkdir(criuOpts.ImagesDirectory, 0o700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	// The parent images are relative to the images directory, and the
+	// pre-dumps are in its subdirectories.
+	parent := ""
+	if criuOpts.ParentImage != "" {
+		parent = filepath.Join("..", criuOpts.ParentImage)
+	}
+	for i := 1; i <= criuOpts.PreDumpIterations; i++ {
+		dir := fmt.Sprintf("pre-dump-%d", i)
+		opts := *criuOpts
+		opts.ImagesDirectory = filepath.Join(criuOpts.ImagesDirectory, dir)
+		opts.ParentImage = parent
+		opts.PreDump = true
+		if err := c.checkpoint(&opts); err != nil {
+			return fmt.Errorf("pre-dump %d: %w", i, err)
+		}
+		stats, err := readCriuDumpStats(opts.ImagesDirectory)
+		if err != nil {
+			return fmt.Errorf("pre-dump %d: %w", i, err)
+		}
+		stats.Iteration = i
+		if criuOpts.PreDumpProgress != nil {
+			criuOpts.PreDumpProgress(*stats)
+		}
+		parent = filepath.Join("..", dir)
+		if stats.PagesWritten <= criuOpts.PreDumpConvergence {
+			break
+		}
+	}
+	opts := *criuOpts
+	opts.ParentImage = filepath.Base(parent)
+	if err := c.checkpoint(&opts); err != nil {
+		return err
+	}
+	if criuOpts.PreDumpProgress != nil {
+		stats, err := readCriuDumpStats(opts.ImagesDirectory)
+		if err != nil {
+			return err
+		}
+		criuOpts.PreDumpProgress(*stats)
+	}
+	return nil
+}
+
+// readCriuDumpStats reads the statistics of the (pre-)dump in dir.
+func readCriuDumpStats(dir string) (*CriuDumpStats, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "stats-dump"))
+	if err != nil {
+		return nil, err
+	}
+	// The image starts with two magic numbers, and the size of the entry.
+	if len(buf) < 12 {
+		return nil, errors.New("invalid CRIU stats-dump image")
+	}
+	if magic := binary.LittleEndian.Uint32(buf[0:4]); magic != criustats.ImgServiceMagic {
+		return nil, fmt.Errorf("invalid CRIU stats-dump image: image magic %#x, expected %#x", magic, criustats.ImgServiceMagic)
+	}
+	if magic := binary.LittleEndian.Uint32(buf[4:8]); magic != criustats.StatsMagic {
+		return nil, fmt.Errorf("invalid CRIU stats-dump image: stats magic %#x, expected %#x", magic, criustats.StatsMagic)
+	}
+	size := int(binary.LittleEndian.Uint32(buf[8:12]))
+	if len(buf) < 12+size {
+		return nil, errors.New("invalid CRIU stats-dump image")
+	}
+	st := &criustats.StatsEntry{}
+	if err := proto.Unmarshal(buf[12:12+size], st); err != nil {
+		return nil, fmt.Errorf("invalid CRIU stats-dump image: %w", err)
+	}
+	d := st.GetDump()
+	return &CriuDumpStats{
+		PagesScanned:       d.GetPagesScanned(),
+		PagesSkippedParent: d.GetPagesSkippedParent(),
+		PagesWritten:       d.GetPagesWritten(),
+		FreezingTime:       d.GetFreezingTime(),
+		FrozenTime:         d.GetFrozenTime(),
+		MemdumpTime:        d.GetMemdumpTime(),
+		MemwriteTime:       d.GetMemwriteTime(),
+	}, nil
+}
+
+func (c *linuxContainer) checkpoint(criuOpts *CriuOpts) error {
+	// If os.Geteuid() != 0 || userns.RunningInUserNS(), CRIU is run in its
+	// unprivileged mode (see checkCriuFeatures), which needs CRIU 3.17+ and
+	// CAP_CHECKPOINT_RESTORE.
 
 	// We are relying on the CRIU version RPC which was introduced with CRIU 3.0.0
 	if err := c.checkCriuVersion(30000); err != nil {
 		return err
 	}
 
+	// CRIU dumps the time namespace offsets (and restores them adjusted
+	// so that the monotonic and boottime clocks do not jump) since 3.14.
+	if c.config.Namespaces.Contains(configs.NEWTIME) {
+		if err := c.checkCriuVersion(31400); err != nil {
+			return fmt.Errorf("unable to checkpoint a container with a time namespace: %w", err)
+		}
+	}
+
 	if criuOpts.ImagesDirectory == "" {
 		return errors.New("invalid directory to save checkpoint")
 	}
@@ -1006,7 +1570,11 @@ func (c *linuxContainer) Checkpoint(criuOpts *CriuOpts) error {
 	// is not set, CRIU uses ptrace() to pause the processes.
 	// Note cgroup v2 freezer is only supported since CRIU release 3.14.
 	if !cgroups.IsCgroup2UnifiedMode() || c.checkCriuVersion(31400) == nil {
-		if fcg := c.cgroupManager.Path("freezer"); fcg != "" {
+		// Rootless containers may have a cgroup which is not delegated
+		// to the user (and so can't be frozen), in which case leave it
+		// to ptrace() as well.
+		if fcg := c.cgroupManager.Path("freezer"); fcg != "" &&
+			(!c.config.RootlessCgroups || unix.Access(fcg, unix.W_OK) == nil) {
 			rpcOpts.FreezeCgroup = proto.String(fcg)
 		}
 	}
@@ -1031,6 +1599,14 @@ func (c *linuxContainer) Checkpoint(criuOpts *CriuOpts) error {
 		rpcOpts.ManageCgroupsMode = &mode
 	}
 
+	// Even with no features to check for, checkCriuFeatures
+	// makes sure that CRIU can run unprivileged, if it has to.
+	if c.criuUnprivileged() && !criuOpts.PreDump && !criuOpts.LazyPages {
+		if err := c.checkCriuFeatures(criuOpts, &rpcOpts, &criurpc.CriuFeatures{}); err != nil {
+			return err
+		}
+	}
+
 	var t criurpc.CriuReqType
 	if criuOpts.PreDump {
 		feat := criurpc.CriuFeatures{
@@ -1121,6 +1697,32 @@ func (c *linuxContainer) Checkpoint(criuOpts *CriuOpts) error {
 		if err != nil {
 			return err
 		}
+
+		// And the ones of the other processes, such as exec'd ones.
+		execProcs, err := c.criuExecProcesses()
+		if err != nil {
+			return err
+		}
+		if len(execProcs) > 0 {
+			execJSON, err := json.Marshal(execProcs)
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filepath.Join(criuOpts.ImagesDirectory, execDescriptorsFilename), execJSON, 0o600)
+			if err != nil {
+				return err
+			}
+		}
+
+		// And the container itself.
+		ctJSON, err := json.Marshal(criuContainer{ID: c.id, CgroupPaths: c.cgroupManager.GetPaths()})
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(criuOpts.ImagesDirectory, containerFilename), ctJSON, 0o600)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = c.criuSwrk(nil, req, criuOpts, nil)
@@ -1269,24 +1871,75 @@ func (c *linuxContainer) prepareCriuRestoreMounts(mounts []*configs.Mount) error
 	return nil
 }
 
+// remapRestoreConfig applies the mount source and namespace path mappings of
+// criuOpts to the configuration of the container, which is then used for the
+// restore, and saved in its state.
+func (c *linuxContainer) remapRestoreConfig(criuOpts *CriuOpts) error {
+	mapped := make(map[string]bool)
+	for _, m := range c.config.Mounts {
+		if m.Device != "bind" {
+			continue
+		}
+		// The longest of the matching paths is used.
+		from := ""
+		for p := range criuOpts.MountSources {
+			if len(p) > len(from) && (m.Source == p || strings.HasPrefix(m.Source, strings.TrimSuffix(p, "/")+"/")) {
+				from = p
+			}
+		}
+		if from == "" {
+			continue
+		}
+		m.Source = filepath.Join(criuOpts.MountSources[from], strings.TrimPrefix(m.Source, from))
+		mapped[from] = true
+	}
+	for p := range criuOpts.MountSources {
+		if !mapped[p] {
+			return fmt.Errorf("unable to remap the mount source %s: no bind mount from it", p)
+		}
+	}
+	for t, path := range criuOpts.NamespacePaths {
+		i := -1
+		for j, ns := range c.config.Namespaces {
+			if ns.Type == t && ns.Path != "" {
+				i = j
+			}
+		}
+		if i == -1 {
+			return fmt.Errorf("unable to remap the %s namespace: the container was not configured with a path to it", configs.NsName(t))
+		}
+		c.config.Namespaces[i].Path = path
+	}
+	return nil
+}
+
 func (c *linuxContainer) Restore(process *Process, criuOpts *CriuOpts) error {
 	c.m.Lock()
 	defer c.m.Unlock()
 
 	var extraFiles []*os.File
 
-	// Restore is unlikely to work if os.Geteuid() != 0 || system.RunningInUserNS().
-	// (CLI prints a warning)
-	// TODO(avagin): Figure out how to make this work nicely. CRIU doesn't have
-	//               support for unprivileged restore at the moment.
+	// If os.Geteuid() != 0 || userns.RunningInUserNS(), CRIU is run in its
+	// unprivileged mode (see checkCriuFeatures), which needs CRIU 3.17+ and
+	// CAP_CHECKPOINT_RESTORE. In addition, runc needs to be able to bind
+	// mount the rootfs below, so it has to be run in a user namespace
+	// (such as with rootlesskit) rather than by an unprivileged user.
 
 	// We are relying on the CRIU version RPC which was introduced with CRIU 3.0.0
 	if err := c.checkCriuVersion(30000); err != nil {
 		return err
 	}
+	if c.config.Namespaces.Contains(configs.NEWTIME) {
+		if err := c.checkCriuVersion(31400); err != nil {
+			return fmt.Errorf("unable to restore a container with a time namespace: %w", err)
+		}
+	}
 	if criuOpts.ImagesDirectory == "" {
 		return errors.New("invalid directory to restore checkpoint")
 	}
+	if err := c.remapRestoreConfig(criuOpts); err != nil {
+		return err
+	}
 	imageDir, err := os.Open(criuOpts.ImagesDirectory)
 	if err != nil {
 		return err
@@ -1308,6 +1961,9 @@ func (c *linuxContainer) Restore(process *Process, criuOpts *CriuOpts) error {
 	}
 	err = mount(c.config.Rootfs, root, "", "", unix.MS_BIND|unix.MS_REC, "")
 	if err != nil {
+		if errors.Is(err, unix.EPERM) && !userns.RunningInUserNS() {
+			return fmt.Errorf("%w (restoring as an unprivileged user requires running runc in a user namespace)", err)
+		}
 		return err
 	}
 	defer unix.Unmount(root, unix.MNT_DETACH) //nolint: errcheck
@@ -1358,6 +2014,12 @@ func (c *linuxContainer) Restore(process *Process, criuOpts *CriuOpts) error {
 	}
 	c.handleCriuConfigurationFile(req.Opts)
 
+	if c.criuUnprivileged() {
+		if err := c.checkCriuFeatures(criuOpts, req.Opts, &criurpc.CriuFeatures{}); err != nil {
+			return err
+		}
+	}
+
 	if err := c.handleRestoringNamespaces(req.Opts, &extraFiles); err != nil {
 		return err
 	}
@@ -1419,31 +2081,83 @@ func (c *linuxContainer) Restore(process *Process, criuOpts *CriuOpts) error {
 	if err := json.Unmarshal(fdJSON, &fds); err != nil {
 		return err
 	}
+	inherited := make(map[string]bool)
 	for i := range fds {
 		if s := fds[i]; strings.Contains(s, "pipe:") {
 			inheritFd := new(criurpc.InheritFd)
 			inheritFd.Key = proto.String(s)
 			inheritFd.Fd = proto.Int32(int32(i))
 			req.Opts.InheritFd = append(req.Opts.InheritFd, inheritFd)
+			inherited[s] = true
 		}
 	}
+
+	// The files of the caller are not closed below.
+	openedFiles := len(extraFiles)
+	if err := c.restoreExecStdio(req, criuOpts, &extraFiles, inherited); err != nil {
+		return err
+	}
+
 	err = c.criuSwrk(process, req, criuOpts, extraFiles)
 
 	// Now that CRIU is done let's close all opened FDs CRIU needed.
-	for _, fd := range extraFiles {
+	for _, fd := range extraFiles[:openedFiles] {
 		fd.Close()
 	}
 
 	return err
 }
 
-func (c *linuxContainer) criuApplyCgroups(pid int, req *criurpc.CriuReq) error {
+// restoreExecStdio reconnects the stdio of the processes of the container
+// other than the init to the files given in criuOpts.ExecStdio, and checks
+// that the ptys of the processes can be sent to the exec console socket.
+func (c *linuxContainer) restoreExecStdio(req *criurpc.CriuReq, criuOpts *CriuOpts, extraFiles *[]*os.File, inherited map[string]bool) error {
+	procs, err := readCriuExecProcesses(criuOpts.ImagesDirectory)
+	if err != nil {
+		return err
+	}
+	found := make(map[int]bool, len(procs))
+	for _, p := range procs {
+		found[p.Pid] = true
+		if p.Pts != -1 && criuOpts.ExecConsoleSocket == "" {
+			return fmt.Errorf("unable to restore the terminal of process %d without an exec console socket", p.Pid)
+		}
+		for i, f := range criuOpts.ExecStdio[p.Pid] {
+			if f == nil {
+				continue
+			}
+			if i >= len(p.Descriptors) || !strings.Contains(p.Descriptors[i], "pipe:") {
+				return fmt.Errorf("unable to reconnect fd %d of process %d: it is not a pipe", i, p.Pid)
+			}
+			s := p.Descriptors[i]
+			if inherited[s] {
+				continue
+			}
+			// CRIU gets the files after its socket, as fd 4 and on.
+			req.Opts.InheritFd = append(req.Opts.InheritFd, &criurpc.InheritFd{
+				Key: proto.String(s),
+				Fd:  proto.Int32(int32(4 + len(*extraFiles))),
+			})
+			*extraFiles = append(*extraFiles, f)
+			inherited[s] = true
+		}
+	}
+	for pid := range criuOpts.ExecStdio {
+		if !found[pid] {
+			return fmt.Errorf("unable to reconnect the stdio of process %d: no such process in the checkpoint", pid)
+		}
+	}
+	return nil
+}
+
+func (c *linuxContainer) criuApplyCgroups(pid int, req *criurpc.CriuReq, opts *CriuOpts) error {
 	// need to apply cgroups only on restore
 	if req.GetType() != criurpc.CriuReqType_RESTORE {
 		return nil
 	}
 
-	// XXX: Do we need to deal with this case? AFAIK criu still requires root.
+	// For rootless containers, the cgroup managers ignore the errors
+	// caused by the cgroups not being delegated to the user.
 	if err := c.cgroupManager.Apply(pid); err != nil {
 		return err
 	}
@@ -1452,11 +2166,32 @@ func (c *linuxContainer) criuApplyCgroups(pid int, req *criurpc.CriuReq) error {
 		return err
 	}
 
-	if cgroups.IsCgroup2UnifiedMode() {
+	// If so, the container has no cgroup of its own, and CRIU can't
+	// create the ones of the checkpoint either, so it has to leave the
+	// processes in its own cgroup (that of the user). With a delegated
+	// cgroup (such as a systemd user slice) the cgroups are restored.
+	if c.config.RootlessCgroups && !c.cgroupManager.Exists() {
+		if req.Opts.ManageCgroupsMode == nil {
+			mode := criurpc.CriuCgMode_IGNORE
+			req.Opts.ManageCgroupsMode = &mode
+		}
 		return nil
 	}
-	// the stuff below is cgroupv1-specific
 
+	if cgroups.IsCgroup2UnifiedMode() {
+		// CRIU restores the processes in the cgroup they were
+		// checkpointed in, unless it is remapped (see below).
+		remap, err := c.criuCgroupRemapped(opts)
+		if err != nil || !remap {
+			return err
+		}
+	}
+
+	// CRIU restores the processes in the cgroups they were checkpointed in,
+	// relative to the cgroup roots given here, which are the container's
+	// cgroups. With cgroup v2, they are only given if they are different
+	// from the ones of the checkpoint, such as when the container is
+	// restored with a different ID.
 	path := fmt.Sprintf("/proc/%d/cgroup", pid)
 	cgroupsPaths, err := cgroups.ParseCgroupFile(path)
 	if err != nil {
@@ -1468,12 +2203,36 @@ func (c *linuxContainer) criuApplyCgroups(pid int, req *criurpc.CriuReq) error {
 			Ctrl: proto.String(c),
 			Path: proto.String(p),
 		}
+		// For cgroup v2, the root is set for all the controllers.
+		if cgroups.IsCgroup2UnifiedMode() {
+			cgroupRoot.Ctrl = nil
+		}
 		req.Opts.CgRoot = append(req.Opts.CgRoot, cgroupRoot)
 	}
 
 	return nil
 }
 
+// criuCgroupRemapped returns whether the container is restored in different
+// cgroups than the ones it was checkpointed in, as recorded in the images
+// directory of opts.
+func (c *linuxContainer) criuCgroupRemapped(opts *CriuOpts) (bool, error) {
+	if opts == nil {
+		return false, nil
+	}
+	ct, err := readCriuContainer(opts.ImagesDirectory)
+	if err != nil || ct == nil {
+		return false, err
+	}
+	paths := c.cgroupManager.GetPaths()
+	for k, p := range ct.CgroupPaths {
+		if paths[k] != p {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (c *linuxContainer) criuSwrk(process *Process, req *criurpc.CriuReq, opts *CriuOpts, extraFiles []*os.File) error {
 	fds, err := unix.Socketpair(unix.AF_LOCAL, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
 	if err != nil {
@@ -1537,7 +2296,7 @@ func (c *linuxContainer) criuSwrk(process *Process, req *criurpc.CriuReq, opts *
 		}
 	}()
 
-	if err := c.criuApplyCgroups(criuProcess.Pid, req); err != nil {
+	if err := c.criuApplyCgroups(criuProcess.Pid, req, opts); err != nil {
 		return err
 	}
 
@@ -1770,6 +2529,19 @@ func (c *linuxContainer) criuNotifications(resp *criurpc.CriuResp, process *Proc
 			return err
 		}
 
+		// The ptys of the processes other than the init are sent
+		// to the exec console socket, each over its own connection.
+		pid, err := c.criuPtsProcess(opts.ImagesDirectory, fds[0])
+		if err != nil {
+			_ = unix.Close(fds[0])
+			return err
+		}
+		if pid != 0 {
+			master := os.NewFile(uintptr(fds[0]), "exec:"+strconv.Itoa(pid))
+			defer master.Close()
+			return sendExecConsole(opts.ExecConsoleSocket, master)
+		}
+
 		master := os.NewFile(uintptr(fds[0]), "orphan-pts-master")
 		defer master.Close()
 
@@ -1791,6 +2563,53 @@ func (c *linuxContainer) criuNotifications(resp *criurpc.CriuResp, process *Proc
 	return nil
 }
 
+// execConsoleTimeout is how long sendExecConsole waits for the exec console
+// socket, as CRIU is blocked meanwhile.
+const execConsoleTimeout = 10 * time.Second
+
+// sendExecConsole sends the pty master of an exec'd process to the socket
+// at sockpath.
+func sendExecConsole(sockpath string, master *os.File) error {
+	if len(master.Name()) >= utils.MaxNameLen {
+		return fmt.Errorf("sendfd: filename too long: %s", master.Name())
+	}
+	conn, err := net.DialTimeout("unix", sockpath, execConsoleTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("casting to UnixConn failed")
+	}
+	// Unlike utils.SendFd, this is subject to the deadline.
+	if err := uc.SetDeadline(time.Now().Add(execConsoleTimeout)); err != nil {
+		return err
+	}
+	_, _, err = uc.WriteMsgUnix([]byte(master.Name()), unix.UnixRights(int(master.Fd())), nil)
+	return err
+}
+
+// criuPtsProcess returns the pid of the process other than the init which
+// the pty re-created by CRIU with the given master belongs to, if any.
+func (c *linuxContainer) criuPtsProcess(imagesDir string, master int) (int, error) {
+	procs, err := readCriuExecProcesses(imagesDir)
+	if err != nil || len(procs) == 0 {
+		return 0, err
+	}
+	// CRIU re-creates the ptys with the same indexes.
+	index, err := unix.IoctlGetUint32(master, unix.TIOCGPTN)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get the index of the pty: %w", err)
+	}
+	for _, p := range procs {
+		if p.Pts == int(index) {
+			return p.Pid, nil
+		}
+	}
+	return 0, nil
+}
+
 func (c *linuxContainer) updateState(process parentProcess) (*State, error) {
 	if process != nil {
 		c.initProcess = process
@@ -1806,7 +2625,81 @@ func (c *linuxContainer) updateState(process parentProcess) (*State, error) {
 	return state, nil
 }
 
-func (c *linuxContainer) saveState(s *State) (retErr error) {
+func (c *linuxContainer) saveState(s *State) error {
+	lock, err := c.lockState()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	// Once recorded (see recordExitStatus), the exit status is kept, the
+	// state being written from a container loaded before it was.
+	if s.ExitStatus == nil {
+		if old, err := readState(c.root); err == nil && old.ExitStatus != nil && sameInitProcess(&old.BaseState, &s.BaseState) {
+			s.ExitStatus = old.ExitStatus
+			c.exitStatus = old.ExitStatus
+		}
+	}
+	return c.writeState(s)
+}
+
+// recordExitStatus records the exit status of the init process, described by
+// initState, in the state of the container, leaving the rest of it as it is on
+// disk, as it may have been changed by another process (such as by "runc
+// update") since we loaded the container.
+func (c *linuxContainer) recordExitStatus(initState *BaseState, status *ExitStatus) error {
+	lock, err := c.lockState()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	s, err := readState(c.root)
+	if err != nil {
+		return err
+	}
+	// The container may have been destroyed and created again.
+	if !sameInitProcess(&s.BaseState, initState) {
+		return nil
+	}
+	s.ExitStatus = status
+	return c.writeState(s)
+}
+
+// sameInitProcess returns whether a and b are the states of the same init
+// process.
+func sameInitProcess(a, b *BaseState) bool {
+	return a.InitProcessPid == b.InitProcessPid && a.InitProcessStartTime == b.InitProcessStartTime
+}
+
+// lockState takes the lock on the state of the container, which is held
+// while it is written. It is released by closing the returned file.
+func (c *linuxContainer) lockState() (*os.File, error) {
+	path := filepath.Join(c.root, stateLockFilename)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: path, Err: err}
+	}
+	return f, nil
+}
+
+// readState reads the state of the container at root.
+func readState(root string) (*State, error) {
+	f, err := os.Open(filepath.Join(root, stateFilename))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var s *State
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *linuxContainer) writeState(s *State) (retErr error) {
 	tmpFile, err := ioutil.TempFile(c.root, "state-")
 	if err != nil {
 		return err
@@ -1892,12 +2785,14 @@ func (c *linuxContainer) isPaused() (bool, error) {
 func (c *linuxContainer) currentState() (*State, error) {
 	var (
 		startTime           uint64
+		pidfdInode          uint64
 		externalDescriptors []string
 		pid                 = -1
 	)
 	if c.initProcess != nil {
 		pid = c.initProcess.pid()
 		startTime, _ = c.initProcess.startTime()
+		pidfdInode = c.initProcess.pidfdInode()
 		externalDescriptors = c.initProcess.externalDescriptors()
 	}
 
@@ -1918,6 +2813,9 @@ func (c *linuxContainer) currentState() (*State, error) {
 		IntelRdtPath:        intelRdtPath,
 		NamespacePaths:      make(map[configs.NamespaceType]string),
 		ExternalDescriptors: externalDescriptors,
+
+		InitProcessPidfdInode: pidfdInode,
+		ExitStatus:            c.exitStatus,
 	}
 	if pid > 0 {
 		for _, ns := range c.config.Namespaces {
@@ -2001,6 +2899,35 @@ func encodeIDMapping(idMap []configs.IDMap) ([]byte, error) {
 	return data.Bytes(), nil
 }
 
+// encodeTimeOffsets encodes the time namespace offsets in the format expected
+// by /proc/self/timens_offsets, using the numeric clock IDs which are
+// understood by all kernels supporting time namespaces.
+func encodeTimeOffsets(offsets map[string]specs.LinuxTimeOffset) ([]byte, error) {
+	clocks := map[string]int{
+		"monotonic": unix.CLOCK_MONOTONIC,
+		"boottime":  unix.CLOCK_BOOTTIME,
+	}
+	names := make([]string, 0, len(offsets))
+	for name := range offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := bytes.NewBuffer(nil)
+	for _, name := range names {
+		clock, ok := clocks[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown time namespace clock %q", name)
+		}
+		offset := offsets[name]
+		line := fmt.Sprintf("%d %d %d\n", clock, offset.Secs, offset.Nanosecs)
+		if _, err := data.WriteString(line); err != nil {
+			return nil, err
+		}
+	}
+	return data.Bytes(), nil
+}
+
 // bootstrapData encodes the necessary data in netlink binary format
 // as a io.Reader.
 // Consumer can write the data to a bootstrap program
@@ -2089,6 +3016,18 @@ func (c *linuxContainer) bootstrapData(cloneFlags uintptr, nsMaps map[configs.Na
 		Value: c.config.RootlessEUID,
 	})
 
+	// write the time namespace offsets, only when creating a new time ns
+	if cloneFlags&unix.CLONE_NEWTIME != 0 && len(c.config.TimeOffsets) > 0 {
+		b, err := encodeTimeOffsets(c.config.TimeOffsets)
+		if err != nil {
+			return nil, err
+		}
+		r.AddData(&Bytemsg{
+			Type:  TimeOffsetsAttr,
+			Value: b,
+		})
+	}
+
 	return bytes.NewReader(r.Serialize()), nil
 }
 
@@ -1,14 +1,28 @@
 package libcontainer
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	criurpc "github.com/checkpoint-restore/go-criu/v7/rpc"
+	criustats "github.com/checkpoint-restore/go-criu/v7/stats"
+	"github.com/containerd/console"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
 	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"golang.org/x/sys/unix"
+	"google.golang.org/protobuf/proto"
 )
 
 type mockCgroupManager struct {
@@ -16,6 +30,8 @@ type mockCgroupManager struct {
 	allPids []int
 	stats   *cgroups.Stats
 	paths   map[string]string
+	// cgroupFDPath, if set, is opened by CgroupFD.
+	cgroupFDPath string
 }
 
 type mockIntelRdtManager struct {
@@ -52,6 +68,13 @@ func (m *mockCgroupManager) Exists() bool {
 	return err == nil
 }
 
+func (m *mockCgroupManager) CgroupFD() (*os.File, error) {
+	if m.cgroupFDPath == "" {
+		return nil, cgroups.ErrNoCgroupFD
+	}
+	return cgroups.OpenCgroupFD(m.cgroupFDPath)
+}
+
 func (m *mockCgroupManager) OOMKillCount() (uint64, error) {
 	return 0, nil
 }
@@ -129,6 +152,14 @@ func (m *mockProcess) signal(_ os.Signal) error {
 	return nil
 }
 
+func (m *mockProcess) pidfd() (*os.File, error) {
+	return nil, errPidfdUnavailable
+}
+
+func (m *mockProcess) pidfdInode() uint64 {
+	return 0
+}
+
 func (m *mockProcess) externalDescriptors() []string {
 	return []string{}
 }
@@ -390,3 +421,449 @@ func TestGetContainerStateAfterUpdate(t *testing.T) {
 		t.Fatalf("expected Memory to be 2048 but received %q", state.Config.Cgroups.Memory)
 	}
 }
+
+func TestSetUpdatesProcesses(t *testing.T) {
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	stat, err := system.Stat(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container := &linuxContainer{
+		root: t.TempDir(),
+		id:   "myid",
+		config: &configs.Config{
+			Cgroups: &configs.Cgroup{
+				Resources: &configs.Resources{},
+			},
+		},
+		initProcess: &mockProcess{
+			_pid:    pid,
+			started: stat.StartTime,
+		},
+		initProcessStartTime: stat.StartTime,
+		cgroupManager:        &mockCgroupManager{allPids: []int{pid}},
+	}
+	container.state = &runningState{c: container}
+
+	adj := 123
+	newConfig := container.Config()
+	newConfig.Rlimits = []configs.Rlimit{{Type: unix.RLIMIT_CORE, Soft: 1024, Hard: 2048}}
+	newConfig.OomScoreAdj = &adj
+	newConfig.Scheduler = &configs.Scheduler{Policy: system.SCHED_BATCH}
+	if err := container.Set(newConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	limits, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/limits", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(limits), "\n") {
+		if strings.HasPrefix(line, "Max core file size") {
+			if fields := strings.Fields(line); fields[4] != "1024" || fields[5] != "2048" {
+				t.Errorf("expected core rlimit 1024:2048, got %s:%s", fields[4], fields[5])
+			}
+		}
+	}
+	oomScoreAdj, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(oomScoreAdj)); got != "123" {
+		t.Errorf("expected oom_score_adj 123, got %s", got)
+	}
+	procStat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The policy is the 41st field, and the 39th after the command name.
+	fields := strings.Fields(string(procStat[bytes.LastIndexByte(procStat, ')')+1:]))
+	if fields[38] != strconv.Itoa(system.SCHED_BATCH) {
+		t.Errorf("expected scheduling policy %d, got %s", system.SCHED_BATCH, fields[38])
+	}
+	if container.config.OomScoreAdj == nil || *container.config.OomScoreAdj != adj {
+		t.Error("expected the config to be updated")
+	}
+}
+
+func TestSetRollsBackProcesses(t *testing.T) {
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	stat, err := system.Stat(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oomScoreAdjPath := fmt.Sprintf("/proc/%d/oom_score_adj", pid)
+	oomScoreAdj, err := ioutil.ReadFile(oomScoreAdjPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adj, err := strconv.Atoi(strings.TrimSpace(string(oomScoreAdj)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	container := &linuxContainer{
+		root: t.TempDir(),
+		id:   "myid",
+		config: &configs.Config{
+			Cgroups: &configs.Cgroup{
+				Resources: &configs.Resources{},
+			},
+			OomScoreAdj: &adj,
+		},
+		initProcess: &mockProcess{
+			_pid:    pid,
+			started: stat.StartTime,
+		},
+		initProcessStartTime: stat.StartTime,
+		cgroupManager:        &mockCgroupManager{allPids: []int{pid}},
+	}
+	container.state = &runningState{c: container}
+
+	// The OOM score adjustment is set before the (invalid) scheduler.
+	newAdj := adj + 1
+	newConfig := container.Config()
+	newConfig.OomScoreAdj = &newAdj
+	newConfig.Scheduler = &configs.Scheduler{Policy: -1}
+	if err := container.Set(newConfig); err == nil {
+		t.Fatal("expected an error for an invalid scheduler")
+	}
+
+	oomScoreAdj, err = ioutil.ReadFile(oomScoreAdjPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(oomScoreAdj)); got != strconv.Itoa(adj) {
+		t.Errorf("expected oom_score_adj %d to be set back, got %s", adj, got)
+	}
+	if *container.config.OomScoreAdj != adj || container.config.Scheduler != nil {
+		t.Error("expected the config not to be updated")
+	}
+}
+
+func TestSendExecConsole(t *testing.T) {
+	sockpath := filepath.Join(t.TempDir(), "exec.sock")
+	l, err := net.Listen("unix", sockpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[1])
+	master := os.NewFile(uintptr(fds[0]), "exec:123")
+	defer master.Close()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- sendExecConsole(sockpath, master)
+	}()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	socket, err := conn.(*net.UnixConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	f, err := utils.RecvFd(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "exec:123" {
+		t.Errorf("expected the name exec:123, got %s", f.Name())
+	}
+}
+
+func TestReadCriuDumpStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criu-stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	entry, err := proto.Marshal(&criustats.StatsEntry{
+		Dump: &criustats.DumpStatsEntry{
+			FreezingTime:       proto.Uint32(10),
+			FrozenTime:         proto.Uint32(20),
+			MemdumpTime:        proto.Uint32(30),
+			MemwriteTime:       proto.Uint32(40),
+			PagesScanned:       proto.Uint64(1000),
+			PagesSkippedParent: proto.Uint64(900),
+			PagesWritten:       proto.Uint64(100),
+			PagesLazy:          proto.Uint64(0),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The magic numbers of the image, then the size of the entry.
+	img := make([]byte, 12, 12+len(entry))
+	binary.LittleEndian.PutUint32(img[0:4], criustats.ImgServiceMagic)
+	binary.LittleEndian.PutUint32(img[4:8], criustats.StatsMagic)
+	binary.LittleEndian.PutUint32(img[8:12], uint32(len(entry)))
+	img = append(img, entry...)
+	if err := ioutil.WriteFile(filepath.Join(dir, "stats-dump"), img, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := readCriuDumpStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CriuDumpStats{
+		PagesScanned:       1000,
+		PagesSkippedParent: 900,
+		PagesWritten:       100,
+		FreezingTime:       10,
+		FrozenTime:         20,
+		MemdumpTime:        30,
+		MemwriteTime:       40,
+	}
+	if *stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, *stats)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "stats-dump"), img[:16], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCriuDumpStats(dir); err == nil {
+		t.Error("expected an error for a truncated image")
+	}
+
+	// Another image, such as the inventory (with no service magic).
+	for i, magics := range [][2]uint32{{0x58313116, criustats.StatsMagic}, {criustats.ImgServiceMagic, 0x58313116}} {
+		bad := append([]byte(nil), img...)
+		binary.LittleEndian.PutUint32(bad[0:4], magics[0])
+		binary.LittleEndian.PutUint32(bad[4:8], magics[1])
+		if err := ioutil.WriteFile(filepath.Join(dir, "stats-dump"), bad, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readCriuDumpStats(dir); err == nil || !strings.Contains(err.Error(), "magic") {
+			t.Errorf("case %d: expected a magic mismatch error, got %v", i, err)
+		}
+	}
+}
+
+func TestRemapRestoreConfig(t *testing.T) {
+	container := &linuxContainer{
+		config: &configs.Config{
+			Mounts: []*configs.Mount{
+				{Device: "bind", Source: "/data", Destination: "/data"},
+				{Device: "bind", Source: "/data/logs/app", Destination: "/logs"},
+				{Device: "bind", Source: "/database", Destination: "/db"},
+				{Device: "tmpfs", Source: "/data", Destination: "/tmp"},
+			},
+			Namespaces: configs.Namespaces{
+				{Type: configs.NEWNET, Path: "/run/netns/old"},
+				{Type: configs.NEWPID},
+			},
+		},
+	}
+	err := container.remapRestoreConfig(&CriuOpts{
+		MountSources: map[string]string{
+			"/data":      "/srv/data",
+			"/data/logs": "/var/log/data",
+		},
+		NamespacePaths: map[configs.NamespaceType]string{
+			configs.NEWNET: "/run/netns/new",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []string{"/srv/data", "/var/log/data/app", "/database", "/data"} {
+		if got := container.config.Mounts[i].Source; got != expected {
+			t.Errorf("expected the source of mount %d to be %s, got %s", i, expected, got)
+		}
+	}
+	if got := container.config.Namespaces.PathOf(configs.NEWNET); got != "/run/netns/new" {
+		t.Errorf("expected the network namespace path to be /run/netns/new, got %s", got)
+	}
+
+	if err := container.remapRestoreConfig(&CriuOpts{
+		MountSources: map[string]string{"/nonexistent": "/srv"},
+	}); err == nil {
+		t.Error("expected an error remapping a mount source without bind mounts")
+	}
+	if err := container.remapRestoreConfig(&CriuOpts{
+		NamespacePaths: map[configs.NamespaceType]string{configs.NEWPID: "/proc/1/ns/pid"},
+	}); err == nil {
+		t.Error("expected an error remapping a namespace without a path")
+	}
+}
+
+func TestPtsIndex(t *testing.T) {
+	master, slavePath, err := console.NewPty()
+	if err != nil {
+		t.Skipf("unable to create a pty: %v", err)
+	}
+	defer master.Close()
+	slave, err := os.OpenFile(slavePath, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	expected, err := strconv.Atoi(strings.TrimPrefix(slavePath, "/dev/pts/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index := ptsIndex(os.Getpid(), int(slave.Fd())); index != expected {
+		t.Errorf("expected index %d for %s, got %d", expected, slavePath, index)
+	}
+
+	null, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if index := ptsIndex(os.Getpid(), int(null.Fd())); index != -1 {
+		t.Errorf("expected no index for /dev/null, got %d", index)
+	}
+}
+
+func TestRestoreExecStdio(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criu-exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	procs := `[{"pid":10,"descriptors":["pipe:[100]","pipe:[101]","/dev/null"],"pts":-1},` +
+		`{"pid":20,"descriptors":["/dev/pts/1","/dev/pts/1","/dev/pts/1"],"pts":1}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, execDescriptorsFilename), []byte(procs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	c := &linuxContainer{}
+	const sockpath = "/run/exec-console.sock"
+	newReq := func() *criurpc.CriuReq {
+		return &criurpc.CriuReq{Opts: &criurpc.CriuOpts{}}
+	}
+
+	// The init's pipe:[100] is already inherited as fd 0 of CRIU.
+	req := newReq()
+	extraFiles := []*os.File{nil}
+	inherited := map[string]bool{"pipe:[100]": true}
+	criuOpts := &CriuOpts{
+		ImagesDirectory:   dir,
+		ExecStdio:         map[int][]*os.File{10: {r, w}},
+		ExecConsoleSocket: sockpath,
+	}
+	if err := c.restoreExecStdio(req, criuOpts, &extraFiles, inherited); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Opts.InheritFd) != 1 {
+		t.Fatalf("expected 1 inherited fd, got %v", req.Opts.InheritFd)
+	}
+	if fd := req.Opts.InheritFd[0]; fd.GetKey() != "pipe:[101]" || fd.GetFd() != 5 {
+		t.Errorf("expected pipe:[101] as fd 5, got %s as fd %d", fd.GetKey(), fd.GetFd())
+	}
+	if len(extraFiles) != 2 || extraFiles[1] != w {
+		t.Errorf("expected the stdout file to be passed to CRIU, got %v", extraFiles)
+	}
+
+	for _, tc := range []struct {
+		execStdio map[int][]*os.File
+		sockpath  string
+		err       string
+	}{
+		{
+			execStdio: map[int][]*os.File{10: {nil, nil, w}},
+			sockpath:  sockpath,
+			err:       "unable to reconnect fd 2 of process 10: it is not a pipe",
+		},
+		{
+			execStdio: map[int][]*os.File{30: {r}},
+			sockpath:  sockpath,
+			err:       "unable to reconnect the stdio of process 30: no such process in the checkpoint",
+		},
+		{
+			err: "unable to restore the terminal of process 20 without an exec console socket",
+		},
+	} {
+		extraFiles := []*os.File{}
+		criuOpts := &CriuOpts{ImagesDirectory: dir, ExecStdio: tc.execStdio, ExecConsoleSocket: tc.sockpath}
+		err := c.restoreExecStdio(newReq(), criuOpts, &extraFiles, map[string]bool{})
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
+
+func TestCriuCgroupRemapped(t *testing.T) {
+	dir := t.TempDir()
+	container := &linuxContainer{
+		id:            "new",
+		cgroupManager: &mockCgroupManager{paths: map[string]string{"": "/sys/fs/cgroup/new"}},
+	}
+	opts := &CriuOpts{ImagesDirectory: dir}
+
+	// A checkpoint made by an older runc is restored as it was before.
+	remap, err := container.criuCgroupRemapped(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remap {
+		t.Error("expected no remap without container.json")
+	}
+	if id, err := CheckpointedID(dir); err != nil || id != "" {
+		t.Errorf("expected no checkpointed ID, got %q (%v)", id, err)
+	}
+
+	for _, tc := range []struct {
+		path  string
+		remap bool
+	}{
+		{path: "/sys/fs/cgroup/new", remap: false},
+		{path: "/sys/fs/cgroup/old", remap: true},
+	} {
+		ct := fmt.Sprintf(`{"id": "old", "cgroup_paths": {"": %q}}`, tc.path)
+		if err := ioutil.WriteFile(filepath.Join(dir, containerFilename), []byte(ct), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		remap, err := container.criuCgroupRemapped(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remap != tc.remap {
+			t.Errorf("checkpointed in %s: expected remap to be %v, got %v", tc.path, tc.remap, remap)
+		}
+		if id, err := CheckpointedID(dir); err != nil || id != "old" {
+			t.Errorf("expected the checkpointed ID to be old, got %q (%v)", id, err)
+		}
+	}
+}
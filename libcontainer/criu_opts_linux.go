@@ -1,6 +1,11 @@
 package libcontainer
 
-import criu "github.com/checkpoint-restore/go-criu/v5/rpc"
+import (
+	"os"
+
+	criu "github.com/checkpoint-restore/go-criu/v7/rpc"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
 
 type CriuPageServerInfo struct {
 	Address string // IP address of CRIU page server
@@ -30,4 +35,50 @@ type CriuOpts struct {
 	LazyPages               bool               // restore memory pages lazily using userfaultfd
 	StatusFd                int                // fd for feedback when lazy server is ready
 	LsmProfile              string             // LSM profile used to restore the container
+
+	// PreDumpIterations, if not 0, makes Checkpoint pre-dump the container
+	// up to that many times before the final dump, each pre-dump only
+	// writing the memory changed since the previous one, stopping once a
+	// pre-dump writes no more than PreDumpConvergence pages. The pre-dumps
+	// are written to the "pre-dump-<n>" subdirectories of ImagesDirectory.
+	PreDumpIterations  int
+	PreDumpConvergence uint64
+	// PreDumpProgress, if set, is called with the statistics of each
+	// pre-dump, and of the final dump.
+	PreDumpProgress func(CriuDumpStats)
+
+	// MountSources maps the sources of the bind mounts of the container, as
+	// they were when it was checkpointed, to the ones to restore them from.
+	// A source which is under one of the mapped paths is mapped as well.
+	MountSources map[string]string
+	// NamespacePaths are the paths of the external namespaces to restore
+	// the container into, instead of the ones it was configured with.
+	NamespacePaths map[configs.NamespaceType]string
+
+	// ExecStdio are the files to reconnect the stdio of the processes other
+	// than the init (such as the ones started by "runc exec") to, by their
+	// pid in the container, as recorded in exec-descriptors.json when the
+	// container was checkpointed. Only pipes can be reconnected; a nil file
+	// leaves the descriptor as it is.
+	ExecStdio map[int][]*os.File
+
+	// ExecConsoleSocket is the path to the AF_UNIX socket to send the
+	// masters of the ptys of the processes other than the init to, as CRIU
+	// re-creates them. It is connected to once for each of them.
+	ExecConsoleSocket string
+}
+
+// CriuDumpStats are the statistics of a (pre-)dump, as reported by CRIU.
+type CriuDumpStats struct {
+	// Iteration is the number of the pre-dump, starting from 1, or 0 for
+	// the final dump.
+	Iteration          int
+	PagesScanned       uint64
+	PagesSkippedParent uint64
+	PagesWritten       uint64
+	// The times are in microseconds.
+	FreezingTime uint32
+	FrozenTime   uint32
+	MemdumpTime  uint32
+	MemwriteTime uint32
 }
@@ -10,4 +10,6 @@ var (
 	ErrRunning    = errors.New("container still running")
 	ErrNotRunning = errors.New("container not running")
 	ErrNotPaused  = errors.New("container not paused")
+
+	ErrExitStatusUnknown = errors.New("container exit status unknown")
 )
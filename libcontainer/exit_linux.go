@@ -0,0 +1,215 @@
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// ExitStatus is the exit status of a container's init process.
+type ExitStatus struct {
+	// Code is the exit code of the init process, unless it was killed by a
+	// signal.
+	Code int `json:"code"`
+
+	// Signal is the signal which killed the init process, if any.
+	Signal unix.Signal `json:"signal,omitempty"`
+
+	// OOMKilled is set if the OOM killer killed any process of the
+	// container.
+	OOMKilled bool `json:"oom_killed,omitempty"`
+
+	// Time is when the init process was seen exiting.
+	Time time.Time `json:"time"`
+}
+
+// ExitCode returns the exit code of a shell running the init process, that
+// is 128 plus the signal number if it was killed by a signal.
+func (s *ExitStatus) ExitCode() int {
+	if s.Signal != 0 {
+		return 128 + int(s.Signal)
+	}
+	return s.Code
+}
+
+func (c *linuxContainer) Wait() (*ExitStatus, error) {
+	c.m.Lock()
+	status, p := c.exitStatus, c.initProcess
+	c.m.Unlock()
+	if status != nil {
+		return status, nil
+	}
+	if p == nil {
+		return nil, ErrExitStatusUnknown
+	}
+	if !isChildProcess(p) {
+		return c.waitRecorded(p)
+	}
+
+	// We are the parent of the init process, so it is ours to reap, and
+	// to record its exit status.
+	initState := &BaseState{InitProcessPid: p.pid()}
+	initState.InitProcessStartTime, _ = p.startTime()
+	ps, err := p.wait()
+	if ps == nil {
+		return nil, err
+	}
+	status = c.newExitStatus(unix.WaitStatus(ps.Sys().(syscall.WaitStatus)), time.Now())
+
+	c.m.Lock()
+	c.exitStatus = status
+	c.m.Unlock()
+	// The container may have been destroyed in the meantime, in which case
+	// there is nowhere to record the status.
+	if err := c.recordExitStatus(initState, status); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logrus.Warnf("unable to record the exit status of container %s: %v", c.id, err)
+	}
+	return status, nil
+}
+
+// exitStatusRecordTimeout is how long waitRecorded waits for the exit status
+// of an init process which has exited to be recorded.
+const exitStatusRecordTimeout = 5 * time.Second
+
+// waitRecorded waits for the init process p, which is not our child, to exit,
+// and returns the exit status its parent records in the state of the
+// container (see Wait). If none is recorded, it falls back to the exit status
+// waitExit gets, if any.
+func (c *linuxContainer) waitRecorded(p parentProcess) (*ExitStatus, error) {
+	initState := &BaseState{InitProcessPid: p.pid()}
+	initState.InitProcessStartTime, _ = p.startTime()
+	ws, exited, waitErr := waitExit(p)
+	for deadline := time.Now().Add(exitStatusRecordTimeout); ; {
+		state, err := readState(c.root)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// The container has been destroyed.
+				return nil, ErrExitStatusUnknown
+			}
+			return nil, err
+		}
+		if !sameInitProcess(&state.BaseState, initState) {
+			return nil, ErrExitStatusUnknown
+		}
+		if state.ExitStatus != nil {
+			c.m.Lock()
+			c.exitStatus = state.ExitStatus
+			c.m.Unlock()
+			return state.ExitStatus, nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if waitErr != nil {
+		return nil, waitErr
+	}
+	return c.newExitStatus(ws, exited), nil
+}
+
+// newExitStatus returns the exit status of the init process, given its wait
+// status ws and the time it exited at.
+func (c *linuxContainer) newExitStatus(ws unix.WaitStatus, exited time.Time) *ExitStatus {
+	status := &ExitStatus{Time: exited}
+	if ws.Signaled() {
+		status.Signal = ws.Signal()
+	} else {
+		status.Code = ws.ExitStatus()
+	}
+	if count, err := c.cgroupManager.OOMKillCount(); err == nil && count > 0 {
+		status.OOMKilled = true
+	}
+	return status
+}
+
+// isChildProcess returns whether the process p is our child.
+func isChildProcess(p parentProcess) bool {
+	stat, err := system.Stat(p.pid())
+	if err != nil {
+		return false
+	}
+	startTime, err := p.startTime()
+	return err == nil && stat.StartTime == startTime && int(stat.PPID) == os.Getpid()
+}
+
+// waitExit waits for the process p to exit, and returns its wait status and
+// the time it exited at. Whether it is our child or not, the exit status is
+// obtained through its pidfd once it has been reaped (Linux >= 6.15), or from
+// /proc while it is a zombie, and is unknown otherwise.
+func waitExit(p parentProcess) (unix.WaitStatus, time.Time, error) {
+	pid := p.pid()
+	startTime, _ := p.startTime()
+	pidfd, err := p.pidfd()
+	if err != nil {
+		if errors.Is(err, errProcessDone) {
+			return 0, time.Time{}, ErrExitStatusUnknown
+		}
+		// Without pidfds, poll the process until it is a zombie.
+		logrus.Debugf("unable to use a pidfd, polling pid %d: %v", pid, err)
+		for {
+			stat, err := system.Stat(pid)
+			if err != nil || stat.StartTime != startTime || stat.State == system.Dead {
+				return 0, time.Time{}, ErrExitStatusUnknown
+			}
+			if stat.State == system.Zombie {
+				return unix.WaitStatus(stat.ExitCode), time.Now(), nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	defer pidfd.Close()
+
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var pollErr error
+	if err := conn.Control(func(fd uintptr) {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for {
+			_, pollErr = unix.Poll(fds, -1)
+			if pollErr != unix.EINTR {
+				return
+			}
+		}
+	}); err != nil {
+		return 0, time.Time{}, err
+	}
+	if pollErr != nil {
+		return 0, time.Time{}, os.NewSyscallError("poll", pollErr)
+	}
+	exited := time.Now()
+
+	// The process is either a zombie, or has been reaped already, and may
+	// be reaped while we are looking, hence pidfd is checked both before
+	// and after /proc.
+	if ws, ok := pidfdExitStatus(conn); ok {
+		return ws, exited, nil
+	}
+	if stat, err := system.Stat(pid); err == nil && stat.StartTime == startTime && stat.State == system.Zombie {
+		return unix.WaitStatus(stat.ExitCode), exited, nil
+	}
+	if ws, ok := pidfdExitStatus(conn); ok {
+		return ws, exited, nil
+	}
+	return 0, time.Time{}, ErrExitStatusUnknown
+}
+
+// pidfdExitStatus returns the wait status of the reaped process referred to
+// by the pidfd conn, if the kernel provides it.
+func pidfdExitStatus(conn syscall.RawConn) (unix.WaitStatus, bool) {
+	var info *system.PidfdInfo
+	_ = conn.Control(func(fd uintptr) {
+		info, _ = system.PidfdGetInfo(int(fd), system.PIDFD_INFO_EXIT)
+	})
+	if info == nil || info.Mask&system.PIDFD_INFO_EXIT == 0 {
+		return 0, false
+	}
+	return unix.WaitStatus(info.ExitCode), true
+}
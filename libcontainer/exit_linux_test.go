@@ -0,0 +1,89 @@
+package libcontainer
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+	"golang.org/x/sys/unix"
+)
+
+func TestWaitExit(t *testing.T) {
+	for _, tc := range []struct {
+		script string
+		code   int
+		signal unix.Signal
+	}{
+		{script: "exit 5", code: 5},
+		{script: "kill -TERM $$", signal: unix.SIGTERM},
+	} {
+		cmd := exec.Command("sh", "-c", tc.script)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		stat, err := system.Stat(cmd.Process.Pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The process is not reaped until waitExit returns, so that its
+		// exit status is known even without pidfd support.
+		p := &nonChildProcess{processPid: cmd.Process.Pid, processStartTime: stat.StartTime}
+		ws, _, err := waitExit(p)
+		_ = cmd.Wait()
+		if err != nil {
+			t.Fatalf("%q: %v", tc.script, err)
+		}
+		if tc.signal != 0 {
+			if !ws.Signaled() || ws.Signal() != tc.signal {
+				t.Errorf("%q: expected to be killed by %v, got %#x", tc.script, tc.signal, ws)
+			}
+		} else if !ws.Exited() || ws.ExitStatus() != tc.code {
+			t.Errorf("%q: expected exit code %d, got %#x", tc.script, tc.code, ws)
+		}
+	}
+}
+
+func TestRecordExitStatus(t *testing.T) {
+	c := &linuxContainer{id: "test", root: t.TempDir()}
+	initState := BaseState{ID: "test", InitProcessPid: 100, InitProcessStartTime: 200}
+	if err := c.saveState(&State{BaseState: initState}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The state written in the meantime (as by "runc update") is kept.
+	updated := &State{BaseState: initState}
+	updated.Config.Hostname = "updated"
+	if err := c.saveState(updated); err != nil {
+		t.Fatal(err)
+	}
+	// The exit status of another init process is not recorded.
+	other := initState
+	other.InitProcessStartTime++
+	if err := c.recordExitStatus(&other, &ExitStatus{Code: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.recordExitStatus(&initState, &ExitStatus{Code: 3}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := readState(c.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Config.Hostname != "updated" {
+		t.Errorf("expected the updated state to be kept, got hostname %q", s.Config.Hostname)
+	}
+	if s.ExitStatus == nil || s.ExitStatus.Code != 3 {
+		t.Fatalf("expected exit code 3 to be recorded, got %+v", s.ExitStatus)
+	}
+
+	// Saving a state loaded before the exit status was recorded keeps it.
+	if err := c.saveState(&State{BaseState: initState}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = readState(c.root); err != nil {
+		t.Fatal(err)
+	}
+	if s.ExitStatus == nil || s.ExitStatus.Code != 3 {
+		t.Errorf("expected exit code 3 to be kept, got %+v", s.ExitStatus)
+	}
+}
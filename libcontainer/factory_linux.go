@@ -25,8 +25,9 @@ import (
 )
 
 const (
-	stateFilename    = "state.json"
-	execFifoFilename = "exec.fifo"
+	stateFilename     = "state.json"
+	stateLockFilename = "state.lock"
+	execFifoFilename  = "exec.fifo"
 )
 
 var (
@@ -307,9 +308,10 @@ func (l *LinuxFactory) Load(id string) (Container, error) {
 		return nil, err
 	}
 	r := &nonChildProcess{
-		processPid:       state.InitProcessPid,
-		processStartTime: state.InitProcessStartTime,
-		fds:              state.ExternalDescriptors,
+		processPid:        state.InitProcessPid,
+		processStartTime:  state.InitProcessStartTime,
+		processPidfdInode: state.InitProcessPidfdInode,
+		fds:               state.ExternalDescriptors,
 	}
 	c := &linuxContainer{
 		initProcess:          r,
@@ -324,6 +326,7 @@ func (l *LinuxFactory) Load(id string) (Container, error) {
 		cgroupManager:        l.NewCgroupsManager(state.Config.Cgroups, state.CgroupPaths),
 		root:                 containerRoot,
 		created:              state.Created,
+		exitStatus:           state.ExitStatus,
 	}
 	if l.NewIntelRdtManager != nil {
 		c.intelRdtManager = l.NewIntelRdtManager(&state.Config, id, state.IntelRdtPath)
@@ -378,6 +381,14 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 		return fmt.Errorf("unable to convert _LIBCONTAINER_LOGPIPE: %w", err)
 	}
 
+	// Only init processes with id-mapped mounts have MOUNT_FDS.
+	var mountFds []int
+	if envMountFds := os.Getenv("_LIBCONTAINER_MOUNT_FDS"); envMountFds != "" {
+		if err := json.Unmarshal([]byte(envMountFds), &mountFds); err != nil {
+			return fmt.Errorf("unable to unmarshal _LIBCONTAINER_MOUNT_FDS: %w", err)
+		}
+	}
+
 	// clear the current process's environment to clean any libcontainer
 	// specific env vars.
 	os.Clearenv()
@@ -396,11 +407,11 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 	}()
 	defer func() {
 		if e := recover(); e != nil {
-			err = fmt.Errorf("panic from initialization: %w, %v", e, string(debug.Stack()))
+			err = fmt.Errorf("panic from initialization: %v, %s", e, debug.Stack())
 		}
 	}()
 
-	i, err := newContainerInit(it, pipe, consoleSocket, fifofd, logPipeFd)
+	i, err := newContainerInit(it, pipe, consoleSocket, fifofd, logPipeFd, mountFds)
 	if err != nil {
 		return err
 	}
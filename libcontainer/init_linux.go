@@ -76,7 +76,7 @@ type initer interface {
 	Init() error
 }
 
-func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd, logFd int) (initer, error) {
+func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd, logFd int, mountFds []int) (initer, error) {
 	var config *initConfig
 	if err := json.NewDecoder(pipe).Decode(&config); err != nil {
 		return nil, err
@@ -100,6 +100,7 @@ func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd,
 			config:        config,
 			fifoFd:        fifoFd,
 			logFd:         logFd,
+			mountFds:      mountFds,
 		}, nil
 	}
 	return nil, fmt.Errorf("unknown init type %q", t)
@@ -271,6 +272,32 @@ func syncParentHooks(pipe io.ReadWriter) error {
 	return readSync(pipe, procResume)
 }
 
+// syncParentSeccomp hands the seccomp notify fd over to the parent, which
+// forwards it to the seccomp agent. It then waits for the parent to indicate
+// that it is cleared to resume. If seccompFd is -1, this is a no-op.
+func syncParentSeccomp(pipe *os.File, seccompFd int) error {
+	if seccompFd == -1 {
+		return nil
+	}
+	defer unix.Close(seccompFd) //nolint: errcheck
+
+	// Tell parent.
+	if err := writeSync(pipe, procSeccomp); err != nil {
+		return err
+	}
+	// Wait for the parent to be ready to receive the fd, so that it
+	// doesn't end up in the parent's JSON decoder buffer.
+	if err := readSync(pipe, procSeccompFd); err != nil {
+		return err
+	}
+	if err := utils.SendFd(pipe, "seccomp-notify", uintptr(seccompFd)); err != nil {
+		return fmt.Errorf("unable to send seccomp notify fd: %w", err)
+	}
+
+	// Wait for parent to give the all-clear.
+	return readSync(pipe, procSeccompDone)
+}
+
 // setupUser changes the groups, gid, and uid for the user inside the container
 func setupUser(config *initConfig) error {
 	// Set up defaults.
@@ -3,13 +3,18 @@ package integration
 import (
 	"bufio"
 	"bytes"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 
+	criu "github.com/checkpoint-restore/go-criu/v7"
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/userns"
+	"github.com/syndtr/gocapability/capability"
 	"golang.org/x/sys/unix"
 )
 
@@ -52,6 +57,65 @@ func TestCheckpoint(t *testing.T) {
 	testCheckpoint(t, false)
 }
 
+// TestCheckpointRootless checkpoints and restores a container from within
+// a user namespace, which makes libcontainer use CRIU's unprivileged mode.
+// Unless already running in a user namespace, the test re-executes itself
+// in a new one.
+func TestCheckpointRootless(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	if userns.RunningInUserNS() {
+		testCheckpoint(t, true)
+		return
+	}
+
+	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
+		t.Skip("Test requires userns.")
+	}
+	if os.Geteuid() != 0 {
+		t.Skip("Test requires root to set up the user namespace.")
+	}
+	if capability.CAP_LAST_CAP < capability.CAP_CHECKPOINT_RESTORE {
+		t.Skip("Test requires CAP_CHECKPOINT_RESTORE.")
+	}
+	if _, err := exec.LookPath("criu"); err != nil {
+		t.Skipf("criu binary not found: %v", err)
+	}
+	version, err := criu.MakeCriu().GetCriuVersion()
+	if err != nil {
+		t.Skipf("unable to get the criu version: %v", err)
+	}
+	if version < 31700 {
+		t.Skipf("criu %d does not support the unprivileged mode", version)
+	}
+
+	// Map root in the user namespace to a non-root host ID, so that
+	// the test does not have any privileges outside of it. The test
+	// binary is usually built in a directory only accessible by root,
+	// so it is copied to one the unprivileged host ID can access.
+	dir, err := ioutil.TempDir("", "checkpoint-rootless")
+	ok(t, err)
+	defer remove(dir)
+	ok(t, os.Chmod(dir, 0o755))
+	bin, err := ioutil.ReadFile(os.Args[0])
+	ok(t, err)
+	testBin := filepath.Join(dir, filepath.Base(os.Args[0]))
+	ok(t, ioutil.WriteFile(testBin, bin, 0o755))
+
+	cmd := exec.Command(testBin, "-test.run=^TestCheckpointRootless$", "-test.v")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  unix.CLONE_NEWUSER | unix.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: 100000, Size: 65536}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: 100000, Size: 65536}},
+	}
+	out, err := cmd.CombinedOutput()
+	t.Logf("%s", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func testCheckpoint(t *testing.T, userns bool) {
 	if testing.Short() {
 		return
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/containerd/console"
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/utils"
 
@@ -548,3 +550,69 @@ func TestExecInUserns(t *testing.T) {
 		t.Errorf("execin userns(%s), wanted %s", out, initUserns)
 	}
 }
+
+func TestExecInSubCgroup(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	config := newTemplateConfig(t, nil)
+	container, err := newContainer(t, config)
+	ok(t, err)
+	defer destroyContainer(container)
+
+	stdinR, stdinW, err := os.Pipe()
+	ok(t, err)
+	process := &libcontainer.Process{
+		Cwd:   "/",
+		Args:  []string{"cat"},
+		Env:   standardEnvironment,
+		Stdin: stdinR,
+		Init:  true,
+	}
+	err = container.Run(process)
+	_ = stdinR.Close()
+	defer stdinW.Close() //nolint: errcheck
+	ok(t, err)
+
+	buffers := newStdBuffers()
+	ps := &libcontainer.Process{
+		Cwd:            "/",
+		Args:           []string{"cat", "/proc/self/cgroup"},
+		Env:            standardEnvironment,
+		Stdin:          buffers.Stdin,
+		Stdout:         buffers.Stdout,
+		Stderr:         buffers.Stderr,
+		SubCgroupPaths: map[string]string{"": "debug"},
+	}
+	// With cgroup v2, the controllers can't be enabled for the sub-cgroup,
+	// as the container's cgroup has processes.
+	if cgroups.IsCgroup2UnifiedMode() {
+		ps.SubCgroupResources = &configs.Resources{PidsLimit: 10}
+		err = container.Run(ps)
+		if err == nil || !strings.Contains(err.Error(), "can't be set with cgroup v2") {
+			t.Fatalf("expected sub-cgroup resources to be rejected, got %v", err)
+		}
+		ps.SubCgroupResources = nil
+	} else {
+		ps.SubCgroupResources = &configs.Resources{PidsLimit: 10}
+	}
+	err = container.Run(ps)
+	ok(t, err)
+	waitProcess(ps, t)
+
+	_ = stdinW.Close()
+	waitProcess(process, t)
+
+	if out := buffers.Stdout.String(); !strings.Contains(out, "/debug\n") {
+		t.Fatalf("expected the process to be in the sub-cgroup, got %q", out)
+	}
+	if ps.SubCgroupResources != nil {
+		state, err := container.State()
+		ok(t, err)
+		pidsMax, err := ioutil.ReadFile(state.CgroupPaths["pids"] + "/debug/pids.max")
+		ok(t, err)
+		if strings.TrimSpace(string(pidsMax)) != "10" {
+			t.Fatalf("expected pids.max of the sub-cgroup to be 10, got %s", pidsMax)
+		}
+	}
+}
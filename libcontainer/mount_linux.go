@@ -1,8 +1,14 @@
 package libcontainer
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strconv"
+	"syscall"
 
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"golang.org/x/sys/unix"
 )
 
@@ -81,3 +87,101 @@ func unmount(target string, flags int) error {
 	}
 	return nil
 }
+
+// moveMount is a system.MoveMount wrapper, attaching the detached mount
+// referenced by fd at target. If procfd is not empty, it is used instead of
+// target (and the target is only used to add context to an error).
+func moveMount(fd int, target, procfd string) error {
+	dst := target
+	flags := uint(system.MOVE_MOUNT_F_EMPTY_PATH)
+	if procfd != "" {
+		dst = procfd
+		// The procfd is a magic link, which has to be followed.
+		flags |= system.MOVE_MOUNT_T_SYMLINKS
+	}
+	if err := system.MoveMount(fd, "", unix.AT_FDCWD, dst, flags); err != nil {
+		return &mountError{
+			op:     "move_mount",
+			target: target,
+			procfd: procfd,
+			err:    err,
+		}
+	}
+	return nil
+}
+
+// idmappedMount creates a detached copy of the bind mount source with the
+// mount's uid and gid mappings applied to it, to be attached inside the
+// container by moveMount. It has to be called from the host mount namespace.
+func idmappedMount(m *configs.Mount) (_ *os.File, retErr error) {
+	recursive := uint(0)
+	if m.Flags&unix.MS_REC != 0 {
+		recursive = system.AT_RECURSIVE
+	}
+	fd, err := system.OpenTree(unix.AT_FDCWD, m.Source, system.OPEN_TREE_CLONE|system.OPEN_TREE_CLOEXEC|recursive)
+	if err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			return nil, errors.New("id-mapped mounts are not supported by the kernel")
+		}
+		return nil, &mountError{op: "open_tree", target: m.Source, err: err}
+	}
+	defer func() {
+		if retErr != nil {
+			_ = unix.Close(fd)
+		}
+	}()
+
+	userns, err := newUserNamespace(m.UIDMappings, m.GIDMappings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create user namespace for id-mapped mount: %w", err)
+	}
+	defer userns.Close()
+
+	attr := &system.MountAttr{
+		AttrSet:  system.MOUNT_ATTR_IDMAP,
+		UsernsFd: uint64(userns.Fd()),
+	}
+	if err := system.MountSetattr(fd, "", unix.AT_EMPTY_PATH|recursive, attr); err != nil {
+		switch {
+		case errors.Is(err, unix.ENOSYS):
+			return nil, errors.New("id-mapped mounts are not supported by the kernel")
+		case errors.Is(err, unix.EINVAL):
+			return nil, fmt.Errorf("unable to create id-mapped mount of %s (the filesystem may not support id-mapped mounts): %w", m.Source, err)
+		}
+		return nil, &mountError{op: "mount_setattr", target: m.Source, err: err}
+	}
+	return os.NewFile(uintptr(fd), "idmap:"+m.Source), nil
+}
+
+// newUserNamespace returns a file referring to a new user namespace with the
+// given mappings. The namespace is created by a short-lived child, which is
+// stopped (by PTRACE_TRACEME) before it gets to execute anything.
+func newUserNamespace(uidMap, gidMap []configs.IDMap) (*os.File, error) {
+	toSysProcIDMap := func(idMap []configs.IDMap) []syscall.SysProcIDMap {
+		var ret []syscall.SysProcIDMap
+		for _, m := range idMap {
+			ret = append(ret, syscall.SysProcIDMap{
+				ContainerID: m.ContainerID,
+				HostID:      m.HostID,
+				Size:        m.Size,
+			})
+		}
+		return ret
+	}
+	proc, err := os.StartProcess("/proc/self/exe", []string{"runc-userns"}, &os.ProcAttr{
+		Sys: &syscall.SysProcAttr{
+			Cloneflags:  unix.CLONE_NEWUSER,
+			UidMappings: toSysProcIDMap(uidMap),
+			GidMappings: toSysProcIDMap(gidMap),
+			Ptrace:      true,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = proc.Kill()
+		_, _ = proc.Wait()
+	}()
+	return os.Open("/proc/" + strconv.Itoa(proc.Pid) + "/ns/user")
+}
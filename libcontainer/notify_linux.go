@@ -18,12 +18,17 @@ const (
 	CriticalPressure
 )
 
-func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct{}, error) {
+// registerMemoryEvent returns a channel on which you can expect an event
+// every time the eventfd registered for evName fires. The channel is closed
+// once the cgroup is removed, or once done (if not nil) is closed.
+func registerMemoryEvent(cgDir string, evName string, arg string, done <-chan struct{}) (<-chan struct{}, error) {
 	evFile, err := os.Open(filepath.Join(cgDir, evName))
 	if err != nil {
 		return nil, err
 	}
-	fd, err := unix.Eventfd(0, unix.EFD_CLOEXEC)
+	// The eventfd is non-blocking for the reads to go through the Go
+	// runtime poller, so that closing it interrupts a pending read.
+	fd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
 	if err != nil {
 		evFile.Close()
 		return nil, err
@@ -32,7 +37,7 @@ func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct
 	eventfd := os.NewFile(uintptr(fd), "eventfd")
 
 	eventControlPath := filepath.Join(cgDir, "cgroup.event_control")
-	data := fmt.Sprintf("%d %d %s", eventfd.Fd(), evFile.Fd(), arg)
+	data := fmt.Sprintf("%d %d %s", fd, evFile.Fd(), arg)
 	if err := ioutil.WriteFile(eventControlPath, []byte(data), 0o700); err != nil {
 		eventfd.Close()
 		evFile.Close()
@@ -45,6 +50,9 @@ func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct
 			evFile.Close()
 			close(ch)
 		}()
+		stop := closeOnDone(eventfd, done)
+		defer close(stop)
+
 		buf := make([]byte, 8)
 		for {
 			if _, err := eventfd.Read(buf); err != nil {
@@ -55,20 +63,42 @@ func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct
 			if _, err := os.Lstat(eventControlPath); os.IsNotExist(err) {
 				return
 			}
-			ch <- struct{}{}
+			select {
+			case ch <- struct{}{}:
+			case <-done:
+				return
+			}
 		}
 	}()
 	return ch, nil
 }
 
+// closeOnDone closes f once done is closed, interrupting any pending read
+// from it, unless the returned channel is closed first. A nil done is
+// never closed.
+func closeOnDone(f *os.File, done <-chan struct{}) chan<- struct{} {
+	stop := make(chan struct{})
+	if done != nil {
+		go func() {
+			select {
+			case <-done:
+				f.Close()
+			case <-stop:
+			}
+		}()
+	}
+	return stop
+}
+
 // notifyOnOOM returns channel on which you can expect event about OOM,
-// if process died without OOM this channel will be closed.
-func notifyOnOOM(dir string) (<-chan struct{}, error) {
+// if process died without OOM this channel will be closed. The channel
+// is also closed once done (if not nil) is closed.
+func notifyOnOOM(dir string, done <-chan struct{}) (<-chan struct{}, error) {
 	if dir == "" {
 		return nil, errors.New("memory controller missing")
 	}
 
-	return registerMemoryEvent(dir, "memory.oom_control", "")
+	return registerMemoryEvent(dir, "memory.oom_control", "", done)
 }
 
 func notifyMemoryPressure(dir string, level PressureLevel) (<-chan struct{}, error) {
@@ -81,5 +111,5 @@ func notifyMemoryPressure(dir string, level PressureLevel) (<-chan struct{}, err
 	}
 
 	levelStr := []string{"low", "medium", "critical"}[level]
-	return registerMemoryEvent(dir, "memory.pressure_level", levelStr)
+	return registerMemoryEvent(dir, "memory.pressure_level", levelStr, nil)
 }
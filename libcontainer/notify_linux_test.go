@@ -95,7 +95,7 @@ func testMemoryNotification(t *testing.T, evName string, notify notifyFunc, targ
 
 func TestNotifyOnOOM(t *testing.T) {
 	f := func(path string) (<-chan struct{}, error) {
-		return notifyOnOOM(path)
+		return notifyOnOOM(path, nil)
 	}
 
 	testMemoryNotification(t, "memory.oom_control", f, "")
@@ -1,25 +1,39 @@
 package libcontainer
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"unsafe"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
-func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, error) {
-	fd, err := unix.InotifyInit()
+// watchCgroupFilesV2 returns a channel on which you can expect the name of
+// any of the given cgroup files once it is modified. The channel is closed
+// once the cgroup has no processes left, as reported by cgEvName, or once
+// done (if not nil) is closed.
+func watchCgroupFilesV2(done <-chan struct{}, cgDir, cgEvName string, evNames ...string) (<-chan string, error) {
+	// The inotify fd is non-blocking for the reads to go through the Go
+	// runtime poller, so that closing it interrupts a pending read.
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
 	if err != nil {
 		return nil, fmt.Errorf("unable to init inotify: %w", err)
 	}
-	// watching oom kill
-	evFd, err := unix.InotifyAddWatch(fd, filepath.Join(cgDir, evName), unix.IN_MODIFY)
-	if err != nil {
-		unix.Close(fd)
-		return nil, fmt.Errorf("unable to add inotify watch: %w", err)
+	watches := make(map[int]string, len(evNames))
+	for _, evName := range evNames {
+		evFd, err := unix.InotifyAddWatch(fd, filepath.Join(cgDir, evName), unix.IN_MODIFY)
+		if err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("unable to add inotify watch: %w", err)
+		}
+		watches[evFd] = evName
 	}
 	// Because no `unix.IN_DELETE|unix.IN_DELETE_SELF` event for cgroup file system, so watching all process exited
 	cgFd, err := unix.InotifyAddWatch(fd, filepath.Join(cgDir, cgEvName), unix.IN_MODIFY)
@@ -27,21 +41,28 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 		unix.Close(fd)
 		return nil, fmt.Errorf("unable to add inotify watch: %w", err)
 	}
-	ch := make(chan struct{})
+	inotify := os.NewFile(uintptr(fd), "inotify")
+	ch := make(chan string)
 	go func() {
 		var (
 			buffer [unix.SizeofInotifyEvent + unix.PathMax + 1]byte
 			offset uint32
 		)
 		defer func() {
-			unix.Close(fd)
+			inotify.Close()
 			close(ch)
 		}()
+		stop := closeOnDone(inotify, done)
+		defer close(stop)
 
 		for {
-			n, err := unix.Read(fd, buffer[:])
+			n, err := inotify.Read(buffer[:])
 			if err != nil {
-				logrus.Warnf("unable to read event data from inotify, got error: %v", err)
+				select {
+				case <-done:
+				default:
+					logrus.Warnf("unable to read event data from inotify, got error: %v", err)
+				}
 				return
 			}
 			if n < unix.SizeofInotifyEvent {
@@ -55,17 +76,41 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 				if rawEvent.Mask&unix.IN_MODIFY != unix.IN_MODIFY {
 					continue
 				}
-				switch int(rawEvent.Wd) {
-				case evFd:
-					oom, err := fscommon.GetValueByKey(cgDir, evName, "oom_kill")
-					if err != nil || oom > 0 {
-						ch <- struct{}{}
-					}
-				case cgFd:
+				if int(rawEvent.Wd) == cgFd {
 					pids, err := fscommon.GetValueByKey(cgDir, cgEvName, "populated")
 					if err != nil || pids == 0 {
 						return
 					}
+					continue
+				}
+				if evName, ok := watches[int(rawEvent.Wd)]; ok {
+					select {
+					case ch <- evName:
+					case <-done:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func registerMemoryEventV2(cgDir, evName, cgEvName string, done <-chan struct{}) (<-chan struct{}, error) {
+	modified, err := watchCgroupFilesV2(done, cgDir, cgEvName, evName)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{})
+	go func() {
+		defer close(ch)
+		for range modified {
+			oom, err := fscommon.GetValueByKey(cgDir, evName, "oom_kill")
+			if err != nil || oom > 0 {
+				select {
+				case ch <- struct{}{}:
+				case <-done:
+					return
 				}
 			}
 		}
@@ -74,7 +119,159 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 }
 
 // notifyOnOOMV2 returns channel on which you can expect event about OOM,
-// if process died without OOM this channel will be closed.
-func notifyOnOOMV2(path string) (<-chan struct{}, error) {
-	return registerMemoryEventV2(path, "memory.events", "cgroup.events")
+// if process died without OOM this channel will be closed. The channel
+// is also closed once done (if not nil) is closed.
+func notifyOnOOMV2(path string, done <-chan struct{}) (<-chan struct{}, error) {
+	return registerMemoryEventV2(path, "memory.events", "cgroup.events", done)
+}
+
+// MemoryEvent is a change of one of the cgroup v2 memory.events counters.
+type MemoryEvent struct {
+	// Name is the name of the counter, i.e. one of "low", "high", "max",
+	// "oom", "oom_kill" (or "oom_group_kill" on newer kernels).
+	Name string
+	// Local is true if the counter is from memory.events.local, i.e. it only
+	// accounts for the events of the container's cgroup itself, and not of
+	// its descendants.
+	Local bool
+	// Count is the new value of the counter.
+	Count uint64
+}
+
+func readMemoryEventsV2(cgDir, evName string) (map[string]uint64, error) {
+	content, err := cgroups.ReadFile(cgDir, evName)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+		k, v, err := fscommon.ParseKeyValue(line)
+		if err != nil {
+			return nil, &fscommon.ParseError{Path: cgDir, File: evName, Err: err}
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+// notifyMemoryEventsV2 returns a channel on which you can expect an event
+// every time one of the memory.events (and, if available, memory.events.local)
+// counters changes. The channel is closed once the cgroup has no processes left,
+// or once done (if not nil) is closed.
+func notifyMemoryEventsV2(cgDir string, done <-chan struct{}) (<-chan MemoryEvent, error) {
+	const (
+		evName      = "memory.events"
+		evLocalName = "memory.events.local" // since kernel 5.2
+	)
+	evNames := []string{evName}
+	if _, err := os.Stat(filepath.Join(cgDir, evLocalName)); err == nil {
+		evNames = append(evNames, evLocalName)
+	}
+	// Remember the current values to only report changes.
+	last := make(map[string]map[string]uint64, len(evNames))
+	for _, name := range evNames {
+		values, err := readMemoryEventsV2(cgDir, name)
+		if err != nil {
+			return nil, err
+		}
+		last[name] = values
+	}
+
+	modified, err := watchCgroupFilesV2(done, cgDir, "cgroup.events", evNames...)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan MemoryEvent)
+	go func() {
+		defer close(ch)
+		for name := range modified {
+			values, err := readMemoryEventsV2(cgDir, name)
+			if err != nil {
+				logrus.Warnf("unable to read %s: %v", name, err)
+				continue
+			}
+			// Sort the counters for the events to be reported in a
+			// stable order.
+			keys := make([]string, 0, len(values))
+			for k := range values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if values[k] > last[name][k] {
+					select {
+					case ch <- MemoryEvent{Name: k, Local: name == evLocalName, Count: values[k]}:
+					case <-done:
+						return
+					}
+				}
+			}
+			last[name] = values
+		}
+	}()
+	return ch, nil
+}
+
+// psiMemoryTriggers maps memory pressure levels to PSI triggers, each being a
+// stall threshold within a 1s window: some tasks stalled for 10% (low) or 30%
+// (medium) of the time, or all tasks stalled for 10% of the time (critical).
+var psiMemoryTriggers = [...]string{
+	LowPressure:      "some 100000 1000000",
+	MediumPressure:   "some 300000 1000000",
+	CriticalPressure: "full 100000 1000000",
+}
+
+// registerPSITrigger registers a PSI trigger (see Documentation/accounting/psi.rst
+// in the kernel sources) on the given pressure file, and returns a channel on
+// which you can expect an event every time the trigger threshold is exceeded.
+// The channel is closed once the cgroup is removed.
+func registerPSITrigger(cgDir, evName, trigger string) (<-chan struct{}, error) {
+	fd, err := unix.Open(filepath.Join(cgDir, evName), unix.O_RDWR|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", evName, err)
+	}
+	// The trigger has to be written in a single write, including the
+	// terminating NUL byte.
+	if _, err := unix.Write(fd, append([]byte(trigger), 0)); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("unable to register psi trigger %q: %w", trigger, err)
+	}
+	ch := make(chan struct{})
+	go func() {
+		defer func() {
+			unix.Close(fd)
+			close(ch)
+		}()
+
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLPRI}}
+		for {
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if errors.Is(err, unix.EINTR) {
+					continue
+				}
+				logrus.Warnf("unable to poll psi trigger, got error: %v", err)
+				return
+			}
+			// The pressure file is gone along with the cgroup.
+			if fds[0].Revents&unix.POLLERR != 0 {
+				return
+			}
+			if fds[0].Revents&unix.POLLPRI != 0 {
+				ch <- struct{}{}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// notifyMemoryPressureV2 is the cgroup v2 counterpart of notifyMemoryPressure,
+// based on PSI triggers as there is no memory.pressure_level in cgroup v2.
+func notifyMemoryPressureV2(path string, level PressureLevel) (<-chan struct{}, error) {
+	if level > CriticalPressure {
+		return nil, fmt.Errorf("invalid pressure level %d", level)
+	}
+	return registerPSITrigger(path, "memory.pressure", psiMemoryTriggers[level])
 }
@@ -0,0 +1,118 @@
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestNotifyMemoryEventsV2(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	cgDir := t.TempDir()
+	// Overwrite the files in place (rather than truncating them first) for
+	// every update to generate a single inotify event, like cgroupfs does.
+	write := func(name, data string) {
+		t.Helper()
+		f, err := os.OpenFile(filepath.Join(cgDir, name), os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("memory.events", "low 0\nhigh 1\nmax 0\noom 0\noom_kill 0\n")
+	write("memory.events.local", "low 0\nhigh 1\nmax 0\noom 0\noom_kill 0\n")
+	write("cgroup.events", "populated 1\nfrozen 0\n")
+
+	ch, err := notifyMemoryEventsV2(cgDir, nil)
+	if err != nil {
+		t.Fatal("expected no error, got:", err)
+	}
+
+	receive := func() MemoryEvent {
+		t.Helper()
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("unexpected channel close")
+			}
+			return e
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("no memory event notification received after 100ms")
+		}
+		return MemoryEvent{}
+	}
+
+	write("memory.events", "low 0\nhigh 3\nmax 1\noom 0\noom_kill 0\n")
+	for _, expected := range []MemoryEvent{
+		{Name: "high", Count: 3},
+		{Name: "max", Count: 1},
+	} {
+		if e := receive(); !reflect.DeepEqual(e, expected) {
+			t.Errorf("expected %+v, got %+v", expected, e)
+		}
+	}
+
+	write("memory.events.local", "low 0\nhigh 1\nmax 0\noom 1\noom_kill 1\n")
+	for _, expected := range []MemoryEvent{
+		{Name: "oom", Local: true, Count: 1},
+		{Name: "oom_kill", Local: true, Count: 1},
+	} {
+		if e := receive(); !reflect.DeepEqual(e, expected) {
+			t.Errorf("expected %+v, got %+v", expected, e)
+		}
+	}
+
+	// The channel is closed once the cgroup has no processes left.
+	write("cgroup.events", "populated 0\nfrozen 0\n")
+	select {
+	case e, ok := <-ch:
+		if ok {
+			t.Fatalf("expected channel to be closed, got %+v", e)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("channel not closed after 100ms")
+	}
+}
+
+func TestNotifyMemoryEventsV2Done(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	cgDir := t.TempDir()
+	for name, data := range map[string]string{
+		"memory.events": "low 0\nhigh 0\nmax 0\noom 0\noom_kill 0\n",
+		"cgroup.events": "populated 1\nfrozen 0\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(cgDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	ch, err := notifyMemoryEventsV2(cgDir, done)
+	if err != nil {
+		t.Fatal("expected no error, got:", err)
+	}
+
+	// Closing done stops the notifier even though the cgroup is populated,
+	// and nobody reads from the channel.
+	close(done)
+	select {
+	case e, ok := <-ch:
+		if ok {
+			t.Fatalf("expected channel to be closed, got %+v", e)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("channel not closed after 100ms")
+	}
+}
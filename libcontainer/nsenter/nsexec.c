@@ -87,6 +87,10 @@ struct nlconfig_t {
 	size_t uidmappath_len;
 	char *gidmappath;
 	size_t gidmappath_len;
+
+	/* Time namespace settings. */
+	char *timensoffset;
+	size_t timensoffset_len;
 };
 
 #define PANIC   "panic"
@@ -112,6 +116,7 @@ static int logfd = -1;
 #define ROOTLESS_EUID_ATTR	27287
 #define UIDMAPPATH_ATTR		27288
 #define GIDMAPPATH_ATTR		27289
+#define TIMENSOFFSET_ATTR	27290
 
 /*
  * Use the raw syscall for versions of glibc which don't include a function for
@@ -356,6 +361,16 @@ static void update_oom_score_adj(char *data, size_t len)
 		bail("failed to update /proc/self/oom_score_adj");
 }
 
+static void update_timens_offsets(char *data, size_t len)
+{
+	if (data == NULL || len == 0)
+		return;
+
+	write_log(DEBUG, "update /proc/self/timens_offsets to '%s'", data);
+	if (write_file(data, len, "/proc/self/timens_offsets") < 0)
+		bail("failed to update /proc/self/timens_offsets");
+}
+
 /* A dummy function that just jumps to the given jumpval. */
 static int child_func(void *arg) __attribute__((noinline));
 static int child_func(void *arg)
@@ -430,6 +445,8 @@ static int nsflag(char *name)
 		return CLONE_NEWUSER;
 	else if (!strcmp(name, "uts"))
 		return CLONE_NEWUTS;
+	else if (!strcmp(name, "time"))
+		return CLONE_NEWTIME;
 
 	/* If we don't recognise a name, fallback to 0. */
 	return 0;
@@ -516,6 +533,10 @@ static void nl_parse(int fd, struct nlconfig_t *config)
 		case SETGROUP_ATTR:
 			config->is_setgroup = readint8(current);
 			break;
+		case TIMENSOFFSET_ATTR:
+			config->timensoffset = current;
+			config->timensoffset_len = payload_len;
+			break;
 		default:
 			bail("unknown netlink message type %d", nlattr->nla_type);
 		}
@@ -990,6 +1011,15 @@ void nsexec(void)
 			if (unshare(config.cloneflags & ~CLONE_NEWCGROUP) < 0)
 				bail("failed to unshare remaining namespaces (except cgroupns)");
 
+			/*
+			 * The time namespace offsets can only be set before any process
+			 * has entered the new time namespace. unshare(CLONE_NEWTIME) only
+			 * affects our children, so this has to be done before we spawn
+			 * stage-2.
+			 */
+			if (config.cloneflags & CLONE_NEWTIME)
+				update_timens_offsets(config.timensoffset, config.timensoffset_len);
+
 			/*
 			 * TODO: What about non-namespace clone flags that we're dropping here?
 			 *
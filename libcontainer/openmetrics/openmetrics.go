@@ -0,0 +1,161 @@
+// Package openmetrics renders container statistics as metric families in the
+// OpenMetrics text format (see https://openmetrics.io), to be consumed by
+// Prometheus-compatible scrapers.
+package openmetrics
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric types, as used in the "# TYPE" metadata line.
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+	Info    = "info"
+)
+
+// Label is a metric label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single sample of a metric family.
+type Sample struct {
+	Labels []Label
+	Value  string
+}
+
+// Family is a metric family, i.e. the samples of a metric along with their
+// metadata.
+type Family struct {
+	// Name is the name of the family. For counters (and infos), the sample
+	// names are suffixed with "_total" (and "_info").
+	Name string
+	Type string
+	// Unit, if not empty, has to be the suffix of the family name.
+	Unit    string
+	Help    string
+	Samples []Sample
+}
+
+// Metrics collects metric families, keeping the samples of a family together
+// even when they are added at different times (e.g. for several containers).
+type Metrics struct {
+	families []*Family
+	byName   map[string]*Family
+}
+
+// New returns an empty set of metric families.
+func New() *Metrics {
+	return &Metrics{byName: make(map[string]*Family)}
+}
+
+// Add adds a sample to the family with the given name, creating the family
+// (with the given metadata) if it does not exist yet.
+func (m *Metrics) Add(name, typ, unit, help string, value string, labels ...Label) {
+	f, ok := m.byName[name]
+	if !ok {
+		f = &Family{Name: name, Type: typ, Unit: unit, Help: help}
+		m.families = append(m.families, f)
+		m.byName[name] = f
+	}
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// Families returns the metric families, sorted by name.
+func (m *Metrics) Families() []*Family {
+	families := make([]*Family, len(m.families))
+	copy(families, m.families)
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families
+}
+
+// WriteTo writes the metric families in the OpenMetrics text format,
+// including the terminating "# EOF" line.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, f := range m.Families() {
+		writeFamily(&buf, f)
+	}
+	buf.WriteString("# EOF\n")
+	return buf.WriteTo(w)
+}
+
+func writeFamily(w *bytes.Buffer, f *Family) {
+	w.WriteString("# TYPE " + f.Name + " " + f.Type + "\n")
+	if f.Unit != "" {
+		w.WriteString("# UNIT " + f.Name + " " + f.Unit + "\n")
+	}
+	if f.Help != "" {
+		w.WriteString("# HELP " + f.Name + " " + escape(f.Help, false) + "\n")
+	}
+	name := f.Name
+	switch f.Type {
+	case Counter:
+		name += "_total"
+	case Info:
+		name += "_info"
+	}
+	for _, s := range f.Samples {
+		w.WriteString(name)
+		if len(s.Labels) > 0 {
+			w.WriteByte('{')
+			for i, l := range s.Labels {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				w.WriteString(l.Name + `="` + escape(l.Value, true) + `"`)
+			}
+			w.WriteByte('}')
+		}
+		w.WriteString(" " + s.Value + "\n")
+	}
+}
+
+// escape escapes a label value (or a help text, which does not need its
+// double quotes to be escaped).
+func escape(s string, quotes bool) string {
+	if !strings.ContainsAny(s, "\\\"\n") {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '"' && quotes:
+			b.WriteString(`\"`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Uint formats an integer sample value.
+func Uint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+// Float formats a floating point sample value.
+func Float(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,84 @@
+package openmetrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/types"
+)
+
+func TestWriteTo(t *testing.T) {
+	m := New()
+	m.Add("test_requests", Counter, "", "Number of \\ requests.", Uint(3), Label{"path", "/a\"b\n"})
+	m.Add("test_latency_seconds", Gauge, "seconds", "", Float(0.25))
+	m.Add("test_requests", Counter, "", "ignored", Uint(4), Label{"path", "/c"})
+	m.Add("test_build", Info, "", "", "1", Label{"version", "1.0"})
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE test_build info
+test_build_info{version="1.0"} 1
+# TYPE test_latency_seconds gauge
+# UNIT test_latency_seconds seconds
+test_latency_seconds 0.25
+# TYPE test_requests counter
+# HELP test_requests Number of \\ requests.
+test_requests_total{path="/a\"b\n"} 3
+test_requests_total{path="/c"} 4
+# EOF
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestAddStats(t *testing.T) {
+	cgStats := cgroups.NewStats()
+	cgStats.CpuStats.CpuUsage.TotalUsage = 1500000000
+	cgStats.CpuStats.CpuUsage.PercpuUsage = []uint64{1000000000, 500000000}
+	cgStats.CpuStats.PSI = &cgroups.PSIStats{Some: cgroups.PSIData{Avg10: 1.5, Total: 2000000}}
+	cgStats.MemoryStats.Usage.Usage = 4096
+	cgStats.MemoryStats.Stats["pgfault"] = 42
+	cgStats.BlkioStats.IoServiceBytesRecursive = []cgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 512},
+	}
+	cgStats.HugetlbStats["2MB"] = cgroups.HugetlbStats{Usage: 2097152}
+	rdtStats := &intelrdt.Stats{
+		L3CacheSchema: "L3:0=ff",
+		CMTStats:      &[]intelrdt.CMTNumaNodeStats{{LLCOccupancy: 1024}},
+	}
+	ifaces := []*types.NetworkInterface{{Name: "eth0", RxBytes: 100}}
+
+	m := New()
+	labels := ContainerLabels("test", "/runc/test")
+	m.AddCgroupStats(cgStats, labels...)
+	m.AddIntelRdtStats(rdtStats, labels...)
+	m.AddNetworkInterfaces(ifaces, labels...)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`container_cpu_usage_seconds_total{container_id="test",cgroup_path="/runc/test"} 1.5`,
+		`container_cpu_usage_per_cpu_seconds_total{container_id="test",cgroup_path="/runc/test",cpu="1"} 0.5`,
+		`container_pressure_stalled_seconds_total{container_id="test",cgroup_path="/runc/test",resource="cpu",kind="some"} 2`,
+		`container_pressure_stalled_ratio{container_id="test",cgroup_path="/runc/test",resource="cpu",kind="some",window="10s"} 0.015`,
+		`container_memory_usage_bytes{container_id="test",cgroup_path="/runc/test"} 4096`,
+		`container_memory_stat{container_id="test",cgroup_path="/runc/test",stat="pgfault"} 42`,
+		`container_blkio_io_service_bytes_total{container_id="test",cgroup_path="/runc/test",device="8:0",op="Read"} 512`,
+		`container_hugetlb_usage_bytes{container_id="test",cgroup_path="/runc/test",pagesize="2MB"} 2097152`,
+		`container_intel_rdt_info{container_id="test",cgroup_path="/runc/test",l3_cache_schema="L3:0=ff",mem_bw_schema=""} 1`,
+		`container_intel_rdt_llc_occupancy_bytes{container_id="test",cgroup_path="/runc/test",numa_node="0"} 1024`,
+		`container_network_receive_bytes_total{container_id="test",cgroup_path="/runc/test",interface="eth0"} 100`,
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte("\n"+line+"\n")) {
+			t.Errorf("expected line %q in output:\n%s", line, out)
+		}
+	}
+}
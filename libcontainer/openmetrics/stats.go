@@ -0,0 +1,235 @@
+package openmetrics
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/types"
+)
+
+const prefix = "container_"
+
+// ContainerLabels returns the labels identifying the metrics of a container.
+// cgroupPath may be empty, in which case the label is omitted.
+func ContainerLabels(id, cgroupPath string) []Label {
+	labels := []Label{{Name: "container_id", Value: id}}
+	if cgroupPath != "" {
+		labels = append(labels, Label{Name: "cgroup_path", Value: cgroupPath})
+	}
+	return labels
+}
+
+// with returns the labels followed by the given extra label.
+func with(labels []Label, name, value string) []Label {
+	ret := make([]Label, 0, len(labels)+1)
+	ret = append(ret, labels...)
+	return append(ret, Label{Name: name, Value: value})
+}
+
+func seconds(ns uint64) string {
+	return Float(float64(ns) / 1e9)
+}
+
+// AddCgroupStats adds the metrics for the cgroup stats s, with the given
+// labels (usually ContainerLabels), to m.
+func (m *Metrics) AddCgroupStats(s *cgroups.Stats, labels ...Label) {
+	if s == nil {
+		return
+	}
+	m.addCPUStats(&s.CpuStats, labels)
+	m.addMemoryStats(&s.MemoryStats, labels)
+	m.addPidsStats(&s.PidsStats, labels)
+	m.addBlkioStats(&s.BlkioStats, labels)
+	m.addHugetlbStats(s.HugetlbStats, labels)
+	m.addRdmaStats(&s.RdmaStats, labels)
+}
+
+func (m *Metrics) addCPUStats(s *cgroups.CpuStats, labels []Label) {
+	u := &s.CpuUsage
+	m.Add(prefix+"cpu_usage_seconds", Counter, "seconds", "Total CPU time consumed.", seconds(u.TotalUsage), labels...)
+	m.Add(prefix+"cpu_kernel_seconds", Counter, "seconds", "CPU time consumed in kernel mode.", seconds(u.UsageInKernelmode), labels...)
+	m.Add(prefix+"cpu_user_seconds", Counter, "seconds", "CPU time consumed in user mode.", seconds(u.UsageInUsermode), labels...)
+	for i, v := range u.PercpuUsage {
+		m.Add(prefix+"cpu_usage_per_cpu_seconds", Counter, "seconds", "CPU time consumed per CPU.", seconds(v), with(labels, "cpu", strconv.Itoa(i))...)
+	}
+
+	t := &s.ThrottlingData
+	m.Add(prefix+"cpu_cfs_periods", Counter, "", "Number of elapsed enforcement periods.", Uint(t.Periods), labels...)
+	m.Add(prefix+"cpu_cfs_throttled_periods", Counter, "", "Number of throttled periods.", Uint(t.ThrottledPeriods), labels...)
+	m.Add(prefix+"cpu_cfs_throttled_seconds", Counter, "seconds", "Total time throttled.", seconds(t.ThrottledTime), labels...)
+
+	m.addPSIStats("cpu", s.PSI, labels)
+}
+
+func (m *Metrics) addPSIStats(resource string, s *cgroups.PSIStats, labels []Label) {
+	if s == nil {
+		return
+	}
+	labels = with(labels, "resource", resource)
+	for _, d := range []struct {
+		kind string
+		data *cgroups.PSIData
+	}{
+		{"some", &s.Some},
+		{"full", &s.Full},
+	} {
+		kindLabels := with(labels, "kind", d.kind)
+		// The totals are in microseconds.
+		m.Add(prefix+"pressure_stalled_seconds", Counter, "seconds", "Total time stalled waiting for the resource.", Float(float64(d.data.Total)/1e6), kindLabels...)
+		for _, avg := range []struct {
+			window string
+			value  float64
+		}{
+			{"10s", d.data.Avg10},
+			{"60s", d.data.Avg60},
+			{"300s", d.data.Avg300},
+		} {
+			m.Add(prefix+"pressure_stalled_ratio", Gauge, "ratio", "Ratio of time stalled waiting for the resource, averaged over the window.", Float(avg.value/100), with(kindLabels, "window", avg.window)...)
+		}
+	}
+}
+
+func (m *Metrics) addMemoryData(name, what string, d *cgroups.MemoryData, labels []Label) {
+	m.Add(prefix+name+"_usage_bytes", Gauge, "bytes", "Current "+what+" usage.", Uint(d.Usage), labels...)
+	m.Add(prefix+name+"_max_usage_bytes", Gauge, "bytes", "Maximum recorded "+what+" usage.", Uint(d.MaxUsage), labels...)
+	m.Add(prefix+name+"_limit_bytes", Gauge, "bytes", "The "+what+" limit.", Uint(d.Limit), labels...)
+	m.Add(prefix+name+"_failcnt", Counter, "", "Number of times the "+what+" limit was hit.", Uint(d.Failcnt), labels...)
+}
+
+func (m *Metrics) addMemoryStats(s *cgroups.MemoryStats, labels []Label) {
+	m.Add(prefix+"memory_cache_bytes", Gauge, "bytes", "Memory used for the page cache.", Uint(s.Cache), labels...)
+	m.addMemoryData("memory", "memory", &s.Usage, labels)
+	m.addMemoryData("memory_swap", "memory+swap", &s.SwapUsage, labels)
+	m.addMemoryData("memory_kernel", "kernel memory", &s.KernelUsage, labels)
+	m.addMemoryData("memory_kernel_tcp", "kernel TCP buffer memory", &s.KernelTCPUsage, labels)
+	keys := make([]string, 0, len(s.Stats))
+	for k := range s.Stats {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Add(prefix+"memory_stat", Gauge, "", "Raw memory controller statistics.", Uint(s.Stats[k]), with(labels, "stat", k)...)
+	}
+	m.addPSIStats("memory", s.PSI, labels)
+}
+
+func (m *Metrics) addPidsStats(s *cgroups.PidsStats, labels []Label) {
+	m.Add(prefix+"pids_current", Gauge, "", "Number of processes.", Uint(s.Current), labels...)
+	m.Add(prefix+"pids_limit", Gauge, "", "Maximum number of processes (0 if unlimited).", Uint(s.Limit), labels...)
+}
+
+func (m *Metrics) addBlkioStats(s *cgroups.BlkioStats, labels []Label) {
+	for _, e := range []struct {
+		name    string
+		typ     string
+		unit    string
+		help    string
+		entries []cgroups.BlkioStatEntry
+	}{
+		{"blkio_io_service_bytes", Counter, "bytes", "Number of bytes transferred to and from the device.", s.IoServiceBytesRecursive},
+		{"blkio_io_serviced", Counter, "", "Number of I/O operations performed on the device.", s.IoServicedRecursive},
+		{"blkio_io_queued", Gauge, "", "Number of I/O operations queued for the device.", s.IoQueuedRecursive},
+		{"blkio_io_service_time", Counter, "", "Total time between request dispatch and completion.", s.IoServiceTimeRecursive},
+		{"blkio_io_wait_time", Counter, "", "Total time spent waiting for service.", s.IoWaitTimeRecursive},
+		{"blkio_io_merged", Counter, "", "Number of merged I/O operations.", s.IoMergedRecursive},
+		{"blkio_io_time", Counter, "", "Disk time allocated per device.", s.IoTimeRecursive},
+		{"blkio_sectors", Counter, "", "Number of sectors transferred to and from the device.", s.SectorsRecursive},
+	} {
+		for _, entry := range e.entries {
+			entryLabels := with(labels, "device", strconv.FormatUint(entry.Major, 10)+":"+strconv.FormatUint(entry.Minor, 10))
+			if entry.Op != "" {
+				entryLabels = with(entryLabels, "op", entry.Op)
+			}
+			m.Add(prefix+e.name, e.typ, e.unit, e.help, Uint(entry.Value), entryLabels...)
+		}
+	}
+	m.addPSIStats("io", s.PSI, labels)
+}
+
+func (m *Metrics) addHugetlbStats(s map[string]cgroups.HugetlbStats, labels []Label) {
+	sizes := make([]string, 0, len(s))
+	for size := range s {
+		sizes = append(sizes, size)
+	}
+	sort.Strings(sizes)
+	for _, size := range sizes {
+		h := s[size]
+		sizeLabels := with(labels, "pagesize", size)
+		m.Add(prefix+"hugetlb_usage_bytes", Gauge, "bytes", "Current hugetlb usage.", Uint(h.Usage), sizeLabels...)
+		m.Add(prefix+"hugetlb_max_usage_bytes", Gauge, "bytes", "Maximum recorded hugetlb usage.", Uint(h.MaxUsage), sizeLabels...)
+		m.Add(prefix+"hugetlb_failcnt", Counter, "", "Number of hugetlb allocation failures.", Uint(h.Failcnt), sizeLabels...)
+	}
+}
+
+func (m *Metrics) addRdmaStats(s *cgroups.RdmaStats, labels []Label) {
+	for _, e := range []struct {
+		name    string
+		help    string
+		entries []cgroups.RdmaEntry
+	}{
+		{"rdma_current", "Current", s.RdmaCurrent},
+		{"rdma_limit", "Maximum", s.RdmaLimit},
+	} {
+		for _, entry := range e.entries {
+			devLabels := with(labels, "device", entry.Device)
+			m.Add(prefix+e.name+"_hca_handles", Gauge, "", e.help+" number of HCA handles.", Uint(uint64(entry.HcaHandles)), devLabels...)
+			m.Add(prefix+e.name+"_hca_objects", Gauge, "", e.help+" number of HCA objects.", Uint(uint64(entry.HcaObjects)), devLabels...)
+		}
+	}
+}
+
+// AddIntelRdtStats adds the metrics for the Intel RDT stats s, with the
+// given labels (usually ContainerLabels), to m.
+func (m *Metrics) AddIntelRdtStats(s *intelrdt.Stats, labels ...Label) {
+	if s == nil {
+		return
+	}
+	if s.L3CacheSchema != "" || s.MemBwSchema != "" {
+		infoLabels := with(labels, "l3_cache_schema", s.L3CacheSchema)
+		infoLabels = with(infoLabels, "mem_bw_schema", s.MemBwSchema)
+		m.Add(prefix+"intel_rdt", Info, "", "Intel RDT schemata of the container.", "1", infoLabels...)
+	}
+	if s.MBMStats != nil {
+		for i, n := range *s.MBMStats {
+			nodeLabels := with(labels, "numa_node", strconv.Itoa(i))
+			m.Add(prefix+"intel_rdt_mbm_total_bytes", Counter, "bytes", "Total memory bandwidth used.", Uint(n.MBMTotalBytes), nodeLabels...)
+			m.Add(prefix+"intel_rdt_mbm_local_bytes", Counter, "bytes", "Local memory bandwidth used.", Uint(n.MBMLocalBytes), nodeLabels...)
+		}
+	}
+	if s.CMTStats != nil {
+		for i, n := range *s.CMTStats {
+			nodeLabels := with(labels, "numa_node", strconv.Itoa(i))
+			m.Add(prefix+"intel_rdt_llc_occupancy_bytes", Gauge, "bytes", "Last level cache occupancy.", Uint(n.LLCOccupancy), nodeLabels...)
+		}
+	}
+}
+
+// AddNetworkInterfaces adds the metrics for the network interfaces, with the
+// given labels (usually ContainerLabels), to m.
+func (m *Metrics) AddNetworkInterfaces(ifaces []*types.NetworkInterface, labels ...Label) {
+	for _, iface := range ifaces {
+		if iface == nil {
+			continue
+		}
+		ifLabels := with(labels, "interface", iface.Name)
+		for _, c := range []struct {
+			name  string
+			unit  string
+			help  string
+			value uint64
+		}{
+			{"network_receive_bytes", "bytes", "Number of bytes received.", iface.RxBytes},
+			{"network_receive_packets", "", "Number of packets received.", iface.RxPackets},
+			{"network_receive_errors", "", "Number of errors while receiving.", iface.RxErrors},
+			{"network_receive_dropped", "", "Number of packets dropped while receiving.", iface.RxDropped},
+			{"network_transmit_bytes", "bytes", "Number of bytes transmitted.", iface.TxBytes},
+			{"network_transmit_packets", "", "Number of packets transmitted.", iface.TxPackets},
+			{"network_transmit_errors", "", "Number of errors while transmitting.", iface.TxErrors},
+			{"network_transmit_dropped", "", "Number of packets dropped while transmitting.", iface.TxDropped},
+		} {
+			m.Add(prefix+c.name, Counter, c.unit, c.help, Uint(c.value), ifLabels...)
+		}
+	}
+}
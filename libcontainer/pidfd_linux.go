@@ -0,0 +1,111 @@
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	errProcessDone      = errors.New("process already finished")
+	errPidfdUnavailable = errors.New("pidfd not available")
+)
+
+// openPidfd returns a pidfd referring to the process pid. Unless pid is a
+// child of the caller that has not been waited for yet, the pidfd may refer
+// to another process having reused the pid, and has to be checked using
+// pidfdInode or the process start time.
+func openPidfd(pid int) (*os.File, error) {
+	fd, err := system.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil, &os.SyscallError{Syscall: "pidfd_open", Err: err}
+	}
+	return os.NewFile(uintptr(fd), "pidfd:"+strconv.Itoa(pid)), nil
+}
+
+// dupPidfd returns a duplicate of pidfd, for the caller to own.
+func dupPidfd(pidfd *os.File) (*os.File, error) {
+	if pidfd == nil {
+		return nil, errPidfdUnavailable
+	}
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	newFd := -1
+	var dupErr error
+	if err := conn.Control(func(fd uintptr) {
+		newFd, dupErr = unix.FcntlInt(fd, unix.F_DUPFD_CLOEXEC, 0)
+	}); err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, os.NewSyscallError("fcntl", dupErr)
+	}
+	return os.NewFile(uintptr(newFd), pidfd.Name()), nil
+}
+
+// pidfdInode returns the inode number of pidfd which, when pidfds are backed
+// by pidfs (Linux >= 6.9), uniquely identifies the process across pidfds,
+// or 0 if it does not.
+func pidfdInode(pidfd *os.File) uint64 {
+	if pidfd == nil {
+		return 0
+	}
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var ino uint64
+	_ = conn.Control(func(fd uintptr) {
+		var fs unix.Statfs_t
+		if err := unix.Fstatfs(int(fd), &fs); err != nil || fs.Type != system.PIDFS_MAGIC {
+			return
+		}
+		var st unix.Stat_t
+		if err := unix.Fstat(int(fd), &st); err == nil {
+			ino = st.Ino
+		}
+	})
+	return ino
+}
+
+// signalProcess sends sig to the process referred to by pidfd or, if pidfd
+// is nil (when pidfds are not supported), to pid.
+func signalProcess(pidfd *os.File, pid int, sig os.Signal) error {
+	s, ok := sig.(unix.Signal)
+	if !ok {
+		return errors.New("os: unsupported signal type")
+	}
+	if pidfd == nil {
+		return unix.Kill(pid, s)
+	}
+	conn, err := pidfd.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sigErr error
+	if err := conn.Control(func(fd uintptr) {
+		sigErr = system.PidfdSendSignal(int(fd), s, 0)
+	}); err != nil {
+		return err
+	}
+	return sigErr
+}
+
+// childPidfd opens a pidfd for pid, a child of ours that has not been waited
+// for yet, so that it can't have been reused. If this fails (for example
+// because the kernel is older than 5.3), nil is returned, and the process is
+// tracked by its pid alone.
+func childPidfd(pid int) *os.File {
+	pidfd, err := openPidfd(pid)
+	if err != nil {
+		logrus.Debugf("unable to track pid %d using a pidfd: %v", pid, err)
+		return nil
+	}
+	return pidfd
+}
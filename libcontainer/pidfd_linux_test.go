@@ -0,0 +1,59 @@
+package libcontainer
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+	"golang.org/x/sys/unix"
+)
+
+func TestNonChildProcessSignal(t *testing.T) {
+	cmd := exec.Command("sleep", "1h")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+	pidfd, err := openPidfd(pid)
+	if err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			t.Skip("pidfds are not supported")
+		}
+		t.Fatal(err)
+	}
+	ino := pidfdInode(pidfd)
+	pidfd.Close()
+	stat, err := system.Stat(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A process having reused the pid must not be signalled.
+	reused := []*nonChildProcess{
+		{processPid: pid, processStartTime: stat.StartTime + 1},
+	}
+	if ino != 0 {
+		reused = append(reused, &nonChildProcess{processPid: pid, processStartTime: stat.StartTime, processPidfdInode: ino + 1})
+	}
+	for _, p := range reused {
+		if err := p.signal(unix.Signal(0)); !errors.Is(err, errProcessDone) {
+			t.Errorf("expected %v, got %v", errProcessDone, err)
+		}
+	}
+
+	p := &nonChildProcess{processPid: pid, processStartTime: stat.StartTime, processPidfdInode: ino}
+	if err := p.signal(unix.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected the process to be killed")
+	}
+	if err := p.signal(unix.Signal(0)); !errors.Is(err, errProcessDone) {
+		t.Errorf("expected %v once the process is gone, got %v", errProcessDone, err)
+	}
+}
@@ -15,6 +15,7 @@ type processOperations interface {
 	wait() (*os.ProcessState, error)
 	signal(sig os.Signal) error
 	pid() int
+	pidfd() (*os.File, error)
 }
 
 // Process specifies the configuration and IO for a process inside
@@ -74,6 +75,17 @@ type Process struct {
 	// ConsoleSocket provides the masterfd console.
 	ConsoleSocket *os.File
 
+	// SubCgroupPaths specifies sub-cgroups of the container's cgroups to run
+	// a non-init process in, which are created if they do not exist. The keys
+	// are controller names (as in cgroups.Manager.GetPaths), or "" for all
+	// of them (and for cgroup v2), and the values paths relative to the
+	// container's cgroup for the controller.
+	SubCgroupPaths map[string]string
+
+	// SubCgroupResources, if set, specifies the resources of the sub-cgroups
+	// of SubCgroupPaths.
+	SubCgroupResources *configs.Resources
+
 	// Init specifies whether the process is the first process in the container.
 	Init bool
 
@@ -109,6 +121,16 @@ func (p Process) Signal(sig os.Signal) error {
 	return p.ops.signal(sig)
 }
 
+// Pidfd returns a pidfd referring to the process (see pidfd_open(2)), which,
+// unlike its pid, can't end up referring to another process. It is up to the
+// caller to close it.
+func (p Process) Pidfd() (*os.File, error) {
+	if p.ops == nil {
+		return nil, errInvalidProcess
+	}
+	return p.ops.pidfd()
+}
+
 // IO holds the process's STDIO
 type IO struct {
 	Stdin  io.WriteCloser
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
@@ -41,6 +43,14 @@ type parentProcess interface {
 
 	signal(os.Signal) error
 
+	// pidfd returns a new pidfd referring to the process, for the caller to
+	// close.
+	pidfd() (*os.File, error)
+
+	// pidfdInode returns the inode number of the process's pidfds, if they
+	// uniquely identify it (see pidfdInode), or 0.
+	pidfdInode() uint64
+
 	externalDescriptors() []string
 
 	setExternalDescriptors(fds []string)
@@ -58,6 +68,7 @@ type setnsProcess struct {
 	messageSockPair filePair
 	logFilePair     filePair
 	cgroupPaths     map[string]string
+	subCgroups      bool
 	rootlessCgroups bool
 	manager         cgroups.Manager
 	intelRdtPath    string
@@ -66,6 +77,11 @@ type setnsProcess struct {
 	process         *Process
 	bootstrapData   io.Reader
 	initProcessPid  int
+	// pidfd refers to the process once it is started, unless pidfds are not
+	// supported. It is closed once the process is reaped, so pidfdMu guards
+	// it against the concurrent signals.
+	pidfdMu   sync.Mutex
+	pidfdFile *os.File
 }
 
 func (p *setnsProcess) startTime() (uint64, error) {
@@ -74,18 +90,34 @@ func (p *setnsProcess) startTime() (uint64, error) {
 }
 
 func (p *setnsProcess) signal(sig os.Signal) error {
-	s, ok := sig.(unix.Signal)
-	if !ok {
-		return errors.New("os: unsupported signal type")
-	}
-	return unix.Kill(p.pid(), s)
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return signalProcess(p.pidfdFile, p.pid(), sig)
+}
+
+func (p *setnsProcess) pidfd() (*os.File, error) {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return dupPidfd(p.pidfdFile)
+}
+
+func (p *setnsProcess) pidfdInode() uint64 {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return pidfdInode(p.pidfdFile)
 }
 
 func (p *setnsProcess) start() (retErr error) {
 	defer p.messageSockPair.parent.Close()
 	// get the "before" value of oom kill count
 	oom, _ := p.manager.OOMKillCount()
-	err := p.cmd.Start()
+	inCgroup := false
+	var err error
+	if len(p.cgroupPaths) > 0 {
+		p.cmd, inCgroup, err = startInCgroup(p.cmd, p.manager)
+	} else {
+		err = p.cmd.Start()
+	}
 	// close the write-side of the pipes (controlled by child)
 	p.messageSockPair.child.Close()
 	p.logFilePair.child.Close()
@@ -123,12 +155,13 @@ func (p *setnsProcess) start() (retErr error) {
 	if err := p.execSetns(); err != nil {
 		return fmt.Errorf("error executing setns process: %w", err)
 	}
-	if len(p.cgroupPaths) > 0 {
+	if len(p.cgroupPaths) > 0 && !inCgroup {
 		if err := cgroups.EnterPid(p.cgroupPaths, p.pid()); err != nil && !p.rootlessCgroups {
 			// On cgroup v2 + nesting + domain controllers, EnterPid may fail with EBUSY.
 			// https://github.com/opencontainers/runc/issues/2356#issuecomment-621277643
-			// Try to join the cgroup of InitProcessPid.
-			if cgroups.IsCgroup2UnifiedMode() {
+			// Try to join the cgroup of InitProcessPid, unless the process is
+			// to be run in a sub-cgroup.
+			if cgroups.IsCgroup2UnifiedMode() && !p.subCgroups {
 				initProcCgroupFile := fmt.Sprintf("/proc/%d/cgroup", p.initProcessPid)
 				initCg, initCgErr := cgroups.ParseCgroupFile(initProcCgroupFile)
 				if initCgErr == nil {
@@ -172,6 +205,17 @@ func (p *setnsProcess) start() (retErr error) {
 		case procHooks:
 			// This shouldn't happen.
 			panic("unexpected procHooks in setns")
+		case procSeccomp:
+			bundle, annotations := utils.Annotations(p.config.Config.Labels)
+			s := &specs.State{
+				Version:     specs.Version,
+				ID:          p.config.ContainerId,
+				Status:      specs.StateRunning,
+				Pid:         p.initProcessPid,
+				Bundle:      bundle,
+				Annotations: annotations,
+			}
+			return recvSeccompFd(p.messageSockPair.parent, p.config.Config.Seccomp, s, p.pid())
 		default:
 			return errors.New("invalid JSON payload from child")
 		}
@@ -188,6 +232,46 @@ func (p *setnsProcess) start() (retErr error) {
 	return nil
 }
 
+// startInCgroup starts cmd directly in the cgroup of m (see
+// cgroups.Manager.CgroupFD), so that neither the process nor its children
+// ever run outside of it. If that's not possible (cgroup v1, Linux < 5.7, Go
+// < 1.20, or a cgroup that can't be created before a process is added to
+// it, such as the systemd unit of the init process), cmd is started in our
+// own cgroup instead, to be moved to the cgroup by the caller. It returns
+// the started command, as cmd can't be reused once it failed to start, and
+// whether it was started in the cgroup.
+func startInCgroup(cmd *exec.Cmd, m cgroups.Manager) (*exec.Cmd, bool, error) {
+	if !canStartInCgroup || !cgroups.IsCgroup2UnifiedMode() {
+		return cmd, false, cmd.Start()
+	}
+	cgroupFD, err := m.CgroupFD()
+	if err != nil {
+		logrus.Debugf("unable to start %s in its cgroup: %v", cmd.Path, err)
+		return cmd, false, cmd.Start()
+	}
+	defer cgroupFD.Close()
+	// Keep a copy, to retry with, should clone3 fail.
+	fallback := &exec.Cmd{
+		Path:       cmd.Path,
+		Args:       cmd.Args,
+		Env:        cmd.Env,
+		Dir:        cmd.Dir,
+		Stdin:      cmd.Stdin,
+		Stdout:     cmd.Stdout,
+		Stderr:     cmd.Stderr,
+		ExtraFiles: cmd.ExtraFiles,
+	}
+	if cmd.SysProcAttr != nil {
+		attr := *cmd.SysProcAttr
+		fallback.SysProcAttr = &attr
+	}
+	if err := startWithCgroupFD(cmd, cgroupFD); err != nil {
+		logrus.Debugf("unable to start %s with CLONE_INTO_CGROUP, retrying without: %v", cmd.Path, err)
+		return fallback, false, fallback.Start()
+	}
+	return cmd, true, nil
+}
+
 // execSetns runs the process that executes C code to perform the setns calls
 // because setns support requires the C process to fork off a child and perform the setns
 // before the go runtime boots, we wait on the process to die and receive the child's pid
@@ -220,6 +304,9 @@ func (p *setnsProcess) execSetns() error {
 		return err
 	}
 	p.cmd.Process = process
+	p.pidfdMu.Lock()
+	p.pidfdFile = childPidfd(pid.Pid)
+	p.pidfdMu.Unlock()
 	p.process.ops = p
 	return nil
 }
@@ -237,8 +324,19 @@ func (p *setnsProcess) terminate() error {
 	return err
 }
 
+// closePidfd closes the pidfd of the process, once it is reaped.
+func (p *setnsProcess) closePidfd() {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	if p.pidfdFile != nil {
+		_ = p.pidfdFile.Close()
+		p.pidfdFile = nil
+	}
+}
+
 func (p *setnsProcess) wait() (*os.ProcessState, error) {
 	err := p.cmd.Wait()
+	p.closePidfd()
 
 	// Return actual ProcessState even on Wait error
 	return p.cmd.ProcessState, err
@@ -272,6 +370,12 @@ type initProcess struct {
 	process         *Process
 	bootstrapData   io.Reader
 	sharePidns      bool
+	mountFiles      []*os.File
+	// pidfd refers to the container's init once it is started, unless
+	// pidfds are not supported. It is closed once init is reaped, so
+	// pidfdMu guards it against the concurrent signals.
+	pidfdMu   sync.Mutex
+	pidfdFile *os.File
 }
 
 func (p *initProcess) pid() int {
@@ -316,17 +420,25 @@ func (p *initProcess) waitForChildExit(childPid int) error {
 		return err
 	}
 	p.cmd.Process = process
+	p.pidfdMu.Lock()
+	p.pidfdFile = childPidfd(childPid)
+	p.pidfdMu.Unlock()
 	p.process.ops = p
 	return nil
 }
 
 func (p *initProcess) start() (retErr error) {
 	defer p.messageSockPair.parent.Close() //nolint: errcheck
-	err := p.cmd.Start()
+	var err error
+	p.cmd, _, err = startInCgroup(p.cmd, p.manager)
 	p.process.ops = p
 	// close the write-side of the pipes (controlled by child)
 	_ = p.messageSockPair.child.Close()
 	_ = p.logFilePair.child.Close()
+	// the id-mapped mounts are owned by the child now
+	for _, f := range p.mountFiles {
+		_ = f.Close()
+	}
 	if err != nil {
 		p.process.ops = nil
 		return fmt.Errorf("unable to start init: %w", err)
@@ -521,6 +633,17 @@ func (p *initProcess) start() (retErr error) {
 				return fmt.Errorf("error writing syncT 'resume': %w", err)
 			}
 			sentResume = true
+		case procSeccomp:
+			s, err := p.container.currentOCIState()
+			if err != nil {
+				return err
+			}
+			// initProcessStartTime hasn't been set yet.
+			s.Pid = p.cmd.Process.Pid
+			s.Status = specs.StateCreating
+			if err := recvSeccompFd(p.messageSockPair.parent, p.config.Config.Seccomp, s, s.Pid); err != nil {
+				return err
+			}
 		default:
 			return errors.New("invalid JSON payload from child")
 		}
@@ -546,8 +669,19 @@ func (p *initProcess) start() (retErr error) {
 	return nil
 }
 
+// closePidfd closes the pidfd of init, once it is reaped.
+func (p *initProcess) closePidfd() {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	if p.pidfdFile != nil {
+		_ = p.pidfdFile.Close()
+		p.pidfdFile = nil
+	}
+}
+
 func (p *initProcess) wait() (*os.ProcessState, error) {
 	err := p.cmd.Wait()
+	p.closePidfd()
 	// we should kill all processes in cgroup when init is died if we use host PID namespace
 	if p.sharePidns {
 		_ = signalAllProcesses(p.manager, unix.SIGKILL)
@@ -606,11 +740,21 @@ func (p *initProcess) createNetworkInterfaces() error {
 }
 
 func (p *initProcess) signal(sig os.Signal) error {
-	s, ok := sig.(unix.Signal)
-	if !ok {
-		return errors.New("os: unsupported signal type")
-	}
-	return unix.Kill(p.pid(), s)
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return signalProcess(p.pidfdFile, p.pid(), sig)
+}
+
+func (p *initProcess) pidfd() (*os.File, error) {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return dupPidfd(p.pidfdFile)
+}
+
+func (p *initProcess) pidfdInode() uint64 {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return pidfdInode(p.pidfdFile)
 }
 
 func (p *initProcess) setExternalDescriptors(newFds []string) {
@@ -621,6 +765,61 @@ func (p *initProcess) forwardChildLogs() chan error {
 	return logs.ForwardLogs(p.logFilePair.parent)
 }
 
+// recvSeccompFd receives the seccomp notify fd from the child over the init
+// pipe, and sends it to the seccomp agent listening on the ListenerPath of
+// the given seccomp config, together with the state of the container process
+// identified by pid.
+func recvSeccompFd(pipe *os.File, config *configs.Seccomp, s *specs.State, pid int) error {
+	if config == nil || config.ListenerPath == "" {
+		return errors.New("seccomp listenerPath is not set")
+	}
+	if err := writeSync(pipe, procSeccompFd); err != nil {
+		return fmt.Errorf("error writing syncT 'seccompFd': %w", err)
+	}
+	seccompFd, err := utils.RecvFd(pipe)
+	if err != nil {
+		return fmt.Errorf("unable to receive seccomp notify fd: %w", err)
+	}
+	defer seccompFd.Close()
+
+	processState := &specs.ContainerProcessState{
+		Version:  specs.Version,
+		Fds:      []string{specs.SeccompFdName},
+		Pid:      pid,
+		Metadata: config.ListenerMetadata,
+		State:    *s,
+	}
+	if err := sendContainerProcessState(config.ListenerPath, processState, seccompFd); err != nil {
+		return err
+	}
+	// Sync with child.
+	if err := writeSync(pipe, procSeccompDone); err != nil {
+		return fmt.Errorf("error writing syncT 'seccompDone': %w", err)
+	}
+	return nil
+}
+
+// sendContainerProcessState sends the container process state as JSON, along
+// with the given file descriptor, to the seccomp agent listening on
+// listenerPath.
+func sendContainerProcessState(listenerPath string, state *specs.ContainerProcessState, fd *os.File) error {
+	conn, err := net.Dial("unix", listenerPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect with seccomp agent specified in the seccomp profile: %w", err)
+	}
+	defer conn.Close()
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("cannot marshal seccomp state: %w", err)
+	}
+	oob := unix.UnixRights(int(fd.Fd()))
+	if _, _, err := conn.(*net.UnixConn).WriteMsgUnix(b, oob, nil); err != nil {
+		return fmt.Errorf("cannot send seccomp fd to %s: %w", listenerPath, err)
+	}
+	return nil
+}
+
 func getPipeFds(pid int) ([]string, error) {
 	fds := make([]string, 3)
 
@@ -0,0 +1,41 @@
+package libcontainer
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestStartInCgroupFallback(t *testing.T) {
+	if !canStartInCgroup || !cgroups.IsCgroup2UnifiedMode() {
+		t.Skip("requires cgroup v2 and Go >= 1.20")
+	}
+	// Not a cgroup, so clone3 fails, and the command has to be started
+	// again the usual way.
+	m := &mockCgroupManager{cgroupFDPath: t.TempDir()}
+	cmd, inCgroup, err := startInCgroup(exec.Command("true"), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inCgroup {
+		t.Error("expected the command not to be started in the cgroup")
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartInCgroupUnsupported(t *testing.T) {
+	m := &mockCgroupManager{}
+	cmd, inCgroup, err := startInCgroup(exec.Command("true"), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inCgroup {
+		t.Error("expected the command not to be started in the cgroup")
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
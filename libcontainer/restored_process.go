@@ -4,8 +4,11 @@ import (
 	"errors"
 	"os"
 	"os/exec"
+	"sync"
 
 	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 )
 
 func newRestoredProcess(cmd *exec.Cmd, fds []string) (*restoredProcess, error) {
@@ -19,6 +22,7 @@ func newRestoredProcess(cmd *exec.Cmd, fds []string) (*restoredProcess, error) {
 		cmd:              cmd,
 		processStartTime: stat.StartTime,
 		fds:              fds,
+		pidfdFile:        childPidfd(pid),
 	}, nil
 }
 
@@ -26,6 +30,10 @@ type restoredProcess struct {
 	cmd              *exec.Cmd
 	processStartTime uint64
 	fds              []string
+	// pidfdMu guards pidfdFile, which is closed once the process is reaped,
+	// against the concurrent signals.
+	pidfdMu   sync.Mutex
+	pidfdFile *os.File
 }
 
 func (p *restoredProcess) start() error {
@@ -48,6 +56,12 @@ func (p *restoredProcess) wait() (*os.ProcessState, error) {
 	// TODO: how do we wait on the actual process?
 	// maybe use --exec-cmd in criu
 	err := p.cmd.Wait()
+	p.pidfdMu.Lock()
+	if p.pidfdFile != nil {
+		_ = p.pidfdFile.Close()
+		p.pidfdFile = nil
+	}
+	p.pidfdMu.Unlock()
 	if err != nil {
 		var exitErr *exec.ExitError
 		if !errors.As(err, &exitErr) {
@@ -63,7 +77,24 @@ func (p *restoredProcess) startTime() (uint64, error) {
 }
 
 func (p *restoredProcess) signal(s os.Signal) error {
-	return p.cmd.Process.Signal(s)
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	if p.pidfdFile == nil {
+		return p.cmd.Process.Signal(s)
+	}
+	return signalProcess(p.pidfdFile, p.pid(), s)
+}
+
+func (p *restoredProcess) pidfd() (*os.File, error) {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return dupPidfd(p.pidfdFile)
+}
+
+func (p *restoredProcess) pidfdInode() uint64 {
+	p.pidfdMu.Lock()
+	defer p.pidfdMu.Unlock()
+	return pidfdInode(p.pidfdFile)
 }
 
 func (p *restoredProcess) externalDescriptors() []string {
@@ -82,9 +113,10 @@ func (p *restoredProcess) forwardChildLogs() chan error {
 // the parent process.  This process is created when a factory loads a container from
 // a persisted state.
 type nonChildProcess struct {
-	processPid       int
-	processStartTime uint64
-	fds              []string
+	processPid        int
+	processStartTime  uint64
+	processPidfdInode uint64
+	fds               []string
 }
 
 func (p *nonChildProcess) start() error {
@@ -108,6 +140,20 @@ func (p *nonChildProcess) startTime() (uint64, error) {
 }
 
 func (p *nonChildProcess) signal(s os.Signal) error {
+	pidfd, err := p.pidfd()
+	switch {
+	case err == nil:
+		defer pidfd.Close()
+		return signalProcess(pidfd, p.processPid, s)
+	case errors.Is(err, errProcessDone):
+		return err
+	}
+	// Without pidfds, fall back to checking the start time, which still
+	// leaves a (narrow) window for the pid to be reused.
+	logrus.Debugf("unable to use a pidfd, falling back to pid %d: %v", p.processPid, err)
+	if err := p.checkStartTime(); err != nil {
+		return err
+	}
 	proc, err := os.FindProcess(p.processPid)
 	if err != nil {
 		return err
@@ -115,6 +161,48 @@ func (p *nonChildProcess) signal(s os.Signal) error {
 	return proc.Signal(s)
 }
 
+// pidfd opens a pidfd for the process and, as it is not our child and its
+// pid may have been reused, checks that it refers to the recorded process.
+func (p *nonChildProcess) pidfd() (*os.File, error) {
+	pidfd, err := openPidfd(p.processPid)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return nil, errProcessDone
+		}
+		return nil, err
+	}
+	// Once the pidfd is open, the process it refers to is known for sure,
+	// so this check can't race with the pid being reused.
+	if p.processPidfdInode != 0 {
+		if ino := pidfdInode(pidfd); ino != 0 {
+			if ino != p.processPidfdInode {
+				pidfd.Close()
+				return nil, errProcessDone
+			}
+			return pidfd, nil
+		}
+	}
+	if err := p.checkStartTime(); err != nil {
+		pidfd.Close()
+		return nil, err
+	}
+	return pidfd, nil
+}
+
+func (p *nonChildProcess) pidfdInode() uint64 {
+	return p.processPidfdInode
+}
+
+// checkStartTime checks that the process with our pid is the one that was
+// recorded, rather than one having reused its pid.
+func (p *nonChildProcess) checkStartTime() error {
+	stat, err := system.Stat(p.processPid)
+	if err != nil || stat.StartTime != p.processStartTime {
+		return errProcessDone
+	}
+	return nil
+}
+
 func (p *nonChildProcess) externalDescriptors() []string {
 	return p.fds
 }
@@ -36,6 +36,9 @@ type mountConfig struct {
 	cgroup2Path     string
 	rootlessCgroups bool
 	cgroupns        bool
+	// fd, if not nil, is a detached id-mapped mount (see idmappedMount)
+	// to be attached instead of bind mounting the mount source.
+	fd *int
 }
 
 // needsSetupDev returns true if /dev needs to be set up.
@@ -51,12 +54,19 @@ func needsSetupDev(config *configs.Config) bool {
 // prepareRootfs sets up the devices, mount points, and filesystems for use
 // inside a new mount namespace. It doesn't set anything as ro. You must call
 // finalizeRootfs after this function to finish setting up the rootfs.
-func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig) (err error) {
+//
+// If mountFds is not nil, it contains an fd for every entry in
+// config.Mounts, which is -1 unless the mount is id-mapped.
+func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig, mountFds []int) (err error) {
 	config := iConfig.Config
 	if err := prepareRoot(config); err != nil {
 		return fmt.Errorf("error preparing rootfs: %w", err)
 	}
 
+	if mountFds != nil && len(mountFds) != len(config.Mounts) {
+		return fmt.Errorf("malformed mountFds slice. Expected size: %d, got: %d", len(config.Mounts), len(mountFds))
+	}
+
 	mountConfig := &mountConfig{
 		root:            config.Rootfs,
 		label:           config.MountLabel,
@@ -65,12 +75,17 @@ func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig) (err error) {
 		cgroupns:        config.Namespaces.Contains(configs.NEWCGROUP),
 	}
 	setupDev := needsSetupDev(config)
-	for _, m := range config.Mounts {
+	for i, m := range config.Mounts {
 		for _, precmd := range m.PremountCmds {
 			if err := mountCmd(precmd); err != nil {
 				return fmt.Errorf("error running premount command: %w", err)
 			}
 		}
+		// We checked above that mountFds, if set, has an entry per mount.
+		mountConfig.fd = nil
+		if mountFds != nil && mountFds[i] != -1 {
+			mountConfig.fd = &mountFds[i]
+		}
 		if err := mountToRootfs(m, mountConfig); err != nil {
 			return fmt.Errorf("error mounting %q to rootfs at %q: %w", m.Source, m.Destination, err)
 		}
@@ -442,7 +457,11 @@ func mountToRootfs(m *configs.Mount, c *mountConfig) error {
 		if err := prepareBindMount(m, rootfs); err != nil {
 			return err
 		}
-		if err := mountPropagate(m, rootfs, mountLabel); err != nil {
+		if c.fd != nil {
+			if err := mountIDMapped(m, rootfs, *c.fd); err != nil {
+				return err
+			}
+		} else if err := mountPropagate(m, rootfs, mountLabel); err != nil {
 			return err
 		}
 		// bind mount won't change mount options, we need remount to make mount options effective.
@@ -1043,6 +1062,27 @@ func remount(m *configs.Mount, rootfs string) error {
 	})
 }
 
+// mountIDMapped attaches the detached id-mapped mount fd, created by the
+// parent using idmappedMount, to the mount destination and applies the mount
+// propagation flags. The fd is closed afterwards.
+func mountIDMapped(m *configs.Mount, rootfs string, fd int) error {
+	defer unix.Close(fd) //nolint:errcheck
+	if err := utils.WithProcfd(rootfs, m.Destination, func(procfd string) error {
+		return moveMount(fd, m.Destination, procfd)
+	}); err != nil {
+		return err
+	}
+	// As in mountPropagate, the mount target needs to be re-opened.
+	return utils.WithProcfd(rootfs, m.Destination, func(procfd string) error {
+		for _, pflag := range m.PropagationFlags {
+			if err := mount("", m.Destination, procfd, "", uintptr(pflag), ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Do the mount operation followed by additional mounts required to take care
 // of propagation flags. This will always be scoped inside the container rootfs.
 func mountPropagate(m *configs.Mount, rootfs string, mountLabel string) error {
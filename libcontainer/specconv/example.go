@@ -228,3 +228,28 @@ func ToRootless(spec *specs.Spec) {
 	// Remove cgroup settings.
 	spec.Linux.Resources = nil
 }
+
+// ToUsernsAuto converts the given spec into one with a user namespace mapped
+// to free subordinate IDs of the user creating the container (see
+// UsernsAnnotation), rather than to fixed IDs.
+func ToUsernsAuto(spec *specs.Spec) {
+	hasUserns := false
+	for _, ns := range spec.Linux.Namespaces {
+		if ns.Type == specs.UserNamespace {
+			hasUserns = true
+			break
+		}
+	}
+	if !hasUserns {
+		spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{
+			Type: specs.UserNamespace,
+		})
+	}
+	spec.Linux.UIDMappings = nil
+	spec.Linux.GIDMappings = nil
+
+	if spec.Annotations == nil {
+		spec.Annotations = make(map[string]string)
+	}
+	spec.Annotations[UsernsAnnotation] = "auto"
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/devices"
 	"github.com/opencontainers/runc/libcontainer/seccomp"
+	"github.com/opencontainers/runc/libcontainer/user"
 	libcontainerUtils "github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -32,6 +35,7 @@ var namespaceMapping = map[specs.LinuxNamespaceType]configs.NamespaceType{
 	specs.IPCNamespace:     configs.NEWIPC,
 	specs.UTSNamespace:     configs.NEWUTS,
 	specs.CgroupNamespace:  configs.NEWCGROUP,
+	specs.TimeNamespace:    configs.NEWTIME,
 }
 
 var mountPropagationMapping = map[string]int{
@@ -46,6 +50,82 @@ var mountPropagationMapping = map[string]int{
 	"":            0,
 }
 
+var mountFlags = map[string]struct {
+	clear bool
+	flag  int
+}{
+	"acl":           {false, unix.MS_POSIXACL},
+	"async":         {true, unix.MS_SYNCHRONOUS},
+	"atime":         {true, unix.MS_NOATIME},
+	"bind":          {false, unix.MS_BIND},
+	"defaults":      {false, 0},
+	"dev":           {true, unix.MS_NODEV},
+	"diratime":      {true, unix.MS_NODIRATIME},
+	"dirsync":       {false, unix.MS_DIRSYNC},
+	"exec":          {true, unix.MS_NOEXEC},
+	"iversion":      {false, unix.MS_I_VERSION},
+	"lazytime":      {false, unix.MS_LAZYTIME},
+	"loud":          {true, unix.MS_SILENT},
+	"mand":          {false, unix.MS_MANDLOCK},
+	"noacl":         {true, unix.MS_POSIXACL},
+	"noatime":       {false, unix.MS_NOATIME},
+	"nodev":         {false, unix.MS_NODEV},
+	"nodiratime":    {false, unix.MS_NODIRATIME},
+	"noexec":        {false, unix.MS_NOEXEC},
+	"noiversion":    {true, unix.MS_I_VERSION},
+	"nolazytime":    {true, unix.MS_LAZYTIME},
+	"nomand":        {true, unix.MS_MANDLOCK},
+	"norelatime":    {true, unix.MS_RELATIME},
+	"nostrictatime": {true, unix.MS_STRICTATIME},
+	"nosuid":        {false, unix.MS_NOSUID},
+	"rbind":         {false, unix.MS_BIND | unix.MS_REC},
+	"relatime":      {false, unix.MS_RELATIME},
+	"remount":       {false, unix.MS_REMOUNT},
+	"ro":            {false, unix.MS_RDONLY},
+	"rw":            {true, unix.MS_RDONLY},
+	"silent":        {false, unix.MS_SILENT},
+	"strictatime":   {false, unix.MS_STRICTATIME},
+	"suid":          {true, unix.MS_NOSUID},
+	"sync":          {false, unix.MS_SYNCHRONOUS},
+}
+
+var extensionFlags = map[string]struct {
+	clear bool
+	flag  int
+}{
+	"tmpcopyup": {false, configs.EXT_COPYUP},
+}
+
+// KnownNamespaces returns the list of the known namespaces.
+// Used by `runc features`.
+func KnownNamespaces() []string {
+	res := make([]string, 0, len(namespaceMapping))
+	for k := range namespaceMapping {
+		res = append(res, string(k))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// KnownMountOptions returns the list of the known mount options.
+// Used by `runc features`.
+func KnownMountOptions() []string {
+	var res []string
+	for k := range mountFlags {
+		res = append(res, k)
+	}
+	for k := range mountPropagationMapping {
+		if k != "" {
+			res = append(res, k)
+		}
+	}
+	for k := range extensionFlags {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // AllowedDevices is the set of devices which are automatically included for
 // all containers.
 //
@@ -190,6 +270,22 @@ var AllowedDevices = []*devices.Device{
 	},
 }
 
+// UsernsAnnotation is the annotation which, when set to "auto" (or
+// "auto:size=<n>"), makes the user namespace of the container be mapped to
+// free subordinate IDs of the user creating it (see subuid(5) and subgid(5)),
+// instead of the mappings of the spec.
+const UsernsAnnotation = "org.opencontainers.runc.userns"
+
+// defaultUsernsAutoSize is the number of IDs mapped in the "auto" mode, when
+// no size is requested.
+const defaultUsernsAutoSize = 65536
+
+// These are variables to be replaced in tests.
+var (
+	currentUserSubUIDs = user.CurrentUserSubUIDs
+	currentUserSubGIDs = user.CurrentUserSubGIDs
+)
+
 type CreateOpts struct {
 	CgroupName       string
 	UseSystemdCgroup bool
@@ -198,6 +294,10 @@ type CreateOpts struct {
 	Spec             *specs.Spec
 	RootlessEUID     bool
 	RootlessCgroups  bool
+	// UsedUidMappings and UsedGidMappings are the mappings of the other
+	// containers, whose host IDs are not used in the "auto" mode.
+	UsedUidMappings []configs.IDMap
+	UsedGidMappings []configs.IDMap
 }
 
 // CreateLibcontainerConfig creates a new libcontainer configuration from a
@@ -282,7 +382,7 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 			}
 		}
 		if config.Namespaces.Contains(configs.NEWUSER) {
-			if err := setupUserNamespace(spec, config); err != nil {
+			if err := setupUserNamespace(opts, config); err != nil {
 				return nil, err
 			}
 		}
@@ -290,6 +390,7 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 		config.ReadonlyPaths = spec.Linux.ReadonlyPaths
 		config.MountLabel = spec.Linux.MountLabel
 		config.Sysctl = spec.Linux.Sysctl
+		config.TimeOffsets = spec.Linux.TimeOffsets
 		if spec.Linux.Seccomp != nil {
 			seccomp, err := SetupSeccomp(spec.Linux.Seccomp)
 			if err != nil {
@@ -352,9 +453,23 @@ func createLibcontainerMount(cwd string, m specs.Mount) (*configs.Mount, error)
 		Flags:            flags,
 		PropagationFlags: pgflags,
 		Extensions:       ext,
+		UIDMappings:      toConfigIDMaps(m.UIDMappings),
+		GIDMappings:      toConfigIDMaps(m.GIDMappings),
 	}, nil
 }
 
+func toConfigIDMaps(idMaps []specs.LinuxIDMapping) []configs.IDMap {
+	var ret []configs.IDMap
+	for _, m := range idMaps {
+		ret = append(ret, configs.IDMap{
+			HostID:      int(m.HostID),
+			ContainerID: int(m.ContainerID),
+			Size:        int(m.Size),
+		})
+	}
+	return ret
+}
+
 // systemd property name check: latin letters only, at least 3 of them
 var isValidName = regexp.MustCompile(`^[a-zA-Z]{3,}$`).MatchString
 
@@ -726,21 +841,15 @@ next:
 	return dedupedAllowDevs, nil
 }
 
-func setupUserNamespace(spec *specs.Spec, config *configs.Config) error {
-	create := func(m specs.LinuxIDMapping) configs.IDMap {
-		return configs.IDMap{
-			HostID:      int(m.HostID),
-			ContainerID: int(m.ContainerID),
-			Size:        int(m.Size),
-		}
-	}
-	if spec.Linux != nil {
-		for _, m := range spec.Linux.UIDMappings {
-			config.UidMappings = append(config.UidMappings, create(m))
-		}
-		for _, m := range spec.Linux.GIDMappings {
-			config.GidMappings = append(config.GidMappings, create(m))
+func setupUserNamespace(opts *CreateOpts, config *configs.Config) error {
+	spec := opts.Spec
+	if mode, ok := spec.Annotations[UsernsAnnotation]; ok {
+		if err := setupUsernsAuto(opts, mode, config); err != nil {
+			return err
 		}
+	} else if spec.Linux != nil {
+		config.UidMappings = append(config.UidMappings, toConfigIDMaps(spec.Linux.UIDMappings)...)
+		config.GidMappings = append(config.GidMappings, toConfigIDMaps(spec.Linux.GIDMappings)...)
 	}
 	rootUID, err := config.HostRootUID()
 	if err != nil {
@@ -757,6 +866,111 @@ func setupUserNamespace(spec *specs.Spec, config *configs.Config) error {
 	return nil
 }
 
+// setupUsernsAuto sets the ID mappings of config for the "auto" mode, mode
+// being the value of the UsernsAnnotation annotation.
+func setupUsernsAuto(opts *CreateOpts, mode string, config *configs.Config) error {
+	size, err := parseUsernsAuto(mode)
+	if err != nil {
+		return err
+	}
+	if spec := opts.Spec; spec.Linux != nil && (len(spec.Linux.UIDMappings) > 0 || len(spec.Linux.GIDMappings) > 0) {
+		return fmt.Errorf("the %s annotation can't be used along with uidMappings or gidMappings", UsernsAnnotation)
+	}
+	subUIDs, err := currentUserSubUIDs()
+	if err != nil {
+		return fmt.Errorf("unable to get the subordinate UIDs: %w", err)
+	}
+	subGIDs, err := currentUserSubGIDs()
+	if err != nil {
+		return fmt.Errorf("unable to get the subordinate GIDs: %w", err)
+	}
+	// A rootless container has its root mapped to the user creating it,
+	// as for the mappings generated by runc spec --rootless.
+	ownUID, ownGID := -1, -1
+	if opts.RootlessEUID {
+		ownUID, ownGID = os.Geteuid(), os.Getegid()
+	}
+	config.UidMappings, err = usernsAutoMappings(subUIDs, opts.UsedUidMappings, size, ownUID)
+	if err != nil {
+		return fmt.Errorf("unable to map the UIDs of the container: %w", err)
+	}
+	config.GidMappings, err = usernsAutoMappings(subGIDs, opts.UsedGidMappings, size, ownGID)
+	if err != nil {
+		return fmt.Errorf("unable to map the GIDs of the container: %w", err)
+	}
+	return nil
+}
+
+// parseUsernsAuto parses the value of the UsernsAnnotation annotation, and
+// returns the number of IDs to map.
+func parseUsernsAuto(mode string) (int, error) {
+	if mode == "auto" {
+		return defaultUsernsAutoSize, nil
+	}
+	if opt := strings.TrimPrefix(mode, "auto:"); opt != mode && strings.HasPrefix(opt, "size=") {
+		size, err := strconv.ParseUint(strings.TrimPrefix(opt, "size="), 10, 32)
+		if err == nil && size > 0 {
+			return int(size), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid %s annotation %q: must be auto or auto:size=<n>", UsernsAnnotation, mode)
+}
+
+// usernsAutoMappings maps size IDs from 0 to host IDs of subIDs, skipping
+// those mapped by used, the mappings of other containers. If ownID is not -1,
+// ID 0 is mapped to it, and the others to subIDs.
+func usernsAutoMappings(subIDs []user.SubID, used []configs.IDMap, size, ownID int) ([]configs.IDMap, error) {
+	var mappings []configs.IDMap
+	// Do not modify the backing array of used.
+	used = append([]configs.IDMap(nil), used...)
+	next := 0
+	if ownID != -1 {
+		mappings = append(mappings, configs.IDMap{ContainerID: 0, HostID: ownID, Size: 1})
+		used = append(used, mappings[0])
+		next = 1
+	}
+	for _, sub := range subIDs {
+		id, end := int(sub.SubID), int(sub.SubID+sub.Count)
+		for id < end && next < size {
+			// Find where the next free range starts, and where it ends:
+			// either with the subordinate range, at the first used ID
+			// after it, or once size IDs are mapped.
+			free := true
+			last := end
+			if last-id > size-next {
+				last = id + size - next
+			}
+			for _, u := range used {
+				uEnd := u.HostID + u.Size
+				if id >= u.HostID && id < uEnd {
+					id = uEnd
+					free = false
+					break
+				}
+				if u.HostID > id && u.HostID < last {
+					last = u.HostID
+				}
+			}
+			if !free {
+				continue
+			}
+			m := configs.IDMap{ContainerID: next, HostID: id, Size: last - id}
+			mappings = append(mappings, m)
+			// Subordinate ranges can overlap.
+			used = append(used, m)
+			next += m.Size
+			id = last
+		}
+	}
+	if next < size {
+		if len(subIDs) == 0 {
+			return nil, errors.New("no subordinate IDs are allocated to the current user")
+		}
+		return nil, fmt.Errorf("only %d of the %d IDs requested are free", next, size)
+	}
+	return mappings, nil
+}
+
 // parseMountOptions parses the string and returns the flags, propagation
 // flags and any mount data that it contains.
 func parseMountOptions(options []string) (int, []int, string, int) {
@@ -766,71 +980,17 @@ func parseMountOptions(options []string) (int, []int, string, int) {
 		data     []string
 		extFlags int
 	)
-	flags := map[string]struct {
-		clear bool
-		flag  int
-	}{
-		"acl":           {false, unix.MS_POSIXACL},
-		"async":         {true, unix.MS_SYNCHRONOUS},
-		"atime":         {true, unix.MS_NOATIME},
-		"bind":          {false, unix.MS_BIND},
-		"defaults":      {false, 0},
-		"dev":           {true, unix.MS_NODEV},
-		"diratime":      {true, unix.MS_NODIRATIME},
-		"dirsync":       {false, unix.MS_DIRSYNC},
-		"exec":          {true, unix.MS_NOEXEC},
-		"iversion":      {false, unix.MS_I_VERSION},
-		"lazytime":      {false, unix.MS_LAZYTIME},
-		"loud":          {true, unix.MS_SILENT},
-		"mand":          {false, unix.MS_MANDLOCK},
-		"noacl":         {true, unix.MS_POSIXACL},
-		"noatime":       {false, unix.MS_NOATIME},
-		"nodev":         {false, unix.MS_NODEV},
-		"nodiratime":    {false, unix.MS_NODIRATIME},
-		"noexec":        {false, unix.MS_NOEXEC},
-		"noiversion":    {true, unix.MS_I_VERSION},
-		"nolazytime":    {true, unix.MS_LAZYTIME},
-		"nomand":        {true, unix.MS_MANDLOCK},
-		"norelatime":    {true, unix.MS_RELATIME},
-		"nostrictatime": {true, unix.MS_STRICTATIME},
-		"nosuid":        {false, unix.MS_NOSUID},
-		"rbind":         {false, unix.MS_BIND | unix.MS_REC},
-		"relatime":      {false, unix.MS_RELATIME},
-		"remount":       {false, unix.MS_REMOUNT},
-		"ro":            {false, unix.MS_RDONLY},
-		"rw":            {true, unix.MS_RDONLY},
-		"silent":        {false, unix.MS_SILENT},
-		"strictatime":   {false, unix.MS_STRICTATIME},
-		"suid":          {true, unix.MS_NOSUID},
-		"sync":          {false, unix.MS_SYNCHRONOUS},
-	}
-	propagationFlags := map[string]int{
-		"private":     unix.MS_PRIVATE,
-		"shared":      unix.MS_SHARED,
-		"slave":       unix.MS_SLAVE,
-		"unbindable":  unix.MS_UNBINDABLE,
-		"rprivate":    unix.MS_PRIVATE | unix.MS_REC,
-		"rshared":     unix.MS_SHARED | unix.MS_REC,
-		"rslave":      unix.MS_SLAVE | unix.MS_REC,
-		"runbindable": unix.MS_UNBINDABLE | unix.MS_REC,
-	}
-	extensionFlags := map[string]struct {
-		clear bool
-		flag  int
-	}{
-		"tmpcopyup": {false, configs.EXT_COPYUP},
-	}
 	for _, o := range options {
 		// If the option does not exist in the flags table or the flag
 		// is not supported on the platform,
 		// then it is a data value for a specific fs type
-		if f, exists := flags[o]; exists && f.flag != 0 {
+		if f, exists := mountFlags[o]; exists && f.flag != 0 {
 			if f.clear {
 				flag &= ^f.flag
 			} else {
 				flag |= f.flag
 			}
-		} else if f, exists := propagationFlags[o]; exists && f != 0 {
+		} else if f, exists := mountPropagationMapping[o]; exists && f != 0 {
 			pgflag = append(pgflag, f)
 		} else if f, exists := extensionFlags[o]; exists && f.flag != 0 {
 			if f.clear {
@@ -855,14 +1015,18 @@ func SetupSeccomp(config *specs.LinuxSeccomp) (*configs.Seccomp, error) {
 		return nil, nil
 	}
 
-	// We don't currently support seccomp flags.
-	if len(config.Flags) != 0 {
-		return nil, errors.New("seccomp flags are not yet supported by runc")
-	}
-
 	newConfig := new(configs.Seccomp)
 	newConfig.Syscalls = []*configs.Syscall{}
 
+	// The list of flags defined in runtime-spec is a subset of the flags
+	// in the seccomp() syscall.
+	for _, flag := range config.Flags {
+		if err := seccomp.FlagSupported(flag); err != nil {
+			return nil, err
+		}
+		newConfig.Flags = append(newConfig.Flags, flag)
+	}
+
 	if len(config.Architectures) > 0 {
 		newConfig.Architectures = []string{}
 		for _, arch := range config.Architectures {
@@ -882,6 +1046,9 @@ func SetupSeccomp(config *specs.LinuxSeccomp) (*configs.Seccomp, error) {
 	newConfig.DefaultAction = newDefaultAction
 	newConfig.DefaultErrnoRet = config.DefaultErrnoRet
 
+	newConfig.ListenerPath = config.ListenerPath
+	newConfig.ListenerMetadata = config.ListenerMetadata
+
 	// Loop through all syscall blocks and convert them to libcontainer format
 	for _, call := range config.Syscalls {
 		newAction, err := seccomp.ConvertStringToAction(string(call.Action))
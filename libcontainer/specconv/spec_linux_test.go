@@ -2,6 +2,7 @@ package specconv
 
 import (
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
 	"github.com/opencontainers/runc/libcontainer/devices"
+	"github.com/opencontainers/runc/libcontainer/seccomp"
+	"github.com/opencontainers/runc/libcontainer/user"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
 )
@@ -213,6 +216,87 @@ func TestSetupSeccomp(t *testing.T) {
 	}
 }
 
+func TestCreateLibcontainerMountIDMapped(t *testing.T) {
+	m := specs.Mount{
+		Destination: "/data",
+		Type:        "bind",
+		Source:      "/srv/data",
+		Options:     []string{"rbind", "ro"},
+		UIDMappings: []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}},
+		GIDMappings: []specs.LinuxIDMapping{{ContainerID: 0, HostID: 200000, Size: 65536}},
+	}
+	cm, err := createLibcontainerMount("/", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cm.IsBind() || !cm.IsIDMapped() {
+		t.Fatalf("expected an id-mapped bind mount, got %+v", cm)
+	}
+	expectedUID := []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	if !reflect.DeepEqual(cm.UIDMappings, expectedUID) {
+		t.Errorf("expected uid mappings %+v, got %+v", expectedUID, cm.UIDMappings)
+	}
+	expectedGID := []configs.IDMap{{ContainerID: 0, HostID: 200000, Size: 65536}}
+	if !reflect.DeepEqual(cm.GIDMappings, expectedGID) {
+		t.Errorf("expected gid mappings %+v, got %+v", expectedGID, cm.GIDMappings)
+	}
+}
+
+func TestSetupSeccompNotify(t *testing.T) {
+	conf := &specs.LinuxSeccomp{
+		DefaultAction:    "SCMP_ACT_ALLOW",
+		ListenerPath:     "/run/seccomp-agent.sock",
+		ListenerMetadata: "opaque",
+		Syscalls: []specs.LinuxSyscall{
+			{
+				Names:  []string{"mount", "mknod"},
+				Action: "SCMP_ACT_NOTIFY",
+			},
+		},
+	}
+	seccomp, err := SetupSeccomp(conf)
+	if err != nil {
+		t.Fatalf("Couldn't create Seccomp config: %v", err)
+	}
+
+	if seccomp.ListenerPath != conf.ListenerPath {
+		t.Errorf("Expected ListenerPath %q, got %q", conf.ListenerPath, seccomp.ListenerPath)
+	}
+	if seccomp.ListenerMetadata != conf.ListenerMetadata {
+		t.Errorf("Expected ListenerMetadata %q, got %q", conf.ListenerMetadata, seccomp.ListenerMetadata)
+	}
+	if len(seccomp.Syscalls) != 2 {
+		t.Fatalf("Expected 2 syscalls, got %d", len(seccomp.Syscalls))
+	}
+	for _, call := range seccomp.Syscalls {
+		if call.Action != configs.Notify {
+			t.Errorf("Wrong conversion for the %s syscall action", call.Name)
+		}
+	}
+}
+
+func TestSetupSeccompFlags(t *testing.T) {
+	conf := &specs.LinuxSeccomp{
+		DefaultAction: "SCMP_ACT_ALLOW",
+		Flags:         []specs.LinuxSeccompFlag{"SECCOMP_FILTER_FLAG_BOGUS"},
+	}
+	if _, err := SetupSeccomp(conf); err == nil {
+		t.Fatal("expected an error for an unknown seccomp flag")
+	}
+
+	if !seccomp.Enabled {
+		t.Skip("seccomp support is not compiled in")
+	}
+	conf.Flags = []specs.LinuxSeccompFlag{specs.LinuxSeccompFlagLog}
+	config, err := SetupSeccomp(conf)
+	if err != nil {
+		t.Skipf("SECCOMP_FILTER_FLAG_LOG is not supported: %v", err)
+	}
+	if !reflect.DeepEqual(config.Flags, conf.Flags) {
+		t.Errorf("expected flags %v, got %v", conf.Flags, config.Flags)
+	}
+}
+
 func TestLinuxCgroupWithMemoryResource(t *testing.T) {
 	cgroupsPath := "/user/cgroups/path/id"
 
@@ -740,3 +824,114 @@ func TestCreateDevices(t *testing.T) {
 		t.Errorf("device /dev/ram0 not found in config devices; got %v", conf.Devices)
 	}
 }
+
+func TestUsernsAutoMappings(t *testing.T) {
+	subIDs := []user.SubID{
+		{SubID: 100000, Count: 65536},
+		{SubID: 300000, Count: 65536},
+	}
+	for _, tc := range []struct {
+		name     string
+		used     []configs.IDMap
+		size     int
+		ownID    int
+		expected []configs.IDMap
+		err      string
+	}{
+		{
+			name:     "simple",
+			size:     1000,
+			ownID:    -1,
+			expected: []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 1000}},
+		},
+		{
+			name:  "rootless",
+			size:  1000,
+			ownID: 1000,
+			expected: []configs.IDMap{
+				{ContainerID: 0, HostID: 1000, Size: 1},
+				{ContainerID: 1, HostID: 100000, Size: 999},
+			},
+		},
+		{
+			name: "used",
+			used: []configs.IDMap{
+				{ContainerID: 0, HostID: 100000, Size: 1000},
+				{ContainerID: 0, HostID: 102000, Size: 65536},
+			},
+			size:  65536,
+			ownID: -1,
+			expected: []configs.IDMap{
+				{ContainerID: 0, HostID: 101000, Size: 1000},
+				{ContainerID: 1000, HostID: 300000, Size: 64536},
+			},
+		},
+		{
+			name:  "not enough",
+			used:  []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}},
+			size:  65537,
+			ownID: -1,
+			err:   "only 65536 of the 65537 IDs requested are free",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mappings, err := usernsAutoMappings(subIDs, tc.used, tc.size, tc.ownID)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(mappings, tc.expected) {
+				t.Errorf("expected mappings %+v, got %+v", tc.expected, mappings)
+			}
+		})
+	}
+}
+
+func TestUsernsAutoSpecconv(t *testing.T) {
+	defer func(uids, gids func() ([]user.SubID, error)) {
+		currentUserSubUIDs, currentUserSubGIDs = uids, gids
+	}(currentUserSubUIDs, currentUserSubGIDs)
+	currentUserSubUIDs = func() ([]user.SubID, error) {
+		return []user.SubID{{Name: "root", SubID: 100000, Count: 65536}}, nil
+	}
+	currentUserSubGIDs = func() ([]user.SubID, error) {
+		return []user.SubID{{Name: "root", SubID: 200000, Count: 65536}}, nil
+	}
+
+	spec := Example()
+	spec.Root.Path = "/"
+	ToUsernsAuto(spec)
+	spec.Annotations[UsernsAnnotation] = "auto:size=1024"
+
+	config, err := CreateLibcontainerConfig(&CreateOpts{
+		CgroupName:      "ContainerID",
+		Spec:            spec,
+		UsedUidMappings: []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 1024}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedUID := []configs.IDMap{{ContainerID: 0, HostID: 101024, Size: 1024}}
+	if !reflect.DeepEqual(config.UidMappings, expectedUID) {
+		t.Errorf("expected uid mappings %+v, got %+v", expectedUID, config.UidMappings)
+	}
+	expectedGID := []configs.IDMap{{ContainerID: 0, HostID: 200000, Size: 1024}}
+	if !reflect.DeepEqual(config.GidMappings, expectedGID) {
+		t.Errorf("expected gid mappings %+v, got %+v", expectedGID, config.GidMappings)
+	}
+
+	spec.Annotations[UsernsAnnotation] = "auto:size=0"
+	if _, err := CreateLibcontainerConfig(&CreateOpts{CgroupName: "ContainerID", Spec: spec}); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+	spec.Annotations[UsernsAnnotation] = "auto"
+	spec.Linux.UIDMappings = []specs.LinuxIDMapping{{ContainerID: 0, HostID: 1000, Size: 1}}
+	if _, err := CreateLibcontainerConfig(&CreateOpts{CgroupName: "ContainerID", Spec: spec}); err == nil {
+		t.Error("expected an error for auto mode along with uidMappings")
+	}
+}
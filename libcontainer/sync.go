@@ -22,12 +22,20 @@ type syncType string
 //
 // procReady   --> [final setup]
 //             <-- procRun
+//
+// procSeccomp --> [prepare to receive fd]
+//             <-- procSeccompFd
+// [notify fd] --> [send fd to seccomp agent]
+//             <-- procSeccompDone
 const (
-	procError  syncType = "procError"
-	procReady  syncType = "procReady"
-	procRun    syncType = "procRun"
-	procHooks  syncType = "procHooks"
-	procResume syncType = "procResume"
+	procError       syncType = "procError"
+	procReady       syncType = "procReady"
+	procRun         syncType = "procRun"
+	procHooks       syncType = "procHooks"
+	procResume      syncType = "procResume"
+	procSeccomp     syncType = "procSeccomp"
+	procSeccompFd   syncType = "procSeccompFd"
+	procSeccompDone syncType = "procSeccompDone"
 )
 
 type syncT struct {
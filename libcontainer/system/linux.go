@@ -56,6 +56,26 @@ func Prlimit(pid, resource int, limit unix.Rlimit) error {
 	return nil
 }
 
+// Scheduling policies, see sched(7).
+const (
+	SCHED_OTHER = 0
+	SCHED_FIFO  = 1
+	SCHED_RR    = 2
+	SCHED_BATCH = 3
+	SCHED_IDLE  = 5
+)
+
+// SchedSetscheduler sets the scheduling policy and static priority of the
+// thread tid (or of the calling thread, if tid is 0).
+func SchedSetscheduler(tid, policy, priority int) error {
+	param := struct{ priority int32 }{int32(priority)}
+	_, _, err := unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, uintptr(tid), uintptr(policy), uintptr(unsafe.Pointer(&param)))
+	if err != 0 {
+		return err
+	}
+	return nil
+}
+
 func SetParentDeathSignal(sig uintptr) error {
 	if err := unix.Prctl(unix.PR_SET_PDEATHSIG, sig, 0, 0, 0); err != nil {
 		return err
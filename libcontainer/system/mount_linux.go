@@ -0,0 +1,72 @@
+package system
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Constants for the new mount API (see open_tree(2), move_mount(2) and
+// mount_setattr(2)), which are not yet provided by golang.org/x/sys/unix.
+const (
+	OPEN_TREE_CLONE   = 0x1     //nolint:golint // ignore "don't use ALL_CAPS" warning
+	OPEN_TREE_CLOEXEC = 0x80000 //nolint:golint
+
+	MOVE_MOUNT_F_EMPTY_PATH = 0x4  //nolint:golint
+	MOVE_MOUNT_T_SYMLINKS   = 0x10 //nolint:golint
+
+	AT_RECURSIVE = 0x8000 //nolint:golint
+
+	MOUNT_ATTR_IDMAP = 0x100000 //nolint:golint
+)
+
+// MountAttr is the argument to mount_setattr(2) (struct mount_attr).
+type MountAttr struct {
+	AttrSet     uint64
+	AttrClr     uint64
+	Propagation uint64
+	UsernsFd    uint64
+}
+
+// OpenTree is a wrapper for the open_tree(2) system call.
+func OpenTree(dirfd int, path string, flags uint) (int, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_OPEN_TREE, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flags))
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// MoveMount is a wrapper for the move_mount(2) system call.
+func MoveMount(fromDirfd int, fromPath string, toDirfd int, toPath string, flags uint) error {
+	from, err := unix.BytePtrFromString(fromPath)
+	if err != nil {
+		return err
+	}
+	to, err := unix.BytePtrFromString(toPath)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_MOVE_MOUNT, uintptr(fromDirfd), uintptr(unsafe.Pointer(from)), uintptr(toDirfd), uintptr(unsafe.Pointer(to)), uintptr(flags), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// MountSetattr is a wrapper for the mount_setattr(2) system call.
+func MountSetattr(dirfd int, path string, flags uint, attr *MountAttr) error {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_MOUNT_SETATTR, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flags), uintptr(unsafe.Pointer(attr)), unsafe.Sizeof(*attr), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package system
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// PIDFS_MAGIC is the filesystem magic of pidfs, which backs pidfds since
+// Linux 6.9, and is not yet provided by golang.org/x/sys/unix.
+const PIDFS_MAGIC = 0x50494446 //nolint:golint // ignore "don't use ALL_CAPS" warning
+
+// PidfdOpen is a wrapper for the pidfd_open(2) system call.
+func PidfdOpen(pid int, flags uint) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(pid), uintptr(flags), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// PidfdSendSignal is a wrapper for the pidfd_send_signal(2) system call.
+func PidfdSendSignal(pidfd int, sig unix.Signal, flags uint) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PIDFD_SEND_SIGNAL, uintptr(pidfd), uintptr(sig), 0, uintptr(flags), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+const (
+	// PIDFD_GET_INFO is the pidfd ioctl (Linux >= 6.13) filling a PidfdInfo.
+	PIDFD_GET_INFO = 0xc040ff0b //nolint:golint // ignore "don't use ALL_CAPS" warning
+
+	// PIDFD_INFO_EXIT requests (and reports) the exit status of a process
+	// that has been reaped (Linux >= 6.15).
+	PIDFD_INFO_EXIT = 1 << 3 //nolint:golint // ignore "don't use ALL_CAPS" warning
+)
+
+// PidfdInfo is the first (and, up to Linux 6.15, only) version of struct
+// pidfd_info.
+type PidfdInfo struct {
+	Mask     uint64
+	CgroupID uint64
+	Pid      uint32
+	Tgid     uint32
+	Ppid     uint32
+	Ruid     uint32
+	Rgid     uint32
+	Euid     uint32
+	Egid     uint32
+	Suid     uint32
+	Sgid     uint32
+	Fsuid    uint32
+	Fsgid    uint32
+	ExitCode int32
+}
+
+// PidfdGetInfo is a wrapper for the PIDFD_GET_INFO ioctl, returning the
+// information requested by mask, which may be only part of it.
+func PidfdGetInfo(pidfd int, mask uint64) (*PidfdInfo, error) {
+	info := &PidfdInfo{Mask: mask}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(pidfd), PIDFD_GET_INFO, uintptr(unsafe.Pointer(info)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return info, nil
+}
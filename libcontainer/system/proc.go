@@ -57,9 +57,24 @@ type Stat_t struct {
 	// State is the state of the process.
 	State State
 
+	// PPID is the process ID of the parent of the process.
+	PPID uint
+
+	// UTime and STime are the number of clock ticks the process has been
+	// scheduled in user and kernel mode.
+	UTime uint64
+	STime uint64
+
+	// RSS is the resident set size of the process, in pages.
+	RSS uint64
+
 	// StartTime is the number of clock ticks after system boot (since
 	// Linux 2.6).
 	StartTime uint64
+
+	// ExitCode is the exit status of the process, in the form reported by
+	// waitpid(2), once it is a zombie (since Linux 3.5).
+	ExitCode int
 }
 
 // Stat returns a Stat_t instance for the specified process.
@@ -98,6 +113,13 @@ func parseStat(data string) (stat Stat_t, err error) {
 	var state int
 	fmt.Sscanf(parts[3-3], "%c", &state) //nolint:staticcheck // "3-3" is more readable in this context.
 	stat.State = State(state)
+	fmt.Sscanf(parts[4-3], "%d", &stat.PPID)
+	fmt.Sscanf(parts[14-3], "%d", &stat.UTime)
+	fmt.Sscanf(parts[15-3], "%d", &stat.STime)
 	fmt.Sscanf(parts[22-3], "%d", &stat.StartTime)
+	fmt.Sscanf(parts[24-3], "%d", &stat.RSS)
+	if len(parts) > 52-3 {
+		fmt.Sscanf(parts[52-3], "%d", &stat.ExitCode)
+	}
 	return stat, nil
 }
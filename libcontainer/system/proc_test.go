@@ -8,21 +8,37 @@ func TestParseStartTime(t *testing.T) {
 			PID:       4902,
 			Name:      "gunicorn: maste",
 			State:     'S',
+			PPID:      4885,
+			UTime:     78,
+			STime:     16,
 			StartTime: 9126532,
+			RSS:       1903,
 		},
 		"9534 (cat) R 9323 9534 9323 34828 9534 4194304 95 0 0 0 0 0 0 0 20 0 1 0 9214966 7626752 168 18446744073709551615 4194304 4240332 140732237651568 140732237650920 140570710391216 0 0 0 0 0 0 0 17 1 0 0 0 0 0 6340112 6341364 21553152 140732237653865 140732237653885 140732237653885 140732237656047 0": {
 			PID:       9534,
 			Name:      "cat",
 			State:     'R',
+			PPID:      9323,
 			StartTime: 9214966,
+			RSS:       168,
 		},
 
 		"24767 (irq/44-mei_me) S 2 0 0 0 -1 2129984 0 0 0 0 0 0 0 0 -51 0 1 0 8722075 0 0 18446744073709551615 0 0 0 0 0 0 0 2147483647 0 0 0 0 17 1 50 1 0 0 0 0 0 0 0 0 0 0 0": {
 			PID:       24767,
 			Name:      "irq/44-mei_me",
 			State:     'S',
+			PPID:      2,
 			StartTime: 8722075,
 		},
+
+		"31082 (sleep) Z 31074 31082 31074 0 -1 4227084 92 0 0 0 0 0 0 0 20 0 1 0 12087531 0 0 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 5 0 0 0 0 0 0 0 0 0 0 0 0 9": {
+			PID:       31082,
+			Name:      "sleep",
+			State:     'Z',
+			PPID:      31074,
+			StartTime: 12087531,
+			ExitCode:  9,
+		},
 	}
 	for line, expected := range data {
 		st, err := parseStat(line)
@@ -38,8 +54,20 @@ func TestParseStartTime(t *testing.T) {
 		if st.Name != expected.Name {
 			t.Fatalf("expected name %q but received %q", expected.Name, st.Name)
 		}
+		if st.PPID != expected.PPID {
+			t.Fatalf("expected PPID %d but received %d", expected.PPID, st.PPID)
+		}
+		if st.UTime != expected.UTime || st.STime != expected.STime {
+			t.Fatalf("expected utime/stime %d/%d but received %d/%d", expected.UTime, expected.STime, st.UTime, st.STime)
+		}
+		if st.RSS != expected.RSS {
+			t.Fatalf("expected RSS %d but received %d", expected.RSS, st.RSS)
+		}
 		if st.StartTime != expected.StartTime {
 			t.Fatalf("expected start time %q but received %q", expected.StartTime, st.StartTime)
 		}
+		if st.ExitCode != expected.ExitCode {
+			t.Fatalf("expected exit code %d but received %d", expected.ExitCode, st.ExitCode)
+		}
 	}
 }
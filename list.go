@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/user"
 	"github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 const formatOptions = `table or json`
@@ -40,6 +42,38 @@ type containerState struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 	// The owner of the state directory (the owner of the container).
 	Owner string `json:"owner"`
+	// ExitStatus is the exit status of the container's init process, if
+	// the container has stopped and it is known.
+	ExitStatus *exitStatus `json:"exitStatus,omitempty"`
+}
+
+// exitStatus represents the exit status of a container's init process
+type exitStatus struct {
+	// ExitCode is the exit code, or 128 plus the signal number if the
+	// process was killed by a signal
+	ExitCode int `json:"exitCode"`
+	// Signal is the name of the signal the process was killed by, if any
+	Signal string `json:"signal,omitempty"`
+	// OOMKilled is set if a process of the container was killed by the OOM
+	// killer
+	OOMKilled bool `json:"oomKilled,omitempty"`
+	// Exited is the time the process exited at
+	Exited time.Time `json:"exited"`
+}
+
+func newExitStatus(s *libcontainer.ExitStatus) *exitStatus {
+	if s == nil {
+		return nil
+	}
+	e := &exitStatus{
+		ExitCode:  s.ExitCode(),
+		OOMKilled: s.OOMKilled,
+		Exited:    s.Time,
+	}
+	if s.Signal != 0 {
+		e.Signal = unix.SignalName(s.Signal)
+	}
+	return e
 }
 
 var listCommand = cli.Command{
@@ -128,7 +162,8 @@ func getContainers(context *cli.Context) ([]containerState, error) {
 
 	var s []containerState
 	for _, item := range list {
-		if item.IsDir() {
+		// Skip the checkpoint archives being imported by runc restore.
+		if item.IsDir() && !strings.HasPrefix(item.Name(), checkpointImportPrefix) {
 			// This cast is safe on Linux.
 			stat := item.Sys().(*syscall.Stat_t)
 			owner, err := user.LookupUid(int(stat.Uid))
@@ -166,6 +201,7 @@ func getContainers(context *cli.Context) ([]containerState, error) {
 				Created:        state.BaseState.Created,
 				Annotations:    annotations,
 				Owner:          owner.Name,
+				ExitStatus:     newExitStatus(state.ExitStatus),
 			})
 		}
 	}
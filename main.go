@@ -114,11 +114,13 @@ func main() {
 		},
 	}
 	app.Commands = []cli.Command{
+		attachCommand,
 		checkpointCommand,
 		createCommand,
 		deleteCommand,
 		eventsCommand,
 		execCommand,
+		featuresCommand,
 		killCommand,
 		listCommand,
 		pauseCommand,
@@ -129,7 +131,12 @@ func main() {
 		specCommand,
 		startCommand,
 		stateCommand,
+		stopCommand,
 		updateCommand,
+		waitCommand,
+		notifyRelayCommand,
+		logCopierCommand,
+		ioBrokerCommand,
 	}
 	app.Before = func(context *cli.Context) error {
 		if !context.IsSet("root") && xdgRuntimeDir != "" {
@@ -1,23 +1,40 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// notifyBufferSize is the maximum size of a notification, as accepted
+	// by systemd.
+	notifyBufferSize = 4096
+	// notifyMaxFds is the maximum number of file descriptors sent along
+	// with a notification (SCM_MAX_FD).
+	notifyMaxFds = 253
 )
 
 type notifySocket struct {
 	socket     *net.UnixConn
 	host       string
 	socketPath string
+	// globalArgs are the global options to run the background relay with.
+	globalArgs []string
 }
 
 func newNotifySocket(context *cli.Context, notifySocketHost string, id string) *notifySocket {
@@ -32,6 +49,7 @@ func newNotifySocket(context *cli.Context, notifySocketHost string, id string) *
 		socket:     nil,
 		host:       notifySocketHost,
 		socketPath: socketPath,
+		globalArgs: globalArgs(context),
 	}
 
 	return notifySocket
@@ -72,10 +90,31 @@ func (s *notifySocket) bindSocket() error {
 		return err
 	}
 
+	// Get the credentials of the senders, to relay the notifications on
+	// their behalf.
+	if err := setPassCred(socket); err != nil {
+		socket.Close()
+		return err
+	}
+
 	s.socket = socket
 	return nil
 }
 
+func setPassCred(socket *net.UnixConn) error {
+	conn, err := socket.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := conn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PASSCRED, 1)
+	}); err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", sockErr)
+}
+
 func (s *notifySocket) setupSocketDirectory() error {
 	return os.Mkdir(path.Dir(s.socketPath), 0o755)
 }
@@ -92,79 +131,270 @@ func notifySocketStart(context *cli.Context, notifySocketHost, id string) (*noti
 	return notifySocket, nil
 }
 
+// waitForContainer relays the notifications of a container runc is about to
+// detach from until it is ready, reporting its init process as the main
+// process, then hands the notify socket over to a background runc process
+// relaying them for the rest of the container's lifetime.
 func (n *notifySocket) waitForContainer(container libcontainer.Container) error {
 	s, err := container.State()
 	if err != nil {
 		return err
 	}
-	return n.run(s.InitProcessPid)
+	ready, err := n.relay(container, s.InitProcessPid, true, false)
+	if err != nil || !ready {
+		return err
+	}
+	return n.startBackgroundRelay(container.ID())
 }
 
-func (n *notifySocket) run(pid1 int) error {
+// relay relays the notifications sent from the container to the host's
+// notify socket, until the container is stopped, or, if untilReady is set, the
+// container is ready. mainPid, if not 0, is reported as MAINPID along with
+// READY=1, while the MAINPID sent by the container is translated to the host
+// pid namespace. If asSender is set, the notifications are sent with the
+// credentials of their sender, when we have the privileges to do so, rather
+// than ours. It returns whether READY=1 was relayed.
+func (n *notifySocket) relay(container libcontainer.Container, mainPid int, untilReady, asSender bool) (bool, error) {
 	if n.socket == nil {
-		return nil
+		return false, nil
 	}
 	notifySocketHostAddr := net.UnixAddr{Name: n.host, Net: "unixgram"}
 	client, err := net.DialUnix("unixgram", nil, &notifySocketHostAddr)
 	if err != nil {
-		return err
+		return false, err
 	}
+	defer client.Close()
 
-	ticker := time.NewTicker(time.Millisecond * 100)
-	defer ticker.Stop()
-
-	fileChan := make(chan []byte)
-	go func() {
-		for {
-			buf := make([]byte, 4096)
-			r, err := n.socket.Read(buf)
-			if err != nil {
-				return
-			}
-			got := buf[0:r]
-			// systemd-ready sends a single datagram with the state string as payload,
-			// so we don't need to worry about partial messages.
-			for _, line := range bytes.Split(got, []byte{'\n'}) {
-				if bytes.HasPrefix(got, []byte("READY=")) {
-					fileChan <- line
-					return
+	// Check that the container is still there every interval without
+	// notifications. When waiting for it to get ready, this has to be
+	// often, as the caller is blocked.
+	interval := time.Second
+	if untilReady {
+		interval = 100 * time.Millisecond
+	}
+	buf := make([]byte, notifyBufferSize)
+	oob := make([]byte, unix.CmsgSpace(unix.SizeofUcred)+unix.CmsgSpace(notifyMaxFds*4))
+	for {
+		if err := n.socket.SetReadDeadline(time.Now().Add(interval)); err != nil {
+			return false, err
+		}
+		r, oobn, _, _, err := n.socket.ReadMsgUnix(buf, oob)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				if status, err := container.Status(); err != nil || status == libcontainer.Stopped {
+					return false, nil
 				}
+				continue
 			}
-
+			return false, err
+		}
+		fds, cred, err := parseNotifyControl(oob[:oobn])
+		if err != nil {
+			logrus.Warnf("notify socket: %v", err)
+			for _, fd := range fds {
+				_ = unix.Close(fd)
+			}
+			continue
+		}
+		msg, ready := translateNotification(container, buf[:r], mainPid)
+		if len(msg) == 0 && len(fds) == 0 {
+			continue
+		}
+		if !asSender {
+			cred = nil
+		}
+		err = sendNotification(client, msg, fds, cred)
+		for _, fd := range fds {
+			_ = unix.Close(fd)
+		}
+		if err != nil {
+			return false, err
+		}
+		if ready && untilReady {
+			return true, nil
 		}
-	}()
+	}
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			_, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid1)))
+// parseNotifyControl returns the file descriptors (for FDSTORE=1) and the
+// credentials of the sender of a notification, from its control messages.
+// The file descriptors received are returned even in case of an error, for
+// the caller to close them.
+func parseNotifyControl(oob []byte) (fds []int, cred *unix.Ucred, _ error) {
+	scms, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range scms {
+		scm := &scms[i]
+		if scm.Header.Level != unix.SOL_SOCKET {
+			continue
+		}
+		switch scm.Header.Type {
+		case unix.SCM_RIGHTS:
+			rights, err := unix.ParseUnixRights(scm)
 			if err != nil {
-				return nil
+				return fds, nil, err
 			}
-		case b := <-fileChan:
-			var out bytes.Buffer
-			_, err = out.Write(b)
-			if err != nil {
-				return err
+			fds = append(fds, rights...)
+		case unix.SCM_CREDENTIALS:
+			if cred, err = unix.ParseUnixCredentials(scm); err != nil {
+				return fds, nil, err
 			}
+		}
+	}
+	return fds, cred, nil
+}
 
-			_, err = out.Write([]byte{'\n'})
-			if err != nil {
-				return err
+// translateNotification translates the MAINPID of a notification to the
+// host pid namespace (dropping it if it is not a process of the container),
+// or adds mainPid, if not 0, when the container gets ready. It also returns
+// whether the notification contains READY=1.
+func translateNotification(container libcontainer.Container, msg []byte, mainPid int) ([]byte, bool) {
+	var (
+		out        bytes.Buffer
+		ready      bool
+		hasMainPid bool
+	)
+	for _, line := range bytes.Split(msg, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("MAINPID=")):
+			pid, err := strconv.Atoi(string(line[len("MAINPID="):]))
+			if err == nil {
+				pid, err = hostPid(container, pid)
 			}
-
-			_, err = client.Write(out.Bytes())
 			if err != nil {
-				return err
+				logrus.Warnf("notify socket: ignoring %s: %v", line, err)
+				continue
 			}
+			line = []byte("MAINPID=" + strconv.Itoa(pid))
+			hasMainPid = true
+		case bytes.Equal(line, []byte("READY=1")):
+			ready = true
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if ready && !hasMainPid && mainPid != 0 {
+		// Now we can inform systemd to use mainPid as the pid to monitor.
+		out.WriteString("MAINPID=" + strconv.Itoa(mainPid) + "\n")
+	}
+	return out.Bytes(), ready
+}
 
-			// now we can inform systemd to use pid1 as the pid to monitor
-			newPid := "MAINPID=" + strconv.Itoa(pid1)
-			_, err := client.Write([]byte(newPid + "\n"))
-			if err != nil {
-				return err
-			}
-			return nil
+// hostPid translates pid, in the pid namespace of the container, to the pid
+// of the same process in ours.
+func hostPid(container libcontainer.Container, pid int) (int, error) {
+	pids, err := container.Processes()
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range pids {
+		if nsPid, err := innermostPid(p); err == nil && nsPid == pid {
+			return p, nil
 		}
 	}
+	return 0, fmt.Errorf("no process with pid %d in the container", pid)
+}
+
+// innermostPid returns the pid of the process pid in its own pid namespace.
+func innermostPid(pid int) (int, error) {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) > 1 && fields[0] == "NSpid:" {
+			return strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return pid, nil
+}
+
+// sendNotification sends a notification to the host's notify socket, along
+// with fds. If cred is set, it is sent on behalf of the process it refers
+// to, unless we lack the privileges to do so.
+func sendNotification(client *net.UnixConn, msg []byte, fds []int, cred *unix.Ucred) error {
+	var rights []byte
+	if len(fds) > 0 {
+		rights = unix.UnixRights(fds...)
+	}
+	conn, err := client.SyscallConn()
+	if err != nil {
+		return err
+	}
+	// WriteMsgUnix can't be used with connected datagram sockets.
+	send := func(oob []byte) error {
+		var sendErr error
+		if err := conn.Write(func(fd uintptr) bool {
+			sendErr = unix.Sendmsg(int(fd), msg, oob, nil, 0)
+			return !errors.Is(sendErr, unix.EAGAIN)
+		}); err != nil {
+			return err
+		}
+		return os.NewSyscallError("sendmsg", sendErr)
+	}
+	if cred != nil {
+		err := send(append(unix.UnixCredentials(cred), rights...))
+		if !errors.Is(err, unix.EPERM) {
+			return err
+		}
+		logrus.Debugf("notify socket: unable to relay the notification on behalf of pid %d: %v", cred.Pid, err)
+	}
+	return send(rights)
+}
+
+// startBackgroundRelay starts a runc process relaying the notifications of
+// the container for the rest of its lifetime, once we are done with it.
+func (n *notifySocket) startBackgroundRelay(id string) error {
+	socket, err := n.socket.File()
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	cmd := backgroundCommand(n.globalArgs, "notify-relay", id)
+	cmd.Env = append(os.Environ(), "NOTIFY_SOCKET="+n.host)
+	cmd.ExtraFiles = []*os.File{socket}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to relay the notifications in the background: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// notifyRelayCommand is the background process started by
+// startBackgroundRelay, getting the notify socket as fd 3.
+var notifyRelayCommand = cli.Command{
+	Name:   "notify-relay",
+	Hidden: true,
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 1, exactArgs); err != nil {
+			return err
+		}
+		container, err := getContainer(context)
+		if err != nil {
+			return err
+		}
+		conn, err := net.FileConn(os.NewFile(3, "notify-socket"))
+		if err != nil {
+			return err
+		}
+		socket, ok := conn.(*net.UnixConn)
+		if !ok {
+			return errors.New("casting to UnixConn failed")
+		}
+		n := &notifySocket{socket: socket, host: os.Getenv("NOTIFY_SOCKET")}
+		defer n.Close()
+		// Once the container is ready, its init process is the main process
+		// of the service, rather than us.
+		_, err = n.relay(container, 0, false, true)
+		return err
+	},
 }
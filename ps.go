@@ -17,12 +17,23 @@ var psCommand = cli.Command{
 	Name:      "ps",
 	Usage:     "ps displays the processes running inside a container",
 	ArgsUsage: `<container-id> [ps options]`,
+	Description: `Unless --json or --tree is used, the output of ps(1), run with the given
+ps options (or -ef), is shown for the processes of the container. If ps(1) is
+not available and no ps options are given, a similar table is shown.`,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "format, f",
 			Value: "table",
 			Usage: `select one of: ` + formatOptions,
 		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "show detailed information about the processes as JSON, without using ps(1)",
+		},
+		cli.BoolFlag{
+			Name:  "tree",
+			Usage: "show the processes as a tree, without using ps(1)",
+		},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, minArgs); err != nil {
@@ -46,6 +57,44 @@ var psCommand = cli.Command{
 			return err
 		}
 
+		// [1:] is to remove command name, ex:
+		// context.Args(): [container_id ps_arg1 ps_arg2 ...]
+		// psArgs:         [ps_arg1 ps_arg2 ...]
+		//
+		psArgs := context.Args()[1:]
+
+		native := context.Bool("json") || context.Bool("tree")
+		if native && len(psArgs) > 0 {
+			return errors.New("ps options can't be used with --json or --tree")
+		}
+		if context.Bool("json") && context.Bool("tree") {
+			return errors.New("--json and --tree are mutually exclusive")
+		}
+		if native && context.String("format") != "table" {
+			return errors.New("--format can't be used with --json or --tree")
+		}
+		if !native && len(psArgs) == 0 && context.String("format") == "table" {
+			if _, err := exec.LookPath("ps"); err != nil {
+				logrus.Debugf("ps(1) not found (%v), showing the processes natively", err)
+				native = true
+			}
+		}
+		if native {
+			procs, err := containerProcesses(container, pids)
+			if err != nil {
+				return err
+			}
+			switch {
+			case context.Bool("json"):
+				return json.NewEncoder(os.Stdout).Encode(procs)
+			case context.Bool("tree"):
+				printProcessTree(os.Stdout, procs)
+			default:
+				printProcessTable(os.Stdout, procs)
+			}
+			return nil
+		}
+
 		switch context.String("format") {
 		case "table":
 		case "json":
@@ -54,11 +103,6 @@ var psCommand = cli.Command{
 			return errors.New("invalid format option")
 		}
 
-		// [1:] is to remove command name, ex:
-		// context.Args(): [container_id ps_arg1 ps_arg2 ...]
-		// psArgs:         [ps_arg1 ps_arg2 ...]
-		//
-		psArgs := context.Args()[1:]
 		if len(psArgs) == 0 {
 			psArgs = []string{"-ef"}
 		}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/user"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// clockTicks is sysconf(_SC_CLK_TCK), the unit of the times in
+	// /proc/<pid>/stat. On Linux, it is USER_HZ, which is a constant (100,
+	// whatever the kernel's HZ) on all the architectures runc supports, so
+	// it is hard coded rather than using cgo, like in cgroups/fs/cpuacct.go.
+	clockTicks = 100
+
+	// overflowID is the ID shown for users and groups which are not mapped
+	// into a user namespace (see /proc/sys/kernel/overflowuid).
+	overflowID = 65534
+)
+
+// psNamespaces are the namespace types shown by runc ps --json.
+var psNamespaces = []string{"cgroup", "ipc", "mnt", "net", "pid", "time", "user", "uts"}
+
+// psProcess is a process of a container, as shown by runc ps --json.
+type psProcess struct {
+	PID   int    `json:"pid"`
+	PPID  int    `json:"ppid"`
+	State string `json:"state"`
+	// UID and GID are the effective user and group IDs of the process, and
+	// ContainerUID and ContainerGID the same as seen in its user namespace.
+	UID          int64     `json:"uid"`
+	GID          int64     `json:"gid"`
+	ContainerUID int64     `json:"containerUid"`
+	ContainerGID int64     `json:"containerGid"`
+	Name         string    `json:"name"`
+	Cmdline      []string  `json:"cmdline"`
+	Started      time.Time `json:"started"`
+	// CPUTime is the time spent running, in seconds, and CPUPercent its
+	// share of the lifetime of the process, like ps(1)'s %CPU.
+	CPUTime    float64 `json:"cpuTime"`
+	CPUPercent float64 `json:"cpuPercent"`
+	// RSS is the resident set size of the process, in bytes.
+	RSS uint64 `json:"rss"`
+	// Cgroup is the cgroup of the process, relative to the container's.
+	Cgroup string `json:"cgroup,omitempty"`
+	// Namespaces are the inode numbers of the namespaces of the process.
+	Namespaces map[string]uint64 `json:"namespaces,omitempty"`
+
+	state system.State
+}
+
+// containerProcesses reads the information about the processes pids of
+// container from /proc, skipping those which are gone meanwhile.
+func containerProcesses(container libcontainer.Container, pids []int) ([]psProcess, error) {
+	state, err := container.State()
+	if err != nil {
+		return nil, err
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	procs := make([]psProcess, 0, len(pids))
+	for _, pid := range pids {
+		p, err := readProcess(pid, boot, now, state.CgroupPaths)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.ESRCH) {
+				continue
+			}
+			return nil, fmt.Errorf("pid %d: %w", pid, err)
+		}
+		procs = append(procs, *p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+	return procs, nil
+}
+
+func readProcess(pid int, boot, now time.Time, cgroupPaths map[string]string) (*psProcess, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := system.Stat(pid)
+	if err != nil {
+		return nil, err
+	}
+	p := &psProcess{
+		PID:        pid,
+		PPID:       int(stat.PPID),
+		State:      stat.State.String(),
+		state:      stat.State,
+		Name:       stat.Name,
+		Started:    boot.Add(time.Duration(stat.StartTime) * (time.Second / clockTicks)),
+		CPUTime:    float64(stat.UTime+stat.STime) / clockTicks,
+		RSS:        stat.RSS * uint64(os.Getpagesize()),
+		Namespaces: make(map[string]uint64),
+	}
+	if elapsed := now.Sub(p.Started).Seconds(); elapsed > 0 {
+		p.CPUPercent = float64(int(1000*p.CPUTime/elapsed)) / 10
+	}
+
+	cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	if cmdline = bytes.TrimSuffix(cmdline, []byte{0}); len(cmdline) > 0 {
+		p.Cmdline = strings.Split(string(cmdline), "\x00")
+	}
+
+	if p.UID, p.GID, err = procIDs(filepath.Join(dir, "status")); err != nil {
+		return nil, err
+	}
+	// Zombies have no user namespace to map the IDs to anymore.
+	p.ContainerUID, p.ContainerGID = p.UID, p.GID
+	if uidMap, err := user.ParseIDMapFile(filepath.Join(dir, "uid_map")); err == nil {
+		p.ContainerUID = mapID(p.UID, uidMap)
+	}
+	if gidMap, err := user.ParseIDMapFile(filepath.Join(dir, "gid_map")); err == nil {
+		p.ContainerGID = mapID(p.GID, gidMap)
+	}
+
+	if procCgroups, err := cgroups.ParseCgroupFile(filepath.Join(dir, "cgroup")); err == nil {
+		p.Cgroup = subCgroup(cgroupPaths, procCgroups)
+	}
+
+	for _, ns := range psNamespaces {
+		link, err := os.Readlink(filepath.Join(dir, "ns", ns))
+		if err != nil {
+			// Either the namespace type is not supported, or the
+			// process is gone (or a zombie).
+			continue
+		}
+		// The link is of the form "net:[4026531840]".
+		var ino uint64
+		if _, err := fmt.Sscanf(strings.TrimPrefix(link, ns+":"), "[%d]", &ino); err == nil {
+			p.Namespaces[ns] = ino
+		}
+	}
+	return p, nil
+}
+
+// procIDs returns the effective user and group IDs of a process from its
+// /proc/[pid]/status file.
+func procIDs(path string) (uid, gid int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	uid, gid = -1, -1
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// The lines are of the form "Uid:\treal\teffective\tsaved\tfs".
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			uid, err = strconv.ParseInt(fields[2], 10, 64)
+		case "Gid:":
+			gid, err = strconv.ParseInt(fields[2], 10, 64)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, 0, err
+	}
+	if uid == -1 || gid == -1 {
+		return 0, 0, fmt.Errorf("no Uid or Gid in %s", path)
+	}
+	return uid, gid, nil
+}
+
+// mapID maps id to the user namespace of idMap, the ID mapping of a process
+// as read from /proc/[pid]/uid_map or gid_map.
+func mapID(id int64, idMap []user.IDMap) int64 {
+	for _, m := range idMap {
+		if id >= m.ParentID && id < m.ParentID+m.Count {
+			return m.ID + id - m.ParentID
+		}
+	}
+	return overflowID
+}
+
+// subCgroup returns the cgroup of a process, as read from /proc/[pid]/cgroup
+// (procCgroups), relative to the container's cgroup (cgroupPaths, from its
+// state), or "/" if it is in the container's cgroup.
+func subCgroup(cgroupPaths, procCgroups map[string]string) string {
+	// For cgroup v1, any controller does, if the container has one.
+	for _, subsystem := range []string{"", "pids", "memory", "cpu", "devices"} {
+		path, ok := cgroupPaths[subsystem]
+		if !ok {
+			continue
+		}
+		cgroup, ok := procCgroups[subsystem]
+		if !ok {
+			return ""
+		}
+		// path is the container's cgroup directory, which ends with the
+		// container's cgroup as seen in /proc/[pid]/cgroup.
+		for prefix := cgroup; prefix != "/" && prefix != "."; prefix = filepath.Dir(prefix) {
+			if strings.HasSuffix(path, prefix) {
+				return "/" + strings.TrimPrefix(cgroup[len(prefix):], "/")
+			}
+		}
+		return ""
+	}
+	return ""
+}
+
+// bootTime returns the time the system booted at, from /proc/stat.
+func bootTime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if strings.HasPrefix(line, "btime ") {
+			sec, err := strconv.ParseInt(strings.TrimSpace(line[len("btime "):]), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime in /proc/stat: %w", err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return time.Time{}, errors.New("no btime in /proc/stat")
+			}
+			return time.Time{}, err
+		}
+	}
+}
+
+// printProcessTree prints procs as a tree, following their parents.
+func printProcessTree(w io.Writer, procs []psProcess) {
+	children := make(map[int][]psProcess)
+	byPID := make(map[int]bool, len(procs))
+	for _, p := range procs {
+		byPID[p.PID] = true
+	}
+	var roots []psProcess
+	for _, p := range procs {
+		if byPID[p.PPID] && p.PPID != p.PID {
+			children[p.PPID] = append(children[p.PPID], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+	fmt.Fprintf(w, "%-8s %-8s %-6s %s\n", "PID", "PPID", "STAT", "CMD")
+	var print func(p psProcess, prefix, branch string)
+	print = func(p psProcess, prefix, branch string) {
+		cmd := strings.Join(p.Cmdline, " ")
+		if cmd == "" {
+			cmd = "[" + p.Name + "]"
+		}
+		fmt.Fprintf(w, "%-8d %-8d %-6s %s%s%s\n", p.PID, p.PPID, string(p.state), prefix, branch, cmd)
+		switch branch {
+		case "├─ ":
+			prefix += "│  "
+		case "└─ ":
+			prefix += "   "
+		}
+		kids := children[p.PID]
+		for i, c := range kids {
+			b := "├─ "
+			if i == len(kids)-1 {
+				b = "└─ "
+			}
+			print(c, prefix, b)
+		}
+	}
+	for _, p := range roots {
+		print(p, "", "")
+	}
+}
+
+// printProcessTable prints procs in a table similar to the one of ps -ef.
+func printProcessTable(w io.Writer, procs []psProcess) {
+	fmt.Fprintf(w, "%-8s %-8s %-8s %-6s %-20s %-10s %s\n", "UID", "PID", "PPID", "STAT", "STARTED", "TIME", "CMD")
+	for _, p := range procs {
+		cmd := strings.Join(p.Cmdline, " ")
+		if cmd == "" {
+			cmd = "[" + p.Name + "]"
+		}
+		cpu := time.Duration(p.CPUTime * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(w, "%-8d %-8d %-8d %-6s %-20s %-10s %s\n", p.UID, p.PID, p.PPID, string(p.state),
+			p.Started.Local().Format("2006-01-02T15:04:05"), cpu, cmd)
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/userns"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 var restoreCommand = cli.Command{
@@ -24,6 +33,11 @@ using the runc checkpoint command.`,
 			Value: "",
 			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal",
 		},
+		cli.StringFlag{
+			Name:  "exec-console-socket",
+			Value: "",
+			Usage: "path to an AF_UNIX socket which will receive, over a connection each, file descriptors referencing the master ends of the pseudoterminals of the exec'd processes",
+		},
 		cli.StringFlag{
 			Name:  "image-path",
 			Value: "",
@@ -94,28 +108,115 @@ using the runc checkpoint command.`,
 			Value: "",
 			Usage: "Specify an LSM profile to be used during restore in the form of TYPE:NAME.",
 		},
+		cli.StringFlag{
+			Name:  "import",
+			Value: "",
+			Usage: "restore from a checkpoint archive written by runc checkpoint --export",
+		},
+		cli.StringSliceFlag{
+			Name:  "mount-source",
+			Usage: "restore the bind mounts from <old-path> (or under it) from <new-path> instead, as <old-path>=<new-path>",
+		},
+		cli.StringSliceFlag{
+			Name:  "ns-path",
+			Usage: "restore into the external namespace at <path> instead of the configured one, as <type>=<path>",
+		},
+		cli.StringFlag{
+			Name:  "rootfs",
+			Value: "",
+			Usage: "restore into the given rootfs instead of the one of the bundle",
+		},
+		cli.StringSliceFlag{
+			Name:  "exec-stdio",
+			Usage: "reconnect the stdio pipes of the exec'd process <pid> to the given fds of runc, as <pid>=<stdin>,<stdout>,<stderr> (- leaves one as it is)",
+		},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
-		// XXX: Currently this is untested with rootless containers.
+		if context.Bool("detach") {
+			if err := runDetached(); err != nil {
+				return err
+			}
+		}
+		// This needs CRIU 3.17+ with CAP_CHECKPOINT_RESTORE, see runc-restore(8).
 		if os.Geteuid() != 0 || userns.RunningInUserNS() {
-			logrus.Warn("runc checkpoint is untested with rootless containers")
+			logrus.Debug("running CRIU in its unprivileged mode")
 		}
 
-		spec, err := setupSpec(context)
+		// The rootfs is relative to the current directory, not the bundle.
+		if rootfs := context.String("rootfs"); rootfs != "" {
+			abs, err := filepath.Abs(rootfs)
+			if err != nil {
+				return err
+			}
+			if err := context.Set("rootfs", abs); err != nil {
+				return err
+			}
+		}
+
+		var (
+			spec      *specs.Spec
+			importDir string
+			err       error
+		)
+		if archive := context.String("import"); archive != "" {
+			if context.String("image-path") != "" || context.Bool("lazy-pages") {
+				return errors.New("--import can't be used with --image-path or --lazy-pages")
+			}
+			root, err := filepath.Abs(context.GlobalString("root"))
+			if err != nil {
+				return err
+			}
+			imp, err := importCheckpoint(archive, context.GlobalString("criu"), root)
+			if err != nil {
+				return err
+			}
+			importDir = imp.dir
+			defer os.RemoveAll(importDir)
+			if err := context.Set("image-path", filepath.Join(imp.dir, archiveImagesDir)); err != nil {
+				return err
+			}
+			if spec, err = imp.setupSpec(context); err != nil {
+				return err
+			}
+		} else {
+			if spec, err = setupSpec(context); err != nil {
+				return err
+			}
+			setRestoreRootfs(context, spec)
+		}
+		options := criuOptions(context)
+		// Restoring with a different ID moves the container to a
+		// different cgroup.
+		oldID, err := libcontainer.CheckpointedID(options.ImagesDirectory)
 		if err != nil {
 			return err
 		}
-		options := criuOptions(context)
+		if id := context.Args().First(); oldID != "" && id != oldID && spec.Linux != nil {
+			spec.Linux.CgroupsPath = renameCgroupsPath(spec.Linux.CgroupsPath, oldID, id)
+		}
 		if err := setEmptyNsMask(context, options); err != nil {
 			return err
 		}
+		if err := setRestoreRemaps(context, options); err != nil {
+			return err
+		}
+		if err := setRestoreExecStdio(context, options); err != nil {
+			return err
+		}
+		if err := setRestoreExecConsole(context, options); err != nil {
+			return err
+		}
 		status, err := startContainer(context, spec, CT_ACT_RESTORE, options)
 		if err != nil {
 			return err
 		}
+		// The deferred calls are not run by os.Exit.
+		if importDir != "" {
+			_ = os.RemoveAll(importDir)
+		}
 		// exit with the container's exit status so any external supervisor is
 		// notified of the exit with the correct exit status.
 		os.Exit(status)
@@ -145,3 +246,126 @@ func criuOptions(context *cli.Context) *libcontainer.CriuOpts {
 		LsmProfile:              context.String("lsm-profile"),
 	}
 }
+
+// setRestoreRootfs makes the container be restored into the rootfs set with
+// --rootfs, if any, rather than into the rootfs of the bundle.
+func setRestoreRootfs(context *cli.Context, spec *specs.Spec) {
+	rootfs := context.String("rootfs")
+	if rootfs == "" {
+		return
+	}
+	if spec.Root == nil {
+		spec.Root = &specs.Root{}
+	}
+	spec.Root.Path = rootfs
+}
+
+// restoreNsMapping are the namespaces which can be remapped with --ns-path.
+var restoreNsMapping = map[specs.LinuxNamespaceType]configs.NamespaceType{
+	specs.NetworkNamespace: configs.NEWNET,
+	specs.PIDNamespace:     configs.NEWPID,
+	specs.IPCNamespace:     configs.NEWIPC,
+	specs.UTSNamespace:     configs.NEWUTS,
+}
+
+// setRestoreRemaps sets the mount sources and namespace paths to restore the
+// container with, from --mount-source and --ns-path.
+func setRestoreRemaps(context *cli.Context, options *libcontainer.CriuOpts) error {
+	for _, m := range context.StringSlice("mount-source") {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) != 2 || !filepath.IsAbs(kv[0]) || !filepath.IsAbs(kv[1]) {
+			return fmt.Errorf("invalid --mount-source %q: must be <old-path>=<new-path>, with absolute paths", m)
+		}
+		if options.MountSources == nil {
+			options.MountSources = make(map[string]string)
+		}
+		options.MountSources[filepath.Clean(kv[0])] = filepath.Clean(kv[1])
+	}
+	for _, n := range context.StringSlice("ns-path") {
+		kv := strings.SplitN(n, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return fmt.Errorf("invalid --ns-path %q: must be <type>=<path>", n)
+		}
+		t, ok := restoreNsMapping[specs.LinuxNamespaceType(kv[0])]
+		if !ok {
+			return fmt.Errorf("invalid --ns-path %q: namespace %q is not supported", n, kv[0])
+		}
+		if options.NamespacePaths == nil {
+			options.NamespacePaths = make(map[configs.NamespaceType]string)
+		}
+		options.NamespacePaths[t] = kv[1]
+	}
+	return nil
+}
+
+// setRestoreExecStdio sets the files to reconnect the stdio of the exec'd
+// processes to, from --exec-stdio.
+func setRestoreExecStdio(context *cli.Context, options *libcontainer.CriuOpts) error {
+	for _, e := range context.StringSlice("exec-stdio") {
+		kv := strings.SplitN(e, "=", 2)
+		var fds []string
+		if len(kv) == 2 {
+			fds = strings.Split(kv[1], ",")
+		}
+		pid, err := strconv.Atoi(kv[0])
+		if err != nil || pid <= 0 || len(fds) == 0 || len(fds) > 3 {
+			return fmt.Errorf("invalid --exec-stdio %q: must be <pid>=<stdin>,<stdout>,<stderr>", e)
+		}
+		files := make([]*os.File, len(fds))
+		for i, s := range fds {
+			if s == "-" {
+				continue
+			}
+			fd, err := strconv.Atoi(s)
+			if err != nil || fd < 0 {
+				return fmt.Errorf("invalid --exec-stdio %q: invalid fd %q", e, s)
+			}
+			if _, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0); err != nil {
+				return fmt.Errorf("invalid --exec-stdio %q: fd %d: %w", e, fd, err)
+			}
+			files[i] = os.NewFile(uintptr(fd), "exec-stdio:"+s)
+		}
+		if options.ExecStdio == nil {
+			options.ExecStdio = make(map[int][]*os.File)
+		}
+		options.ExecStdio[pid] = files
+	}
+	return nil
+}
+
+// setRestoreExecConsole sets the socket to send the pseudoterminal masters
+// of the exec'd processes to, from --exec-console-socket.
+func setRestoreExecConsole(context *cli.Context, options *libcontainer.CriuOpts) error {
+	sockpath := context.String("exec-console-socket")
+	if sockpath == "" {
+		return nil
+	}
+	fi, err := os.Stat(sockpath)
+	if err != nil {
+		return fmt.Errorf("invalid --exec-console-socket: %w", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("invalid --exec-console-socket %q: not a socket", sockpath)
+	}
+	if options.ExecConsoleSocket, err = filepath.Abs(sockpath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renameCgroupsPath returns the cgroups path of a container checkpointed as
+// oldID for restoring it as newID, which is p with its last element (or the
+// name of the unit, for the "slice:prefix:name" form used with systemd)
+// renamed to newID if it is oldID.
+func renameCgroupsPath(p, oldID, newID string) string {
+	if parts := strings.Split(p, ":"); len(parts) == 3 {
+		if parts[2] == oldID {
+			parts[2] = newID
+		}
+		return strings.Join(parts, ":")
+	}
+	if path.Base(p) == oldID {
+		return path.Join(path.Dir(p), newID)
+	}
+	return p
+}
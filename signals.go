@@ -50,7 +50,7 @@ type signalHandler struct {
 
 // forward handles the main signal event loop forwarding, resizing, or reaping depending
 // on the signal received.
-func (h *signalHandler) forward(process *libcontainer.Process, tty *tty, detach bool) (int, error) {
+func (h *signalHandler) forward(container libcontainer.Container, process *libcontainer.Process, tty *tty, detach bool) (int, error) {
 	// make sure we know the pid of our main process so that we can return
 	// after it dies.
 	if detach && h.notifySocket == nil {
@@ -64,11 +64,18 @@ func (h *signalHandler) forward(process *libcontainer.Process, tty *tty, detach
 
 	if h.notifySocket != nil {
 		if detach {
-			_ = h.notifySocket.run(pid1)
+			if err := h.notifySocket.waitForContainer(container); err != nil {
+				logrus.Warnf("notify socket: %v", err)
+			}
 			return 0, nil
 		}
-		_ = h.notifySocket.run(os.Getpid())
-		go func() { _ = h.notifySocket.run(0) }()
+		// We stay the main process of the service, being the one monitoring
+		// the container.
+		go func() {
+			if _, err := h.notifySocket.relay(container, os.Getpid(), false, false); err != nil {
+				logrus.Warnf("notify socket: %v", err)
+			}
+		}()
 	}
 
 	// Perform the initial tty resize. Always ignore errors resizing because
@@ -100,7 +107,7 @@ func (h *signalHandler) forward(process *libcontainer.Process, tty *tty, detach
 			}
 		default:
 			logrus.Debugf("sending signal to process %s", s)
-			if err := unix.Kill(pid1, s.(unix.Signal)); err != nil {
+			if err := process.Signal(s); err != nil {
 				logrus.Error(err)
 			}
 		}
@@ -63,6 +63,14 @@ generate a proper rootless spec file.
 
 Note that --rootless is not needed when you execute runc as the root in a user namespace
 created by an unprivileged user.
+
+With --userns-auto, the user namespace of the container is mapped to free
+subordinate IDs of the user creating it (from /etc/subuid and /etc/subgid),
+which are not mapped by the other containers. This is set by the
+"` + specconv.UsernsAnnotation + `" annotation, which is either "auto",
+or "auto:size=<n>" to map n IDs (65536 by default). For rootless containers,
+the root of the container is mapped to the user creating it, and mapping the
+other IDs requires newuidmap(1) and newgidmap(1).
 `,
 	Flags: []cli.Flag{
 		cli.StringFlag{
@@ -74,6 +82,10 @@ created by an unprivileged user.
 			Name:  "rootless",
 			Usage: "generate a configuration for a rootless container",
 		},
+		cli.BoolFlag{
+			Name:  "userns-auto",
+			Usage: "map the user namespace to free subordinate IDs of the user creating the container",
+		},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 0, exactArgs); err != nil {
@@ -85,6 +97,9 @@ created by an unprivileged user.
 		if rootless {
 			specconv.ToRootless(spec)
 		}
+		if context.Bool("userns-auto") {
+			specconv.ToUsernsAuto(spec)
+		}
 
 		checkNoFile := func(name string) error {
 			_, err := os.Stat(name)
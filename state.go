@@ -47,6 +47,7 @@ instance of a container.`,
 			Rootfs:         state.BaseState.Config.Rootfs,
 			Created:        state.BaseState.Created,
 			Annotations:    annotations,
+			ExitStatus:     newExitStatus(state.ExitStatus),
 		}
 		data, err := json.MarshalIndent(cs, "", "  ")
 		if err != nil {
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	stdioLogFormatCRI  = "cri"
+	stdioLogFormatJSON = "json"
+
+	// stdioLogMaxLine is the size above which lines are split into several
+	// (partial) records.
+	stdioLogMaxLine = 16 * 1024
+)
+
+// stdioLog is the configuration of the logging of a detached process's
+// stdout and stderr to a file, set by --log-path and friends.
+type stdioLog struct {
+	path     string
+	format   string
+	maxSize  int64
+	maxFiles int
+}
+
+// stdioLogFlags are the flags of the commands starting a detached process
+// whose stdout and stderr can be logged to a file.
+var stdioLogFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "log-path",
+		Usage: "path to a file to log the stdout and stderr of the detached process to (rather than inheriting them), without a terminal",
+	},
+	cli.StringFlag{
+		Name:  "log-path-format",
+		Value: "cri",
+		Usage: "format of the records of the log path: cri or json (one JSON object per line)",
+	},
+	cli.StringFlag{
+		Name:  "log-max-size",
+		Usage: "size above which the log path is rotated (e.g. 10MB), no rotation if unset",
+	},
+	cli.IntFlag{
+		Name:  "log-max-files",
+		Value: 1,
+		Usage: "number of rotated log files to keep, besides the current one",
+	},
+}
+
+func newStdioLog(context *cli.Context) (*stdioLog, error) {
+	path := context.String("log-path")
+	if path == "" {
+		return nil, nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &stdioLog{
+		path:     path,
+		format:   context.String("log-path-format"),
+		maxFiles: context.Int("log-max-files"),
+	}
+	switch l.format {
+	case stdioLogFormatCRI, stdioLogFormatJSON:
+	default:
+		return nil, fmt.Errorf("invalid --log-path-format %q: must be %s or %s", l.format, stdioLogFormatCRI, stdioLogFormatJSON)
+	}
+	if size := context.String("log-max-size"); size != "" {
+		if l.maxSize, err = units.RAMInBytes(size); err != nil {
+			return nil, fmt.Errorf("invalid --log-max-size: %w", err)
+		}
+	}
+	if l.maxSize < 0 || l.maxFiles < 1 {
+		return nil, errors.New("--log-max-size must not be negative, and --log-max-files must be at least 1")
+	}
+	return l, nil
+}
+
+// setupLogPipes sets up pipes for the stdout and stderr of the process, and
+// starts a runc process copying them to the log file for as long as they
+// are open, that is for the lifetime of the process (and its children)
+// rather than ours. Stdin is inherited, as without logging.
+func setupLogPipes(p *libcontainer.Process, rootuid, rootgid int, l *stdioLog, globalArgs []string) (*tty, error) {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	t, i, err := processPipes(p, rootuid, rootgid)
+	if err != nil {
+		return nil, err
+	}
+	// The stdin pipe is unused, and closed along with the others by t (its
+	// read end once the process is started, and its write end by Close).
+	p.Stdin = os.Stdin
+
+	cmd := backgroundCommand(globalArgs, "log-copier",
+		"--path", l.path,
+		"--format", l.format,
+		"--max-size", strconv.FormatInt(l.maxSize, 10),
+		"--max-files", strconv.Itoa(l.maxFiles))
+	cmd.ExtraFiles = []*os.File{i.Stdout.(*os.File), i.Stderr.(*os.File), file}
+	if err := cmd.Start(); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("unable to start copying stdio to %s: %w", l.path, err)
+	}
+	if err := cmd.Process.Release(); err != nil {
+		logrus.Warn(err)
+	}
+	return t, nil
+}
+
+// logCopierCommand is the background process started by setupLogPipes,
+// getting the read ends of the stdout and stderr pipes as fds 3 and 4, and
+// the (open) log file as fd 5.
+var logCopierCommand = cli.Command{
+	Name:   "log-copier",
+	Hidden: true,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "path"},
+		cli.StringFlag{Name: "format"},
+		cli.Int64Flag{Name: "max-size"},
+		cli.IntFlag{Name: "max-files"},
+	},
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 0, exactArgs); err != nil {
+			return err
+		}
+		w := &stdioLogWriter{
+			stdioLog: stdioLog{
+				path:     context.String("path"),
+				format:   context.String("format"),
+				maxSize:  context.Int64("max-size"),
+				maxFiles: context.Int("max-files"),
+			},
+			file: os.NewFile(5, "log"),
+		}
+		if fi, err := w.file.Stat(); err == nil {
+			w.size = fi.Size()
+		}
+		defer w.file.Close()
+
+		var wg sync.WaitGroup
+		for _, s := range []struct {
+			fd     uintptr
+			stream string
+		}{{3, "stdout"}, {4, "stderr"}} {
+			wg.Add(1)
+			go func(r *os.File, stream string) {
+				defer wg.Done()
+				defer r.Close()
+				if err := w.copy(stream, r); err != nil {
+					logrus.Warnf("log copier: %s: %v", stream, err)
+				}
+			}(os.NewFile(s.fd, s.stream), s.stream)
+		}
+		wg.Wait()
+		return nil
+	},
+}
+
+// stdioLogWriter writes the lines read from the process's stdout and stderr
+// as records to the log file, rotating it as configured.
+type stdioLogWriter struct {
+	stdioLog
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// failed is set once writing has failed, not to log every failure.
+	failed bool
+}
+
+// copy writes the lines read from r as records of stream until EOF.
+func (w *stdioLogWriter) copy(stream string, r io.Reader) error {
+	br := bufio.NewReaderSize(r, stdioLogMaxLine)
+	for {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			// A line only continues in the next record if it is too long,
+			// while a trailing unterminated line is complete.
+			partial := errors.Is(err, bufio.ErrBufferFull)
+			w.write(w.record(time.Now().UTC(), stream, line, partial))
+		}
+		switch {
+		case err == nil, errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// record formats line (including its newline, if any) as a record.
+func (w *stdioLogWriter) record(now time.Time, stream string, line []byte, partial bool) []byte {
+	if w.format == stdioLogFormatJSON {
+		rec, _ := json.Marshal(struct {
+			Log    string    `json:"log"`
+			Stream string    `json:"stream"`
+			Time   time.Time `json:"time"`
+		}{string(line), stream, now})
+		return append(rec, '\n')
+	}
+	// See https://github.com/kubernetes/design-proposals-archive/blob/main/node/kubelet-cri-logging.md
+	tag := "F"
+	if partial {
+		tag = "P"
+	}
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+	rec := make([]byte, 0, len(line)+64)
+	rec = now.AppendFormat(rec, time.RFC3339Nano)
+	rec = append(rec, ' ')
+	rec = append(rec, stream...)
+	rec = append(rec, ' ')
+	rec = append(rec, tag...)
+	rec = append(rec, ' ')
+	rec = append(rec, line...)
+	return append(rec, '\n')
+}
+
+func (w *stdioLogWriter) write(rec []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(rec)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			w.fail(err)
+		}
+	}
+	n, err := w.file.Write(rec)
+	w.size += int64(n)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	w.failed = false
+}
+
+func (w *stdioLogWriter) fail(err error) {
+	if !w.failed {
+		logrus.Warnf("log copier: %v", err)
+		w.failed = true
+	}
+}
+
+// rotate renames the log file to <path>.1 (and so on for the previous ones,
+// keeping maxFiles rotated files), and reopens it.
+func (w *stdioLogWriter) rotate() error {
+	for i := w.maxFiles; i > 1; i-- {
+		err := os.Rename(w.path+"."+strconv.Itoa(i-1), w.path+"."+strconv.Itoa(i))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	w.size = 0
+	return nil
+}
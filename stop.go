@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/opencontainers/runc/libcontainer/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+// stopSignalAnnotation is the annotation set from the StopSignal of the OCI
+// image configuration.
+const stopSignalAnnotation = "org.opencontainers.image.stopSignal"
+
+var stopCommand = cli.Command{
+	Name:  "stop",
+	Usage: "stop a container gracefully, killing it after a timeout",
+	ArgsUsage: `<container-id>
+
+Where "<container-id>" is the name for the instance of the container.
+
+EXAMPLE:
+For example, if the container id is "ubuntu01" the following will send the stop
+signal to the init process of the "ubuntu01" container, and kill it unless it has
+exited after 30 seconds:
+
+       # runc stop --timeout 30s ubuntu01`,
+	Description: `The stop command sends the stop signal of the container (the
+"org.opencontainers.image.stopSignal" annotation, or SIGTERM) to its init
+process, and waits for it to exit. If it is still running after the timeout,
+all the processes of the container are killed (using SIGKILL). It then
+reports how the container was stopped, according to the exit status of its
+init process.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "signal, s",
+			Usage: "signal to send instead of the container's stop signal",
+		},
+		cli.DurationFlag{
+			Name:  "timeout, t",
+			Value: 10 * time.Second,
+			Usage: "time to wait for the container to exit before killing it",
+		},
+		cli.BoolFlag{
+			Name:  "all, a",
+			Usage: "send the signal to, and wait for, all processes inside the container",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 1, exactArgs); err != nil {
+			return err
+		}
+		container, err := getContainer(context)
+		if err != nil {
+			return err
+		}
+		sigstr := context.String("signal")
+		if sigstr == "" {
+			config := container.Config()
+			_, annotations := utils.Annotations(config.Labels)
+			sigstr = annotations[stopSignalAnnotation]
+		}
+		if sigstr == "" {
+			sigstr = "SIGTERM"
+		}
+		signal, err := parseSignal(sigstr)
+		if err != nil {
+			return err
+		}
+		timeout := context.Duration("timeout")
+		if timeout < 0 {
+			return errors.New("--timeout must not be negative")
+		}
+		stage, err := stopContainer(container, signal, timeout, context.Bool("all"))
+		if err != nil {
+			return err
+		}
+		fmt.Println(stage)
+		return nil
+	},
+}
+
+// stopContainer sends signal to the init process (or, if all is set, to all
+// the processes) of container and, if it is still running after timeout,
+// kills all its processes. It returns how the container was stopped, as
+// told by the exit status of its init process.
+func stopContainer(container libcontainer.Container, signal unix.Signal, timeout time.Duration, all bool) (string, error) {
+	exited, err := containerExited(container, all)
+	if err != nil {
+		return "", err
+	}
+	if exited {
+		return "already stopped", nil
+	}
+	status, err := container.Status()
+	if err != nil {
+		return "", err
+	}
+	// Start waiting for the exit status before sending any signal, not to
+	// miss it. We are not the parent of the init process, so this only
+	// reads the exit status from the state, once its parent has recorded it.
+	exitStatus := make(chan *libcontainer.ExitStatus, 1)
+	go func() {
+		s, err := container.Wait()
+		if err != nil {
+			logrus.Debugf("unable to get the exit status: %v", err)
+		}
+		exitStatus <- s
+	}()
+
+	if err := container.Signal(signal, all); err != nil && !errors.Is(err, libcontainer.ErrNotRunning) {
+		return "", err
+	}
+	if status == libcontainer.Paused {
+		// The signal is only delivered once the container is resumed (which
+		// it already is if all processes were signalled).
+		if err := container.Resume(); err != nil && !errors.Is(err, libcontainer.ErrNotPaused) {
+			return "", err
+		}
+	}
+	exited, err = waitContainerExited(container, all, timeout)
+	if err != nil {
+		return "", err
+	}
+	if exited {
+		return describeStop(waitExitStatus(exitStatus), signal, false, timeout), nil
+	}
+
+	if err := container.Signal(unix.SIGKILL, true); err != nil && !errors.Is(err, libcontainer.ErrNotRunning) {
+		return "", err
+	}
+	exited, err = waitContainerExited(container, true, 10*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if !exited {
+		return "", errors.New("container still running")
+	}
+	return describeStop(waitExitStatus(exitStatus), signal, true, timeout), nil
+}
+
+// waitExitStatus returns the exit status sent on ch, or nil if it is unknown.
+// As the init process has exited already, it should not take long to come.
+func waitExitStatus(ch <-chan *libcontainer.ExitStatus) *libcontainer.ExitStatus {
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// describeStop tells how a container stopped, given the exit status s of its
+// init process (nil if unknown), the stop signal it was sent and whether it
+// was then killed after timeout.
+func describeStop(s *libcontainer.ExitStatus, signal unix.Signal, killed bool, timeout time.Duration) string {
+	sent := signal
+	if killed {
+		sent = unix.SIGKILL
+	}
+	switch {
+	case s == nil:
+		return "stopped after " + unix.SignalName(sent) + ", with an unknown exit status"
+	case s.Signal == unix.SIGKILL && killed:
+		return fmt.Sprintf("killed by SIGKILL after %v", timeout)
+	case s.Signal == signal:
+		return "stopped by " + unix.SignalName(signal)
+	case s.Signal != 0:
+		return "killed by " + unix.SignalName(s.Signal)
+	}
+	return fmt.Sprintf("exited with code %d after %s", s.Code, unix.SignalName(sent))
+}
+
+// waitContainerExited waits up to timeout for containerExited to be true.
+func waitContainerExited(container libcontainer.Container, all bool, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		exited, err := containerExited(container, all)
+		if err != nil || exited {
+			return exited, err
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// containerExited reports whether the init process (or, if all is set, every
+// process) of container has exited.
+func containerExited(container libcontainer.Container, all bool) (bool, error) {
+	status, err := container.Status()
+	if err != nil {
+		return false, err
+	}
+	if status != libcontainer.Stopped {
+		return false, nil
+	}
+	if !all {
+		return true, nil
+	}
+	pids, err := container.Processes()
+	if err != nil {
+		// The cgroup is gone along with the processes.
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	for _, pid := range pids {
+		stat, err := system.Stat(pid)
+		if err != nil {
+			logrus.Debugf("pid %d: %v", pid, err)
+			continue
+		}
+		if stat.State != system.Zombie && stat.State != system.Dead {
+			return false, nil
+		}
+	}
+	return true, nil
+}
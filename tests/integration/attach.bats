@@ -0,0 +1,81 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+	update_config '.process.terminal = false'
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+@test "runc attach" {
+	update_config '.process.args = ["sh", "-c", "echo hello; echo world >&2; read l; echo got $l"]'
+
+	runc run -d --attachable test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	# Only the owner of the container may attach to it.
+	[ "$(stat -c %a "$ROOT/state/test_busybox/attach.sock")" = "600" ]
+
+	# The output so far is replayed, and stdin is closed along with ours.
+	runc attach --close-stdin test_busybox <<<"foo"
+	[ "$status" -eq 0 ]
+	[[ "${output}" == *"hello"* ]]
+	[[ "${output}" == *"world"* ]]
+	[[ "${output}" == *"got foo"* ]]
+
+	runc wait test_busybox
+	[ "$status" -eq 0 ]
+}
+
+@test "runc attach (multiple clients, no stdin)" {
+	update_config '.process.args = ["sh", "-c", "echo hello; sleep 1; echo bye"]'
+
+	runc create --attachable test_busybox
+	[ "$status" -eq 0 ]
+
+	(__runc attach --no-stdin test_busybox >attach1.out 2>&1) &
+	(__runc attach --no-stdin test_busybox >attach2.out 2>&1) &
+
+	runc start test_busybox
+	[ "$status" -eq 0 ]
+	wait
+	grep -q bye attach1.out
+	grep -q bye attach2.out
+}
+
+@test "runc attach to a container which is not attachable" {
+	update_config '.process.args = ["sleep", "60"]'
+
+	runc run -d test_busybox
+	[ "$status" -eq 0 ]
+
+	runc attach test_busybox
+	[ "$status" -ne 0 ]
+	[[ "${output}" == *"not attachable"* ]]
+}
+
+@test "runc run --attachable without --detach" {
+	runc run --attachable test_busybox
+	[ "$status" -ne 0 ]
+	[[ "${output}" == *"cannot make the container attachable if runc will not detach"* ]]
+}
+
+@test "runc run -d --attachable --log-path" {
+	runc run -d --attachable --log-path log test_busybox
+	[ "$status" -ne 0 ]
+	[[ "${output}" == *"cannot use console socket or log path with an attachable container"* ]]
+}
+
+@test "runc run -d --attachable (attach socket path too long)" {
+	local id
+	id=$(printf 'x%.0s' {1..120})
+
+	runc run -d --attachable "$id"
+	[ "$status" -ne 0 ]
+	[[ "${output}" == *"attach socket path"*"is too long"* ]]
+}
@@ -3,7 +3,8 @@
 load helpers
 
 function setup() {
-	# XXX: currently criu require root containers.
+	# Unprivileged restore needs runc to be run in a user namespace,
+	# which is covered by TestCheckpointRootless in libcontainer/integration.
 	requires criu root
 
 	setup_busybox
@@ -190,6 +191,47 @@ function simple_cr() {
 	check_pipes
 }
 
+@test "checkpoint --iterative and restore" {
+	setup_pipes
+	runc_run_with_pipes test_busybox
+
+	mkdir image-dir
+	mkdir work-dir
+	runc --criu "$CRIU" checkpoint --iterative --max-iterations 3 --work-path ./work-dir --image-path ./image-dir test_busybox
+	grep -B 5 Error ./work-dir/dump.log || true
+	[ "$status" -eq 0 ]
+
+	# One event per pre-dump, then one for the final dump.
+	pre_dumps=$(echo "$output" | grep '^{' | jq -s '[.[] | select(.type == "pre-dump")] | length')
+	[ "$pre_dumps" -ge 1 ] && [ "$pre_dumps" -le 3 ]
+	[ "$(echo "$output" | grep '^{' | jq -s '[.[] | select(.type == "dump")] | length')" -eq 1 ]
+	[ "$(echo "$output" | grep '^{' | jq -s '.[0].data.iteration')" -eq 1 ]
+	[ "$(echo "$output" | grep '^{' | jq -s '.[0].data.pagesWritten')" -gt 0 ]
+
+	[ -e ./image-dir/pre-dump-1/inventory.img ]
+	[ "$(readlink ./image-dir/parent)" = "pre-dump-$pre_dumps" ]
+
+	testcontainer test_busybox checkpointed
+
+	runc_restore_with_pipes ./work-dir test_busybox
+	check_pipes
+}
+
+@test "checkpoint --iterative (bad options)" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --iterative --pre-dump test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"--iterative can't be used with"* ]]
+
+	runc --criu "$CRIU" checkpoint --iterative --max-iterations 0 test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"--max-iterations must be at least 1"* ]]
+
+	testcontainer test_busybox running
+}
+
 @test "checkpoint --lazy-pages and restore" {
 	# check if lazy-pages is supported
 	if ! "${CRIU}" check --feature uffd-noncoop; then
@@ -304,6 +346,62 @@ function simple_cr() {
 	ip netns del "$ns_name"
 }
 
+@test "checkpoint and restore with remapped mounts, rootfs and ID" {
+	mkdir -p vol-old vol-new
+	echo data >vol-old/file
+	update_config '	  .mounts += [{
+					type: "bind",
+					source: "'"$PWD"'/vol-old",
+					destination: "/vol",
+					options: ["rw", "bind"]
+				}]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir --image-path ./image-dir test_busybox
+	grep -B 5 Error ./work-dir/dump.log || true
+	[ "$status" -eq 0 ]
+
+	# Move the rootfs and the volume, as on another host.
+	cp -a vol-old/. vol-new/
+	echo new >vol-new/file
+	mv rootfs rootfs-new
+	mkdir rootfs
+
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir --console-socket "$CONSOLE_SOCKET" \
+		--mount-source "$PWD/vol-old=$PWD/vol-new" --rootfs ./rootfs-new test_busybox_new
+	grep -B 5 Error ./work-dir/restore.log || true
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox_new running
+
+	runc exec test_busybox_new cat /vol/file
+	[ "$status" -eq 0 ]
+	[[ "$output" == "new" ]]
+
+	# The new paths are recorded in the state.
+	state="$ROOT/state/test_busybox_new/state.json"
+	[ "$(jq -r '.config.rootfs' "$state")" = "$PWD/rootfs-new" ]
+	[ "$(jq -r '.config.mounts[] | select(.destination == "/vol") | .source' "$state")" = "$PWD/vol-new" ]
+
+	# The container is restored in the cgroup of its new ID.
+	pid=$(__runc state test_busybox_new | jq '.pid')
+	grep -q test_busybox_new "/proc/$pid/cgroup"
+}
+
+@test "restore --mount-source (no such mount)" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --console-socket "$CONSOLE_SOCKET" --mount-source /nonexistent=/tmp test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"unable to remap the mount source /nonexistent"* ]]
+}
+
 @test "checkpoint and restore with container specific CRIU config" {
 	tmp=$(mktemp /tmp/runc-criu-XXXXXX.conf)
 	# This is the file we write to /etc/criu/default.conf
@@ -394,3 +492,138 @@ function simple_cr() {
 	# busybox should be back up and running
 	testcontainer test_busybox running
 }
+
+@test "checkpoint --export and restore --import" {
+	echo removed >rootfs/removed
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox running
+
+	runc exec test_busybox sh -c 'echo changed > /changed && rm /removed'
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir --export ./checkpoint.tar test_busybox
+	grep -B 5 Error ./work-dir/dump.log || true
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox checkpointed
+
+	tar tf ./checkpoint.tar | grep -qx manifest.json
+	tar tf ./checkpoint.tar | grep -qx checkpoint/inventory.img
+	tar tf ./checkpoint.tar | grep -qx rootfs-diff/changed
+	# Without --image-path, the images are only in the archive.
+	[ ! -e ./checkpoint ]
+
+	# Restore in a new bundle, from the archive only.
+	new_bundle=$(mktemp -d -p .)
+	cp -a rootfs "$new_bundle"
+	rm -f "$new_bundle/rootfs/changed"
+	echo removed >"$new_bundle/rootfs/removed"
+
+	runc --criu "$CRIU" restore -d --bundle "$new_bundle" --work-path "$PWD/work-dir" --console-socket "$CONSOLE_SOCKET" --import ./checkpoint.tar test_busybox
+	grep -B 5 Error ./work-dir/restore.log || true
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox running
+
+	runc exec test_busybox cat /changed
+	[ "$status" -eq 0 ]
+	[[ "$output" == "changed" ]]
+
+	# The files removed from the rootfs are removed as well.
+	[ ! -e "$new_bundle/rootfs/removed" ]
+
+	# The archive is extracted in the root directory, and removed.
+	[ -z "$(find "$ROOT" -maxdepth 1 -name 'checkpoint-import@*')" ]
+}
+
+@test "restore --import (corrupted archive)" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir --export ./checkpoint.tar test_busybox
+	[ "$status" -eq 0 ]
+
+	mkdir ./corrupted
+	tar xf ./checkpoint.tar -C ./corrupted
+	echo garbage >>./corrupted/checkpoint/inventory.img
+	tar cf ./corrupted.tar -C ./corrupted .
+
+	runc --criu "$CRIU" restore -d --console-socket "$CONSOLE_SOCKET" --import ./corrupted.tar test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"checkpoint/inventory.img is missing or corrupted"* ]]
+}
+
+@test "checkpoint and restore with exec'd processes" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox running
+
+	# An exec'd process with a terminal, which is sent to recvtty.
+	runc exec -d -t --console-socket "$CONSOLE_SOCKET" test_busybox sleep 3600
+	[ "$status" -eq 0 ]
+
+	# And one with pipes.
+	setup_pipes
+	__runc exec -d test_busybox sh -c 'read x; echo ponG $x' <&${in_r} >&${out_w} 2>&${err_w}
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir --image-path ./image-dir test_busybox
+	grep -B 5 Error ./work-dir/dump.log || true
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox checkpointed
+
+	# Both are recorded, only the first one with a terminal.
+	[ "$(jq length ./image-dir/exec-descriptors.json)" -eq 2 ]
+	[ "$(jq '[.[] | select(.pts != -1)] | length' ./image-dir/exec-descriptors.json)" -eq 1 ]
+	pid=$(jq '.[] | select(.pts == -1) | .pid' ./image-dir/exec-descriptors.json)
+
+	# The terminal of the first one has to be sent somewhere.
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir \
+		--console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"without an exec console socket"* ]]
+
+	# Reconnect the stdio of the second one to new pipes, while the
+	# terminal of the first one is re-created and sent to recvtty
+	# (which accepts a connection for each terminal).
+	setup_pipes
+	ret=0
+	__runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir \
+		--console-socket "$CONSOLE_SOCKET" --exec-console-socket "$CONSOLE_SOCKET" \
+		--exec-stdio "$pid=$in_r,$out_w,$err_w" test_busybox || ret=$?
+	grep -B 5 Error ./work-dir/restore.log || true
+	[ "$ret" -eq 0 ]
+
+	testcontainer test_busybox running
+
+	runc exec test_busybox pidof sleep
+	[ "$status" -eq 0 ]
+
+	check_pipes
+}
+
+@test "restore --exec-stdio (bad options)" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" checkpoint --work-path ./work-dir --image-path ./image-dir test_busybox
+	[ "$status" -eq 0 ]
+
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir \
+		--console-socket "$CONSOLE_SOCKET" --exec-stdio "1=0,1,2,3" test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"must be <pid>=<stdin>,<stdout>,<stderr>"* ]]
+
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir \
+		--console-socket "$CONSOLE_SOCKET" --exec-stdio "1234=0,1,2" test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"unable to reconnect the stdio of process 1234: no such process in the checkpoint"* ]]
+
+	runc --criu "$CRIU" restore -d --work-path ./work-dir --image-path ./image-dir \
+		--console-socket "$CONSOLE_SOCKET" --exec-console-socket ./config.json test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"invalid --exec-console-socket \"./config.json\": not a socket"* ]]
+}
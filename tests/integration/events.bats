@@ -26,6 +26,49 @@ function teardown() {
 	[[ "${lines[0]}" == *"data"* ]]
 }
 
+@test "events --stats --format openmetrics" {
+	requires root
+	init_cgroup_paths
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc events --stats --format openmetrics test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"# TYPE container_pids_current gauge"* ]]
+	[[ "$output" == *'container_pids_current{container_id="test_busybox",cgroup_path="'* ]]
+	[[ "$output" == *'container_cpu_usage_seconds_total{container_id="test_busybox"'* ]]
+	[ "${lines[-1]}" = "# EOF" ]
+}
+
+@test "events --stats with psi data" {
+	requires root cgroups_v2 psi
+	init_cgroup_paths
+
+	update_config '.linux.resources.cpu |= { "quota": 10000, "period": 100000 }'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	# Stress the CPU a bit, so that the cpu quota results in some stalls.
+	runc exec test_busybox dd if=/dev/zero bs=1 count=128K of=/dev/null
+	[ "$status" -eq 0 ]
+
+	runc events --stats test_busybox
+	[ "$status" -eq 0 ]
+
+	# Check that all the PSI metrics are present.
+	for res in cpu memory blkio; do
+		for psi_type in some full; do
+			for psi_metric in avg10 avg60 avg300 total; do
+				jq -e ".data.$res.psi.$psi_type.$psi_metric" <<<"${lines[0]}"
+			done
+		done
+	done
+	# The cpu quota should have resulted in some stall time.
+	jq -e '.data.cpu.psi.some.total > 0' <<<"${lines[0]}"
+}
+
 function test_events() {
 	# XXX: currently cgroups require root containers.
 	requires root
@@ -98,3 +141,75 @@ function test_events() {
 
 	grep -q '{"type":"oom","id":"test_busybox"}' events.log
 }
+
+@test "events memory.events" {
+	# XXX: currently cgroups require root containers.
+	requires root cgroups_v2 cgroups_swap
+	init_cgroup_paths
+
+	# we need the container to hit OOM, so disable swap
+	update_config '(.. | select(.resources? != null)) .resources.memory |= {"limit": 33554432, "swap": 33554432}'
+
+	# run busybox detached
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	# same as in "events oom", but wait for the memory.events counter events
+	(__runc events test_busybox >events.log) &
+	(
+		retry 10 1 grep -q test_busybox events.log
+		# shellcheck disable=SC2016
+		__runc exec -d test_busybox sh -c 'test=$(dd if=/dev/urandom ibs=5120k)'
+		retry 10 1 grep -q '"type":"memory.oom_kill"' events.log
+		__runc delete -f test_busybox
+	) &
+	wait # wait for the above sub shells to finish
+
+	grep -q '{"type":"memory.max","id":"test_busybox","data":{"count":' events.log
+	grep -q '{"type":"memory.oom_kill","id":"test_busybox","data":{"count":1}}' events.log
+	# OOM kills are also reported as with cgroup v1.
+	grep -q '{"type":"oom","id":"test_busybox"}' events.log
+}
+
+@test "events --listen" {
+	# XXX: currently cgroups require root containers.
+	requires root
+	init_cgroup_paths
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	local sock="$BATS_RUN_TMPDIR/events.sock"
+	(__runc events --listen "$sock" --interval 100ms &)
+	retry 10 0.1 test -S "$sock"
+
+	# Subscribe, get a few events, then unsubscribe.
+	python3 - "$sock" >events.log <<-'EOF_PY'
+		import json, socket, sys
+		s = socket.socket(socket.AF_UNIX)
+		s.connect(sys.argv[1])
+		f = s.makefile("rw")
+		def request(action, id):
+		    f.write(json.dumps({"action": action, "id": id}) + "\n")
+		    f.flush()
+		request("subscribe", "test_busybox")
+		request("subscribe", "no_such_container")
+		stats = 0
+		for line in f:
+		    print(line, end="")
+		    if json.loads(line)["type"] == "stats":
+		        stats += 1
+		        if stats == 2:
+		            request("unsubscribe", "test_busybox")
+		    if json.loads(line)["type"] == "unsubscribe":
+		        break
+	EOF_PY
+
+	pkill -f "events --listen $sock"
+
+	cat events.log
+	grep -q '{"type":"subscribe","id":"test_busybox"}' events.log
+	grep -q '{"type":"error","id":"no_such_container","data":"container does not exist"}' events.log
+	[ "$(grep -c '"type":"stats","id":"test_busybox"' events.log)" -ge 2 ]
+	grep -q '{"type":"unsubscribe","id":"test_busybox"}' events.log
+}
@@ -187,3 +187,50 @@ function check_exec_debug() {
 	[[ "${output}" == *"level=debug"* ]]
 	check_exec_debug "$output"
 }
+
+@test "runc exec --cgroup" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	set_cgroups_path
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc exec --cgroup debug test_busybox cat /proc/self/cgroup
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"/debug"* ]]
+
+	# The container's init stays in the container's cgroup.
+	runc exec test_busybox cat /proc/1/cgroup
+	[ "$status" -eq 0 ]
+	[[ "$output" != *"/debug"* ]]
+
+	runc exec --cgroup ../debug test_busybox true
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"must be a path relative to the container's cgroup"* ]]
+}
+
+@test "runc exec --cgroup --cgroup-resources" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_pids
+	set_cgroups_path
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	echo '{"pids": {"limit": 10}}' >sub-cgroup.json
+	if [ "$CGROUP_UNIFIED" = "yes" ]; then
+		# The container's init is in the container's cgroup, so the
+		# controllers can't be enabled for its sub-cgroups.
+		runc exec --cgroup debug --cgroup-resources sub-cgroup.json test_busybox true
+		[ "$status" -ne 0 ]
+		[[ "$output" == *"--cgroup-resources is not supported with cgroup v2"* ]]
+		return
+	fi
+
+	runc exec --cgroup pids:debug --cgroup-resources sub-cgroup.json test_busybox cat /proc/self/cgroup
+	[ "$status" -eq 0 ]
+	[[ "$output" == *":pids:"*"/debug"* ]]
+	[[ "$output" != *":memory:"*"/debug"* ]]
+	[ "$(get_cgroup_value pids.max)" = "max" ]
+	[ "$(cat "${CGROUP_PIDS_BASE_PATH}${REL_CGROUPS_PATH}/debug/pids.max")" -eq 10 ]
+}
@@ -0,0 +1,45 @@
+#!/usr/bin/env bats
+
+load helpers
+
+@test "runc features" {
+	runc features
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"ociVersionMax"* ]]
+
+	# The output must be valid JSON.
+	echo "$output" | jq -e '.linux.namespaces | index("mount")'
+	echo "$output" | jq -e '.hooks | index("createRuntime")'
+	echo "$output" | jq -e '.mountOptions | index("rbind")'
+	echo "$output" | jq -e '.annotations["org.opencontainers.runc.cgroup.unified"]'
+}
+
+@test "runc features [seccomp]" {
+	runc features
+	[ "$status" -eq 0 ]
+
+	if ! echo "$output" | jq -e '.linux.seccomp'; then
+		skip "runc built without seccomp support"
+	fi
+	echo "$output" | jq -e '.linux.seccomp.actions | index("SCMP_ACT_NOTIFY")'
+	echo "$output" | jq -e '.annotations["io.github.seccomp.libseccomp.version"]'
+	echo "$output" | jq -e '.linux.seccomp.knownFlags | index("SECCOMP_FILTER_FLAG_LOG")'
+}
+
+@test "runc features [host support]" {
+	runc features
+	[ "$status" -eq 0 ]
+
+	init_cgroup_paths
+	if [ "$CGROUP_UNIFIED" = "yes" ]; then
+		echo "$output" | jq -e '.linux.cgroup.v2 == true and .linux.cgroup.v1 == false'
+	else
+		echo "$output" | jq -e '.linux.cgroup.v1 == true and .linux.cgroup.v2 == false'
+	fi
+
+	if [ "$(cat /sys/module/apparmor/parameters/enabled 2>/dev/null)" = "Y" ]; then
+		echo "$output" | jq -e '.linux.apparmor.enabled'
+	else
+		echo "$output" | jq -e '.linux.apparmor.enabled == false'
+	fi
+}
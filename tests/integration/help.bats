@@ -15,6 +15,10 @@ load helpers
 }
 
 @test "runc command -h" {
+	runc attach -h
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ runc\ attach+ ]]
+
 	runc checkpoint -h
 	[ "$status" -eq 0 ]
 	[[ ${lines[1]} =~ runc\ checkpoint+ ]]
@@ -74,10 +78,18 @@ load helpers
 	[ "$status" -eq 0 ]
 	[[ ${lines[1]} =~ runc\ state+ ]]
 
+	runc stop -h
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ runc\ stop+ ]]
+
 	runc update -h
 	[ "$status" -eq 0 ]
 	[[ ${lines[1]} =~ runc\ update+ ]]
 
+	runc wait -h
+	[ "$status" -eq 0 ]
+	[[ ${lines[1]} =~ runc\ wait+ ]]
+
 }
 
 @test "runc foo -h" {
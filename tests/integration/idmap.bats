@@ -0,0 +1,92 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	requires root idmap_mounts
+
+	setup_busybox
+
+	mkdir -p source-{1,2}/
+	touch source-{1,2}/foo.txt
+	chown 1:1 source-2/foo.txt
+
+	# Run the container in a user namespace, with the rootfs owned by the
+	# container's root user.
+	chown -R 100000:100000 rootfs
+	update_config '.linux.namespaces += [{"type": "user"}]
+		| .linux.uidMappings += [{"containerID": 0, "hostID": 100000, "size": 65536}]
+		| .linux.gidMappings += [{"containerID": 0, "hostID": 100000, "size": 65536}]'
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+@test "simple idmap mount" {
+	update_config '.mounts += [
+			{
+				"source": "source-1/",
+				"destination": "/tmp/mount-1",
+				"options": ["bind"],
+				"uidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}],
+				"gidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}]
+			}
+		]
+		| .process.args = ["stat", "-c", "%u:%g", "/tmp/mount-1/foo.txt"]'
+
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "0:0" ]]
+}
+
+@test "idmap mount with different mappings" {
+	update_config '.mounts += [
+			{
+				"source": "source-2/",
+				"destination": "/tmp/mount-2",
+				"options": ["bind"],
+				"uidMappings": [{"containerID": 0, "hostID": 100010, "size": 65526}],
+				"gidMappings": [{"containerID": 0, "hostID": 100020, "size": 65516}]
+			}
+		]
+		| .process.args = ["stat", "-c", "%u:%g", "/tmp/mount-2/foo.txt"]'
+
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "11:21" ]]
+}
+
+@test "idmap mount without user namespace" {
+	update_config '.linux.namespaces -= [{"type": "user"}]
+		| del(.linux.uidMappings, .linux.gidMappings)'
+	update_config '.mounts += [
+			{
+				"source": "source-1/",
+				"destination": "/tmp/mount-1",
+				"options": ["bind"],
+				"uidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}],
+				"gidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}]
+			}
+		]'
+
+	runc run test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"id-mapped mounts require a user namespace"* ]]
+}
+
+@test "idmap mount of a non-bind mount" {
+	update_config '.mounts += [
+			{
+				"source": "tmpfs",
+				"destination": "/tmp/mount-1",
+				"type": "tmpfs",
+				"uidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}],
+				"gidMappings": [{"containerID": 0, "hostID": 100000, "size": 65536}]
+			}
+		]'
+
+	runc run test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"id-mapped mounts are only supported for bind mounts"* ]]
+}
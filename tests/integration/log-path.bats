@@ -0,0 +1,71 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+	update_config '.process.terminal = false'
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+@test "runc run -d --log-path" {
+	update_config '.process.args = ["sh", "-c", "echo hello; echo world >&2"]'
+
+	runc run -d --log-path log test_busybox
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 0 ]
+	# The log copier may outlive the container a little.
+	retry 10 0.5 eval "[ \$(wc -l <log) -eq 2 ]"
+
+	grep -E '^[0-9-]+T[0-9:.]+Z stdout F hello$' log
+	grep -E '^[0-9-]+T[0-9:.]+Z stderr F world$' log
+}
+
+@test "runc run -d --log-path --log-path-format json (rotation)" {
+	update_config '.process.args = ["sh", "-c", "for i in 1 2 3 4 5 6 7 8 9; do echo line $i; done"]'
+
+	runc run -d --log-path log --log-path-format json --log-max-size 200 --log-max-files 1 test_busybox
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 0 ]
+	retry 10 0.5 eval "tail -n 1 log | jq -e '.log == \"line 9\n\"'"
+
+	[ -e log.1 ]
+	[ ! -e log.2 ]
+	jq -e '.stream == "stdout" and (.time | length > 0)' <log.1
+	[ "$(stat -c %s log)" -le 200 ]
+}
+
+@test "runc run -d --log-path (unterminated line)" {
+	update_config '.process.args = ["printf", "no newline"]'
+
+	runc run -d --log-path log test_busybox
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 0 ]
+	retry 10 0.5 grep -qE '^[0-9-]+T[0-9:.]+Z stdout F no newline$' log
+}
+
+@test "runc exec -d --log-path" {
+	update_config '.process.args = ["sleep", "1h"]'
+
+	# Without --log-path, the container would hold the output of runc.
+	runc run -d --log-path run.log test_busybox
+	[ "$status" -eq 0 ]
+
+	runc exec -d --log-path exec.log test_busybox sh -c 'echo from exec'
+	[ "$status" -eq 0 ]
+	retry 10 0.5 grep -q ' stdout F from exec$' exec.log
+
+	# The process has to be detached, and without a terminal.
+	runc exec --log-path exec.log test_busybox true
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"cannot use log path"* ]]
+}
@@ -0,0 +1,88 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+	HOST_NOTIFY_SOCKET="$(pwd)/host-notify.sock"
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+# Act as the host's notify socket, printing the first $1 notifications
+# received, along with the number of fds they came with.
+function recv_notifications() {
+	python3 - "$HOST_NOTIFY_SOCKET" "$1" <<-'EOF_PY'
+		import array, socket, sys
+		s = socket.socket(socket.AF_UNIX, socket.SOCK_DGRAM)
+		s.bind(sys.argv[1])
+		s.settimeout(10)
+		for _ in range(int(sys.argv[2])):
+		    msg, ancdata, _, _ = s.recvmsg(4096, socket.CMSG_SPACE(16))
+		    fds = array.array("i")
+		    for level, type, data in ancdata:
+		        if level == socket.SOL_SOCKET and type == socket.SCM_RIGHTS:
+		            fds.frombytes(data)
+		    print(msg.decode().strip().replace("\n", " "), "fds=%d" % len(fds), flush=True)
+	EOF_PY
+}
+
+# Send the notifications given as arguments to the container's notify socket,
+# once it exists, with a pipe fd for FDSTORE=1.
+function send_notifications() {
+	python3 - "$ROOT/state/test_busybox/notify/notify.sock" "$@" <<-'EOF_PY'
+		import array, os, socket, sys, time
+		for _ in range(100):
+		    if os.path.exists(sys.argv[1]):
+		        break
+		    time.sleep(0.1)
+		s = socket.socket(socket.AF_UNIX, socket.SOCK_DGRAM)
+		r, w = os.pipe()
+		for msg in sys.argv[2:]:
+		    if msg == "wait":
+		        time.sleep(1)
+		        continue
+		    anc = []
+		    if msg.startswith("FDSTORE=1"):
+		        anc = [(socket.SOL_SOCKET, socket.SCM_RIGHTS, array.array("i", [r]))]
+		    s.sendmsg([msg.replace(" ", "\n").encode()], anc, 0, sys.argv[1])
+	EOF_PY
+}
+
+@test "runc run -d relays notifications for the container's lifetime" {
+	recv_notifications 4 >notifications.log &
+	retry 10 0.1 test -S "$HOST_NOTIFY_SOCKET"
+	send_notifications "STATUS=starting" "READY=1" wait "STATUS=running WATCHDOG=1" "FDSTORE=1 FDNAME=test" &
+
+	NOTIFY_SOCKET="$HOST_NOTIFY_SOCKET" runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	pid=$(__runc state test_busybox | jq '.pid')
+	wait
+
+	cat notifications.log
+	[ "$(sed -n 1p notifications.log)" = "STATUS=starting fds=0" ]
+	[ "$(sed -n 2p notifications.log)" = "READY=1 MAINPID=$pid fds=0" ]
+	# These are relayed once "runc run -d" has exited.
+	[ "$(sed -n 3p notifications.log)" = "STATUS=running WATCHDOG=1 fds=0" ]
+	[ "$(sed -n 4p notifications.log)" = "FDSTORE=1 FDNAME=test fds=1" ]
+}
+
+@test "runc run relays notifications" {
+	update_config '.process.args = ["sleep", "3"] | .process.terminal = false'
+
+	recv_notifications 3 >notifications.log &
+	retry 10 0.1 test -S "$HOST_NOTIFY_SOCKET"
+	send_notifications "READY=1" "STATUS=running" "STOPPING=1" &
+
+	NOTIFY_SOCKET="$HOST_NOTIFY_SOCKET" runc run test_busybox
+	[ "$status" -eq 0 ]
+	wait
+
+	cat notifications.log
+	# runc stays the main process, monitoring the container.
+	[[ "$(sed -n 1p notifications.log)" == "READY=1 MAINPID="[0-9]*" fds=0" ]]
+	[ "$(sed -n 2p notifications.log)" = "STATUS=running fds=0" ]
+	[ "$(sed -n 3p notifications.log)" = "STOPPING=1 fds=0" ]
+}
@@ -0,0 +1,60 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	requires pidfd
+	setup_busybox
+	PIDFD_SOCKET="$(pwd)/pidfd.sock"
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+# Receive a pidfd on the socket, and print the pid of the process it refers to.
+function recv_pidfd() {
+	python3 - "$PIDFD_SOCKET" <<-'EOF_PY'
+		import array, socket, sys
+		s = socket.socket(socket.AF_UNIX)
+		s.bind(sys.argv[1])
+		s.listen(1)
+		conn, _ = s.accept()
+		fds = array.array("i")
+		_, ancdata, _, _ = conn.recvmsg(1024, socket.CMSG_SPACE(fds.itemsize))
+		for level, type, data in ancdata:
+		    if level == socket.SOL_SOCKET and type == socket.SCM_RIGHTS:
+		        fds.frombytes(data[:fds.itemsize])
+		with open("/proc/self/fdinfo/%d" % fds[0]) as f:
+		    for line in f:
+		        if line.startswith("Pid:"):
+		            print(line.split()[1])
+	EOF_PY
+}
+
+@test "runc create --pidfd-socket" {
+	recv_pidfd >pidfd.pid &
+	retry 10 0.1 test -S "$PIDFD_SOCKET"
+
+	runc create --console-socket "$CONSOLE_SOCKET" --pidfd-socket "$PIDFD_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	wait
+
+	testcontainer test_busybox created
+	pid=$(__runc state test_busybox | jq '.pid')
+	[ "$(cat pidfd.pid)" = "$pid" ]
+}
+
+@test "runc exec --pidfd-socket" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	recv_pidfd >pidfd.pid &
+	retry 10 0.1 test -S "$PIDFD_SOCKET"
+
+	runc exec -d --pid-file exec.pid --pidfd-socket "$PIDFD_SOCKET" test_busybox sleep 1h
+	[ "$status" -eq 0 ]
+	wait
+
+	[ "$(cat pidfd.pid)" = "$(cat exec.pid)" ]
+}
@@ -60,6 +60,48 @@ function teardown() {
 	[[ "${lines[1]}" =~ [0-9]+ ]]
 }
 
+@test "ps --json" {
+	# ps is not supported, it requires cgroups
+	requires root
+
+	update_config '.process.args = ["sh", "-c", "sleep 100 & exec sleep 200"]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	runc ps --json test_busybox
+	[ "$status" -eq 0 ]
+	[ "$(echo "$output" | jq length)" -eq 2 ]
+	pid=$(__runc state test_busybox | jq .pid)
+	echo "$output" | jq -e '.[] | select(.pid == '"$pid"') | .cmdline == ["sleep", "200"] and .containerUid == 0 and .cgroup == "/"'
+	echo "$output" | jq -e '.[] | select(.ppid == '"$pid"') | .cmdline == ["sleep", "100"] and .state == "sleeping"'
+	echo "$output" | jq -e '.[0].namespaces.pid != '"$(stat -L -c %i /proc/self/ns/pid)"
+
+	# ps options are for ps(1).
+	runc ps --json test_busybox -ef
+	[ "$status" -ne 0 ]
+
+	# --json is not a --format.
+	runc ps --json --format json test_busybox
+	[ "$status" -ne 0 ]
+}
+
+@test "ps --tree" {
+	# ps is not supported, it requires cgroups
+	requires root
+
+	update_config '.process.args = ["sh", "-c", "sleep 100 & exec sleep 200"]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	runc ps --tree test_busybox
+	[ "$status" -eq 0 ]
+	[[ ${lines[0]} =~ PID\ +PPID\ +STAT\ +CMD ]]
+	[[ ${lines[1]} =~ \ sleep\ 200$ ]]
+	[[ ${lines[2]} =~ └─\ sleep\ 100$ ]]
+}
+
 @test "ps after the container stopped" {
 	# ps requires cgroups
 	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
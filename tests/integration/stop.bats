@@ -0,0 +1,76 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+@test "runc stop" {
+	update_config '.process.args = ["sh", "-c", "trap \"exit 4\" TERM; while :; do sleep 0.1; done"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	runc stop test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "exited with code 4 after SIGTERM" ]]
+
+	testcontainer test_busybox stopped
+
+	runc stop test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "already stopped" ]]
+}
+
+@test "runc stop (SIGKILL after timeout)" {
+	# As the init process of the pid namespace, sleep ignores SIGTERM.
+	update_config '.process.args = ["sleep", "1h"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc stop --timeout 1s test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "killed by SIGKILL after 1s" ]]
+
+	runc wait test_busybox
+	[ "$status" -eq 137 ]
+}
+
+@test "runc stop (stop signal annotation)" {
+	update_config '.process.args = ["sh", "-c", "trap \"exit 5\" USR1; while :; do sleep 0.1; done"]
+		| .annotations += {"org.opencontainers.image.stopSignal": "SIGUSR1"}'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc stop test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "exited with code 5 after SIGUSR1" ]]
+
+	runc wait test_busybox
+	[ "$status" -eq 5 ]
+}
+
+@test "runc stop --signal --all (paused)" {
+	update_config '.process.args = ["sh", "-c", "trap \"exit 6\" INT; while :; do sleep 0.1; done"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc pause test_busybox
+	[ "$status" -eq 0 ]
+
+	runc stop --signal INT --all test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == "exited with code 6 after SIGINT" ]]
+
+	runc wait test_busybox
+	[ "$status" -eq 6 ]
+}
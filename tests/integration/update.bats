@@ -456,6 +456,127 @@ EOF
 	check_systemd_value "TasksMax" 10
 }
 
+@test "update cgroup v2 resources via --unified and friends" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_v2 cgroups_memory
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --memory-high 30M --memory-low 10M --cpu-weight 50 --unified pids.max=15 test_update
+	[ "$status" -eq 0 ]
+	check_cgroup_value "memory.high" $((30 * 1024 * 1024))
+	check_systemd_value "MemoryHigh" $((30 * 1024 * 1024))
+	check_cgroup_value "memory.low" $((10 * 1024 * 1024))
+	check_cpu_weight 50
+	check_cgroup_value "pids.max" 15
+	check_systemd_value "TasksMax" 15
+
+	# Unified resources are merged with the previous ones.
+	runc update --unified memory.high=max test_update
+	[ "$status" -eq 0 ]
+	check_cgroup_value "memory.high" max
+	check_cgroup_value "memory.low" $((10 * 1024 * 1024))
+
+	# Invalid values are rejected before anything is changed.
+	runc update --unified memory.high=foo test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"unified resource \"memory.high\" value invalid"* ]]
+	runc update --unified nosuchcontroller.max=1 test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"controller \"nosuchcontroller\" not available"* ]]
+	runc update --cpu-weight 0 test_update
+	[ "$status" -ne 0 ]
+
+	# Unified resources read from --resources replace the previous ones.
+	runc update -r - test_update <<EOF
+{
+  "unified": {
+    "pids.max": "12"
+  }
+}
+EOF
+	[ "$status" -eq 0 ]
+	check_cgroup_value "pids.max" 12
+	state="$ROOT/state/test_update/state.json"
+	[ "$(jq -c '.config.cgroups.unified' "$state")" = '{"pids.max":"12"}' ]
+}
+
+@test "update cgroup v2 resources on cgroup v1" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_v1
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --unified memory.high=max test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"require cgroup v2"* ]]
+
+	runc update --cpu-weight 50 test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"requires cgroup v2"* ]]
+}
+
+@test "update per-device io limits" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires root
+
+	local disk major minor
+	disk=$(ls /sys/block | grep -Ev '^(loop|ram|zram)' | head -n 1)
+	[ -z "$disk" ] && skip "test requires a block device"
+	IFS=: read -r major minor <"/sys/block/$disk/dev"
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --device-read-bps "$major:$minor:10MB" --device-write-iops "$major:$minor:100" test_update
+	[ "$status" -eq 0 ]
+	if [ "$CGROUP_UNIFIED" = "yes" ]; then
+		check_cgroup_value "io.max" "$major:$minor rbps=10485760 wbps=max riops=max wiops=100"
+	else
+		check_cgroup_value "blkio.throttle.read_bps_device" "$major:$minor 10485760"
+		check_cgroup_value "blkio.throttle.write_iops_device" "$major:$minor 100"
+	fi
+
+	# Per-device limits are merged with the previous ones.
+	runc update --device-read-bps "$major:$minor:20MB" test_update
+	[ "$status" -eq 0 ]
+	if [ "$CGROUP_UNIFIED" = "yes" ]; then
+		check_cgroup_value "io.max" "$major:$minor rbps=20971520 wbps=max riops=max wiops=100"
+	else
+		check_cgroup_value "blkio.throttle.read_bps_device" "$major:$minor 20971520"
+		check_cgroup_value "blkio.throttle.write_iops_device" "$major:$minor 100"
+	fi
+}
+
+@test "update hugetlb limits" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_hugetlb
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --hugetlb 3MB:1G test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"invalid hugepage size"* ]]
+
+	local size pagesize
+	size=$(ls /sys/kernel/mm/hugepages/ | head -n 1 | sed -E 's/hugepages-([0-9]+)kB/\1/')
+	if [ "$size" -ge 1048576 ]; then
+		pagesize="$((size / 1048576))GB"
+	else
+		pagesize="$((size / 1024))MB"
+	fi
+	runc update --hugetlb "$pagesize:64M" test_update
+	[ "$status" -eq 0 ]
+	if [ "$CGROUP_UNIFIED" = "yes" ]; then
+		check_cgroup_value "hugetlb.$pagesize.max" $((64 * 1024 * 1024))
+	else
+		check_cgroup_value "hugetlb.$pagesize.limit_in_bytes" $((64 * 1024 * 1024))
+	fi
+}
+
 @test "update cpuset parameters via resources.CPU" {
 	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
 	requires smp cgroups_cpuset
@@ -704,3 +825,40 @@ EOF
 	runc resume test_update
 	[ "$status" -eq 0 ]
 }
+
+@test "update rlimit, oom_score_adj and cpu scheduler" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+
+	update_config '.process.rlimits = [{"type": "RLIMIT_NOFILE", "soft": 1024, "hard": 1024}]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --rlimit nofile=512:1000 --oom-score-adj 300 --cpu-sched-policy SCHED_BATCH test_update
+	[ "$status" -eq 0 ]
+
+	# The running processes are updated.
+	pid=$(__runc state test_update | jq '.pid')
+	grep -E '^Max open files +512 +1000 ' "/proc/$pid/limits"
+	[ "$(cat "/proc/$pid/oom_score_adj")" -eq 300 ]
+	# The scheduling policy is the 41st field (SCHED_BATCH is 3).
+	[ "$(awk '{print $41}' "/proc/$pid/stat")" -eq 3 ]
+
+	# And so are the processes executed later.
+	runc exec test_update sh -c 'ulimit -Sn; ulimit -Hn; cat /proc/self/oom_score_adj; cut -d" " -f41 /proc/self/stat'
+	[ "$status" -eq 0 ]
+	[ "${lines[0]}" = "512" ]
+	[ "${lines[1]}" = "1000" ]
+	[ "${lines[2]}" = "300" ]
+	[ "${lines[3]}" = "3" ]
+
+	runc update --rlimit nofile=1000:512 test_update
+	[ "$status" -eq 1 ]
+	[[ "$output" == *"soft limit is greater than the hard one"* ]]
+
+	runc update --oom-score-adj 1001 test_update
+	[ "$status" -eq 1 ]
+
+	runc update --cpu-sched-policy SCHED_BATCH --cpu-sched-priority 10 test_update
+	[ "$status" -eq 1 ]
+	[[ "$output" == *"can only be set for SCHED_FIFO and SCHED_RR"* ]]
+}
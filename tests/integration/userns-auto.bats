@@ -0,0 +1,119 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+
+	if [ "$ROOTLESS" -eq 0 ]; then
+		# Allocate subordinate IDs to root, and the rootfs to the first
+		# of them, as the container's root. The host's files are not
+		# modified: copies of them are bind mounted over them.
+		for f in subuid subgid; do
+			[ -e /etc/$f ] || echo "created" >"$BATS_RUN_TMPDIR/$f.created"
+			touch /etc/$f
+			cp /etc/$f "$BATS_RUN_TMPDIR/$f"
+			echo "root:300000:200000" >>"$BATS_RUN_TMPDIR/$f"
+			mount --bind "$BATS_RUN_TMPDIR/$f" /etc/$f
+		done
+		chown -R 300000:300000 rootfs
+	fi
+
+	update_config ' .linux.namespaces |= (map(select(.type != "user")) + [{"type": "user"}])
+			| del(.linux.uidMappings, .linux.gidMappings)
+			| .annotations["org.opencontainers.runc.userns"] = "auto:size=1000"'
+}
+
+function teardown() {
+	if [ "$ROOTLESS" -eq 0 ]; then
+		for f in subuid subgid; do
+			umount /etc/$f
+			rm -f "$BATS_RUN_TMPDIR/$f"
+			if [ -e "$BATS_RUN_TMPDIR/$f.created" ]; then
+				rm -f /etc/$f "$BATS_RUN_TMPDIR/$f.created"
+			fi
+		done
+	fi
+	teardown_bundle
+}
+
+@test "runc spec --userns-auto" {
+	rm config.json
+	runc spec --userns-auto
+	[ "$status" -eq 0 ]
+	[ "$(jq -r '.annotations["org.opencontainers.runc.userns"]' config.json)" = "auto" ]
+	[ "$(jq '.linux.namespaces | map(select(.type == "user")) | length' config.json)" -eq 1 ]
+	[ "$(jq '.linux.uidMappings' config.json)" = "null" ]
+}
+
+@test "userns auto [root]" {
+	requires root
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto1
+	[ "$status" -eq 0 ]
+	runc exec test_auto1 cat /proc/self/uid_map /proc/self/gid_map
+	[ "$status" -eq 0 ]
+	[[ "${lines[0]}" =~ ^\ +0\ +300000\ +1000$ ]]
+	[[ "${lines[1]}" =~ ^\ +0\ +300000\ +1000$ ]]
+
+	# A second container gets the next free IDs.
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto2
+	[ "$status" -eq 0 ]
+	runc exec test_auto2 cat /proc/self/uid_map
+	[ "$status" -eq 0 ]
+	[[ "${lines[0]}" =~ ^\ +0\ +301000\ +1000$ ]]
+
+	# Not enough IDs are free for a third one.
+	update_config '.annotations["org.opencontainers.runc.userns"] = "auto:size=199000"'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto3
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"only 198000 of the 199000 IDs requested are free"* ]]
+
+	# The IDs of stopped containers can be reused.
+	runc delete --force test_auto1
+	[ "$status" -eq 0 ]
+	update_config '.annotations["org.opencontainers.runc.userns"] = "auto:size=1000"'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto3
+	[ "$status" -eq 0 ]
+	runc exec test_auto3 cat /proc/self/uid_map
+	[ "$status" -eq 0 ]
+	[[ "${lines[0]}" =~ ^\ +0\ +300000\ +1000$ ]]
+}
+
+@test "userns auto parallel creates [root]" {
+	requires root
+
+	# Containers created at the same time get distinct IDs.
+	for i in $(seq 1 10); do
+		__runc create --console-socket "$CONSOLE_SOCKET" test_auto$i &
+	done
+	wait
+
+	local starts=()
+	for i in $(seq 1 10); do
+		runc exec test_auto$i cat /proc/self/uid_map
+		[ "$status" -eq 0 ]
+		[[ "${lines[0]}" =~ ^\ +0\ +([0-9]+)\ +1000$ ]]
+		starts+=("${BASH_REMATCH[1]}")
+	done
+	[ "$(printf '%s\n' "${starts[@]}" | sort -u | wc -l)" -eq 10 ]
+}
+
+@test "userns auto [rootless]" {
+	requires rootless rootless_idmap
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto1
+	[ "$status" -eq 0 ]
+	runc exec test_auto1 cat /proc/self/uid_map
+	[ "$status" -eq 0 ]
+	# The container's root is the user running runc.
+	[[ "${lines[0]}" =~ ^\ +0\ +$(id -u)\ +1$ ]]
+	[[ "${lines[1]}" =~ ^\ +1\ +$ROOTLESS_UIDMAP_START\ +999$ ]]
+}
+
+@test "userns auto with uidMappings" {
+	update_config '.linux.uidMappings = [{"containerID": 0, "hostID": 300000, "size": 1000}]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_auto1
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"can't be used along with uidMappings or gidMappings"* ]]
+}
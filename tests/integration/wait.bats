@@ -0,0 +1,85 @@
+#!/usr/bin/env bats
+
+load helpers
+
+function setup() {
+	setup_busybox
+}
+
+function teardown() {
+	teardown_bundle
+}
+
+@test "runc wait" {
+	update_config '.process.args = ["sh", "-c", "sleep 1; exit 7"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 7 ]
+
+	testcontainer test_busybox stopped
+	jq -e '.exitStatus.exitCode == 7' <<<"$output"
+	jq -e '.exitStatus | has("signal") | not' <<<"$output"
+
+	# Once stopped, the recorded exit status is returned.
+	runc wait test_busybox
+	[ "$status" -eq 7 ]
+}
+
+@test "runc wait (killed by a signal)" {
+	runc create --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc start test_busybox
+	[ "$status" -eq 0 ]
+
+	runc kill test_busybox KILL
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 137 ]
+
+	runc state test_busybox
+	[ "$status" -eq 0 ]
+	jq -e '.exitStatus.exitCode == 137 and .exitStatus.signal == "SIGKILL"' <<<"$output"
+}
+
+@test "runc state shows the exit status of a detached container" {
+	update_config '.process.args = ["sh", "-c", "exit 3"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	# Nobody waits for the container, its exit status is recorded anyway.
+	retry 10 0.5 eval "__runc state test_busybox | jq -e '.exitStatus.exitCode == 3'"
+
+	runc list --format json
+	[ "$status" -eq 0 ]
+	jq -e '.[0].exitStatus.exitCode == 3' <<<"$output"
+}
+
+@test "runc wait keeps the state written in the meantime" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_pids
+	set_cgroups_path
+	update_config '.process.args = ["sh", "-c", "sleep 2; exit 4"]'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	# The background runc is the parent of the init process.
+	init_pid=$(__runc state test_busybox | jq .pid)
+	ppid=$(awk '{print $4}' "/proc/$init_pid/stat")
+	tr '\0' ' ' <"/proc/$ppid/cmdline" | grep -q "run -d"
+
+	runc update --pids-limit 123 test_busybox
+	[ "$status" -eq 0 ]
+
+	runc wait test_busybox
+	[ "$status" -eq 4 ]
+
+	# The exit status is recorded along with the update.
+	jq -e '.exit_status.code == 4 and .config.cgroups.pids_limit == 123' "$ROOT/state/test_busybox/state.json"
+}
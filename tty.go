@@ -21,6 +21,9 @@ type tty struct {
 	postStart   []io.Closer
 	wg          sync.WaitGroup
 	consoleC    chan error
+	// resizeTo, if set, resizes the terminal of the process held by the IO
+	// broker, in place of console.
+	resizeTo func(console.WinSize) error
 }
 
 func (t *tty) copyIO(w io.Writer, r io.ReadCloser) {
@@ -32,10 +35,27 @@ func (t *tty) copyIO(w io.Writer, r io.ReadCloser) {
 // setup pipes for the process so that advanced features like c/r are able to easily checkpoint
 // and restore the process's IO without depending on a host specific path or device
 func setupProcessPipes(p *libcontainer.Process, rootuid, rootgid int) (*tty, error) {
-	i, err := p.InitializeIO(rootuid, rootgid)
+	t, i, err := processPipes(p, rootuid, rootgid)
 	if err != nil {
 		return nil, err
 	}
+	go func() {
+		_, _ = io.Copy(i.Stdin, os.Stdin)
+		_ = i.Stdin.Close()
+	}()
+	t.wg.Add(2)
+	go t.copyIO(os.Stdout, i.Stdout)
+	go t.copyIO(os.Stderr, i.Stderr)
+	return t, nil
+}
+
+// processPipes sets up pipes for the process's stdio, and returns our ends of
+// them, which are closed along with the tty.
+func processPipes(p *libcontainer.Process, rootuid, rootgid int) (*tty, *libcontainer.IO, error) {
+	i, err := p.InitializeIO(rootuid, rootgid)
+	if err != nil {
+		return nil, nil, err
+	}
 	t := &tty{
 		closers: []io.Closer{
 			i.Stdin,
@@ -53,14 +73,7 @@ func setupProcessPipes(p *libcontainer.Process, rootuid, rootgid int) (*tty, err
 			t.postStart = append(t.postStart, c)
 		}
 	}
-	go func() {
-		_, _ = io.Copy(i.Stdin, os.Stdin)
-		_ = i.Stdin.Close()
-	}()
-	t.wg.Add(2)
-	go t.copyIO(os.Stdout, i.Stdout)
-	go t.copyIO(os.Stderr, i.Stderr)
-	return t, nil
+	return t, i, nil
 }
 
 func inheritStdio(process *libcontainer.Process) error {
@@ -190,6 +203,13 @@ func (t *tty) Close() error {
 }
 
 func (t *tty) resize() error {
+	if t.resizeTo != nil && t.hostConsole != nil {
+		ws, err := t.hostConsole.Size()
+		if err != nil {
+			return err
+		}
+		return t.resizeTo(ws)
+	}
 	if t.console == nil || t.hostConsole == nil {
 		return nil
 	}
@@ -9,6 +9,46 @@ type Event struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
+// EventsRequest is sent by the clients of `runc events --listen`, as a JSON
+// object per line, to subscribe to (or unsubscribe from) a container's events.
+type EventsRequest struct {
+	// Action is either EventsSubscribe or EventsUnsubscribe.
+	Action string `json:"action"`
+	// ID is the container ID.
+	ID string `json:"id"`
+}
+
+const (
+	EventsSubscribe   = "subscribe"
+	EventsUnsubscribe = "unsubscribe"
+)
+
+// MemoryEvent is the data of the "memory.<counter>" events, reporting a change
+// of one of the cgroup v2 memory.events (or memory.events.local) counters.
+type MemoryEvent struct {
+	Count uint64 `json:"count"`
+	Local bool   `json:"local,omitempty"`
+}
+
+// DumpStats is the data of the "pre-dump" and "dump" events of runc checkpoint
+// --iterative, reporting the statistics of each pre-dump, and of the final dump.
+type DumpStats struct {
+	// Iteration is the number of the pre-dump, starting from 1 (or 0 for the
+	// final dump).
+	Iteration          int    `json:"iteration"`
+	PagesScanned       uint64 `json:"pagesScanned"`
+	PagesSkippedParent uint64 `json:"pagesSkippedParent"`
+	PagesWritten       uint64 `json:"pagesWritten"`
+	// The times are in microseconds.
+	FreezingTime uint32 `json:"freezingTime"`
+	FrozenTime   uint32 `json:"frozenTime"`
+	MemdumpTime  uint32 `json:"memdumpTime"`
+	MemwriteTime uint32 `json:"memwriteTime"`
+	// Converged is set for the last pre-dump if the memory changes converged,
+	// rather than the maximum number of pre-dumps being reached.
+	Converged bool `json:"converged,omitempty"`
+}
+
 // stats is the runc specific stats structure for stability when encoding and decoding stats.
 type Stats struct {
 	CPU               Cpu                 `json:"cpu"`
@@ -43,6 +83,19 @@ type Blkio struct {
 	IoMergedRecursive       []BlkioEntry `json:"ioMergedRecursive,omitempty"`
 	IoTimeRecursive         []BlkioEntry `json:"ioTimeRecursive,omitempty"`
 	SectorsRecursive        []BlkioEntry `json:"sectorsRecursive,omitempty"`
+	PSI                     *PSIStats    `json:"psi,omitempty"`
+}
+
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+type PSIStats struct {
+	Some PSIData `json:"some,omitempty"`
+	Full PSIData `json:"full,omitempty"`
 }
 
 type Pids struct {
@@ -69,6 +122,7 @@ type CpuUsage struct {
 type Cpu struct {
 	Usage      CpuUsage   `json:"usage,omitempty"`
 	Throttling Throttling `json:"throttling,omitempty"`
+	PSI        *PSIStats  `json:"psi,omitempty"`
 }
 
 type CPUSet struct {
@@ -99,6 +153,7 @@ type Memory struct {
 	Kernel    MemoryEntry       `json:"kernel,omitempty"`
 	KernelTCP MemoryEntry       `json:"kernelTCP,omitempty"`
 	Raw       map[string]uint64 `json:"raw,omitempty"`
+	PSI       *PSIStats         `json:"psi,omitempty"`
 }
 
 type L3CacheInfo struct {
@@ -0,0 +1,34 @@
+// Package features contains the annotation keys used in the output of
+// `runc features`, in addition to the fields defined by the OCI runtime-spec
+// features document.
+package features
+
+const (
+	// AnnotationRuncVersion is the version of runc, e.g., "1.0.0+dev".
+	// Parsing this annotation value is discouraged.
+	AnnotationRuncVersion = "org.opencontainers.runc.version"
+
+	// AnnotationRuncCommit is the git commit runc was built from.
+	// Parsing this annotation value is discouraged.
+	AnnotationRuncCommit = "org.opencontainers.runc.commit"
+
+	// AnnotationRuncCheckpointEnabled is set to "true" if CRIU-based checkpointing is supported.
+	// Unrelated to whether the host supports CRIU or not.
+	AnnotationRuncCheckpointEnabled = "org.opencontainers.runc.checkpoint.enabled"
+
+	// AnnotationLibseccompVersion is the version of libseccomp, e.g., "2.5.1".
+	// Note that the runtime MAY support seccomp even when this annotation is not present.
+	AnnotationLibseccompVersion = "io.github.seccomp.libseccomp.version"
+
+	// AnnotationCgroupUnified is set to "true" if the host uses cgroup v2
+	// in unified mode, and "false" otherwise.
+	AnnotationCgroupUnified = "org.opencontainers.runc.cgroup.unified"
+
+	// AnnotationIntelRdtCATEnabled is set to "true" if the host supports
+	// Intel RDT Cache Allocation Technology, and "false" otherwise.
+	AnnotationIntelRdtCATEnabled = "org.opencontainers.runc.intelrdt.cat.enabled"
+
+	// AnnotationIntelRdtMBAEnabled is set to "true" if the host supports
+	// Intel RDT Memory Bandwidth Allocation, and "false" otherwise.
+	AnnotationIntelRdtMBAEnabled = "org.opencontainers.runc.intelrdt.mba.enabled"
+)
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/sirupsen/logrus"
@@ -13,8 +14,10 @@ import (
 	"github.com/docker/go-units"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 func i64Ptr(i int64) *int64   { return &i }
@@ -49,12 +52,25 @@ The accepted format is as follow (unchanged values can be omitted):
     "mems": ""
   },
   "blockIO": {
-    "weight": 0
-  }
+    "weight": 0,
+    "weightDevice": [{"major": 8, "minor": 0, "weight": 0}],
+    "throttleReadBpsDevice": [{"major": 8, "minor": 0, "rate": 0}],
+    "throttleWriteBpsDevice": [{"major": 8, "minor": 0, "rate": 0}],
+    "throttleReadIOPSDevice": [{"major": 8, "minor": 0, "rate": 0}],
+    "throttleWriteIOPSDevice": [{"major": 8, "minor": 0, "rate": 0}]
+  },
+  "hugepageLimits": [{"pageSize": "2MB", "limit": 0}],
+  "rdma": {"mlx5_1": {"hcaHandles": 0, "hcaObjects": 0}},
+  "unified": {"memory.high": "max"}
 }
 
+The per-device, hugepage and rdma settings are merged with the current
+ones. The unified settings replace the current ones (use --unified to
+change some of them only).
+
 Note: if data is to be read from a file or the standard input, all
-other options are ignored.
+other options are ignored, except for --rlimit, --oom-score-adj,
+--cpu-sched-policy and --cpu-sched-priority.
 `,
 		},
 
@@ -82,6 +98,10 @@ other options are ignored.
 			Name:  "cpu-rt-runtime",
 			Usage: "CPU realtime hardcap limit (in usecs). Allowed cpu time in a given period",
 		},
+		cli.StringFlag{
+			Name:  "cpu-weight",
+			Usage: "CPU weight (relative weight vs. other containers, from 1 to 10000), for cgroup v2",
+		},
 		cli.StringFlag{
 			Name:  "cpuset-cpus",
 			Usage: "CPU(s) to use",
@@ -112,10 +132,70 @@ other options are ignored.
 			Name:  "memory-swap",
 			Usage: "Total memory usage (memory + swap); set '-1' to enable unlimited swap",
 		},
+		cli.StringFlag{
+			Name:  "memory-high",
+			Usage: "Memory usage throttle limit (in bytes, or 'max'), for cgroup v2",
+		},
+		cli.StringFlag{
+			Name:  "memory-low",
+			Usage: "Best-effort memory protection (in bytes, or 'max'), for cgroup v2",
+		},
+		cli.StringFlag{
+			Name:  "memory-min",
+			Usage: "Hard memory protection (in bytes, or 'max'), for cgroup v2",
+		},
 		cli.IntFlag{
 			Name:  "pids-limit",
 			Usage: "Maximum number of pids allowed in the container",
 		},
+		cli.StringSliceFlag{
+			Name:  "blkio-weight-device",
+			Usage: "Weight for a block device, as <major>:<minor>:<weight> (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-bps",
+			Usage: "Read rate limit for a block device, as <major>:<minor>:<bytes per second> (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-bps",
+			Usage: "Write rate limit for a block device, as <major>:<minor>:<bytes per second> (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-iops",
+			Usage: "Read rate limit for a block device, as <major>:<minor>:<IO per second> (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-iops",
+			Usage: "Write rate limit for a block device, as <major>:<minor>:<IO per second> (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "hugetlb",
+			Usage: "Hugepage limit, as <pagesize>:<limit in bytes>, e.g. 2MB:1G (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "rdma",
+			Usage: "RDMA limits for a device, as <device>:<hca_handles>:<hca_objects>, either limit may be empty (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "unified",
+			Usage: "Set a cgroup v2 file of the container's cgroup, as <file>=<value>, e.g. pids.max=100 (may be repeated)",
+		},
+		cli.StringSliceFlag{
+			Name:  "rlimit",
+			Usage: "Set a resource limit of the container processes, as <type>=<soft>:<hard>, e.g. RLIMIT_NOFILE=1024:4096 (may be repeated)",
+		},
+		cli.IntFlag{
+			Name:  "oom-score-adj",
+			Usage: "Set the OOM score adjustment of the container processes, range is from -1000 to 1000",
+		},
+		cli.StringFlag{
+			Name:  "cpu-sched-policy",
+			Usage: "Set the CPU scheduling policy of the container processes (SCHED_OTHER, SCHED_FIFO, SCHED_RR, SCHED_BATCH or SCHED_IDLE)",
+		},
+		cli.IntFlag{
+			Name:  "cpu-sched-priority",
+			Usage: "Set the CPU scheduling priority of the container processes, for SCHED_FIFO and SCHED_RR",
+		},
 		cli.StringFlag{
 			Name:  "l3-cache-schema",
 			Usage: "The string of Intel RDT/CAT L3 cache schema",
@@ -160,6 +240,7 @@ other options are ignored.
 		}
 
 		config := container.Config()
+		var cpuWeight uint64
 
 		if in := context.String("resources"); in != "" {
 			var (
@@ -249,6 +330,22 @@ other options are ignored.
 			}
 
 			r.Pids.Limit = int64(context.Int("pids-limit"))
+
+			if val := context.String("cpu-weight"); val != "" {
+				cpuWeight, err = strconv.ParseUint(val, 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid value for cpu-weight: %w", err)
+				}
+				if cpuWeight < 1 || cpuWeight > 10000 {
+					return errors.New("invalid value for cpu-weight: must be from 1 to 10000")
+				}
+				if *r.CPU.Shares != 0 {
+					return errors.New("cpu-share and cpu-weight are mutually exclusive")
+				}
+			}
+			if err := parseUpdateFlags(context, &r); err != nil {
+				return err
+			}
 		}
 
 		if *r.Memory.Kernel != 0 || *r.Memory.KernelTCP != 0 {
@@ -288,6 +385,9 @@ other options are ignored.
 		config.Cgroups.Resources.CpuShares = *r.CPU.Shares
 		// CpuWeight is used for cgroupv2 and should be converted
 		config.Cgroups.Resources.CpuWeight = cgroups.ConvertCPUSharesToCgroupV2Value(*r.CPU.Shares)
+		if cpuWeight != 0 {
+			config.Cgroups.Resources.CpuWeight = cpuWeight
+		}
 		config.Cgroups.Resources.CpuRtPeriod = *r.CPU.RealtimePeriod
 		config.Cgroups.Resources.CpuRtRuntime = *r.CPU.RealtimeRuntime
 		config.Cgroups.Resources.CpusetCpus = r.CPU.Cpus
@@ -296,7 +396,31 @@ other options are ignored.
 		config.Cgroups.Resources.MemoryReservation = *r.Memory.Reservation
 		config.Cgroups.Resources.MemorySwap = *r.Memory.Swap
 		config.Cgroups.Resources.PidsLimit = r.Pids.Limit
-		config.Cgroups.Resources.Unified = r.Unified
+		mergeResources(config.Cgroups.Resources, &r)
+		if context.String("resources") != "" {
+			// The unified resources read from --resources replace the
+			// current ones, while the --unified ones are merged with them.
+			config.Cgroups.Resources.Unified = r.Unified
+		} else {
+			for k, v := range r.Unified {
+				if config.Cgroups.Resources.Unified == nil {
+					config.Cgroups.Resources.Unified = make(map[string]string)
+				}
+				config.Cgroups.Resources.Unified[k] = v
+			}
+		}
+
+		if err := updateProcesses(context, &config); err != nil {
+			return err
+		}
+
+		state, err := container.State()
+		if err != nil {
+			return err
+		}
+		if err := validateUpdate(config.Cgroups.Resources, &r, cpuWeight, state.CgroupPaths); err != nil {
+			return err
+		}
 
 		// Update Intel RDT
 		l3CacheSchema := context.String("l3-cache-schema")
@@ -315,10 +439,6 @@ other options are ignored.
 			// In update command, we could re-enable through IntelRdtManager.Apply()
 			// and then update intelrdt constraint.
 			if config.IntelRdt == nil {
-				state, err := container.State()
-				if err != nil {
-					return err
-				}
 				config.IntelRdt = &configs.IntelRdt{}
 				intelRdtManager := intelrdt.NewManager(&config, container.ID(), state.IntelRdtPath)
 				if err := intelRdtManager.Apply(state.InitProcessPid); err != nil {
@@ -339,3 +459,412 @@ other options are ignored.
 		return container.Set(config)
 	},
 }
+
+var schedPolicies = map[string]int{
+	"SCHED_OTHER": system.SCHED_OTHER,
+	"SCHED_FIFO":  system.SCHED_FIFO,
+	"SCHED_RR":    system.SCHED_RR,
+	"SCHED_BATCH": system.SCHED_BATCH,
+	"SCHED_IDLE":  system.SCHED_IDLE,
+}
+
+// updateProcesses sets the rlimits, OOM score adjustment and CPU scheduler of
+// config from the command-line options. These are applied to the processes of
+// the container, and inherited by those executed in it later.
+func updateProcesses(context *cli.Context, config *configs.Config) error {
+	if vals := context.StringSlice("rlimit"); len(vals) > 0 {
+		// Do not modify the rlimits of the current config in place, as
+		// they are compared with the new ones.
+		rlimits := append([]configs.Rlimit(nil), config.Rlimits...)
+		for _, val := range vals {
+			rl, err := parseRlimit(val)
+			if err != nil {
+				return err
+			}
+			found := false
+			for i := range rlimits {
+				if rlimits[i].Type == rl.Type {
+					rlimits[i] = rl
+					found = true
+					break
+				}
+			}
+			if !found {
+				rlimits = append(rlimits, rl)
+			}
+		}
+		config.Rlimits = rlimits
+	}
+
+	if context.IsSet("oom-score-adj") {
+		adj := context.Int("oom-score-adj")
+		if adj < -1000 || adj > 1000 {
+			return errors.New("invalid value for oom-score-adj: must be from -1000 to 1000")
+		}
+		config.OomScoreAdj = &adj
+	}
+
+	if context.IsSet("cpu-sched-policy") || context.IsSet("cpu-sched-priority") {
+		sched := configs.Scheduler{}
+		if config.Scheduler != nil {
+			sched = *config.Scheduler
+		}
+		if context.IsSet("cpu-sched-policy") {
+			name := strings.ToUpper(context.String("cpu-sched-policy"))
+			if !strings.HasPrefix(name, "SCHED_") {
+				name = "SCHED_" + name
+			}
+			policy, ok := schedPolicies[name]
+			if !ok {
+				return fmt.Errorf("invalid cpu-sched-policy %q", context.String("cpu-sched-policy"))
+			}
+			sched.Policy = policy
+			if !context.IsSet("cpu-sched-priority") {
+				sched.Priority = 0
+			}
+		}
+		if context.IsSet("cpu-sched-priority") {
+			sched.Priority = context.Int("cpu-sched-priority")
+		}
+		switch sched.Policy {
+		case system.SCHED_FIFO, system.SCHED_RR:
+			if sched.Priority < 1 || sched.Priority > 99 {
+				return errors.New("invalid value for cpu-sched-priority: must be from 1 to 99 for SCHED_FIFO and SCHED_RR")
+			}
+		default:
+			if sched.Priority != 0 {
+				return errors.New("cpu-sched-priority can only be set for SCHED_FIFO and SCHED_RR")
+			}
+		}
+		config.Scheduler = &sched
+	}
+	return nil
+}
+
+// parseRlimit parses an rlimit given as <type>=<soft>:<hard>, where the type
+// is either like RLIMIT_NOFILE or nofile, and the limits are either numbers,
+// or "unlimited" (or -1).
+func parseRlimit(val string) (configs.Rlimit, error) {
+	i := strings.IndexByte(val, '=')
+	if i <= 0 {
+		return configs.Rlimit{}, fmt.Errorf("invalid rlimit %q: must be <type>=<soft>:<hard>", val)
+	}
+	name := strings.ToUpper(val[:i])
+	if !strings.HasPrefix(name, "RLIMIT_") {
+		name = "RLIMIT_" + name
+	}
+	typ, err := strToRlimit(name)
+	if err != nil {
+		return configs.Rlimit{}, err
+	}
+	limits := strings.Split(val[i+1:], ":")
+	if len(limits) != 2 {
+		return configs.Rlimit{}, fmt.Errorf("invalid rlimit %q: must be <type>=<soft>:<hard>", val)
+	}
+	var soft, hard uint64
+	for _, pair := range []struct {
+		s string
+		v *uint64
+	}{
+		{limits[0], &soft},
+		{limits[1], &hard},
+	} {
+		switch pair.s {
+		case "unlimited", "-1":
+			*pair.v = unix.RLIM_INFINITY
+		default:
+			*pair.v, err = strconv.ParseUint(pair.s, 10, 64)
+			if err != nil {
+				return configs.Rlimit{}, fmt.Errorf("invalid rlimit %q: %w", val, err)
+			}
+		}
+	}
+	if soft > hard {
+		return configs.Rlimit{}, fmt.Errorf("invalid rlimit %q: the soft limit is greater than the hard one", val)
+	}
+	return configs.Rlimit{Type: typ, Soft: soft, Hard: hard}, nil
+}
+
+// parseUpdateFlags sets the per-device, hugepage, rdma and unified resources
+// of r from the command-line options.
+func parseUpdateFlags(context *cli.Context, r *specs.LinuxResources) error {
+	for _, val := range context.StringSlice("blkio-weight-device") {
+		major, minor, weight, err := parseDeviceValue(val, false)
+		if err != nil || weight > 1000 {
+			return fmt.Errorf("invalid value for blkio-weight-device: %q", val)
+		}
+		wd := specs.LinuxWeightDevice{Weight: u16Ptr(uint16(weight))}
+		wd.Major, wd.Minor = major, minor
+		r.BlockIO.WeightDevice = append(r.BlockIO.WeightDevice, wd)
+	}
+	for _, pair := range []struct {
+		opt   string
+		bytes bool
+		dest  *[]specs.LinuxThrottleDevice
+	}{
+		{"device-read-bps", true, &r.BlockIO.ThrottleReadBpsDevice},
+		{"device-write-bps", true, &r.BlockIO.ThrottleWriteBpsDevice},
+		{"device-read-iops", false, &r.BlockIO.ThrottleReadIOPSDevice},
+		{"device-write-iops", false, &r.BlockIO.ThrottleWriteIOPSDevice},
+	} {
+		for _, val := range context.StringSlice(pair.opt) {
+			major, minor, rate, err := parseDeviceValue(val, pair.bytes)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %q", pair.opt, val)
+			}
+			td := specs.LinuxThrottleDevice{Rate: rate}
+			td.Major, td.Minor = major, minor
+			*pair.dest = append(*pair.dest, td)
+		}
+	}
+
+	for _, val := range context.StringSlice("hugetlb") {
+		i := strings.LastIndex(val, ":")
+		if i == -1 {
+			return fmt.Errorf("invalid value for hugetlb: %q", val)
+		}
+		limit, err := units.RAMInBytes(val[i+1:])
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid value for hugetlb: %q", val)
+		}
+		r.HugepageLimits = append(r.HugepageLimits, specs.LinuxHugepageLimit{
+			Pagesize: val[:i],
+			Limit:    uint64(limit),
+		})
+	}
+
+	for _, val := range context.StringSlice("rdma") {
+		parts := strings.Split(val, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return fmt.Errorf("invalid value for rdma: %q", val)
+		}
+		var limits specs.LinuxRdma
+		for i, dest := range []**uint32{&limits.HcaHandles, &limits.HcaObjects} {
+			if parts[i+1] == "" {
+				continue
+			}
+			v, err := strconv.ParseUint(parts[i+1], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid value for rdma: %q", val)
+			}
+			n := uint32(v)
+			*dest = &n
+		}
+		if r.Rdma == nil {
+			r.Rdma = make(map[string]specs.LinuxRdma)
+		}
+		r.Rdma[parts[0]] = limits
+	}
+
+	if r.Unified == nil {
+		r.Unified = make(map[string]string)
+	}
+	for _, val := range context.StringSlice("unified") {
+		kv := strings.SplitN(val, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid value for unified: %q (must be <file>=<value>)", val)
+		}
+		r.Unified[kv[0]] = kv[1]
+	}
+	for _, opt := range []string{"memory-high", "memory-low", "memory-min"} {
+		val := context.String(opt)
+		if val == "" {
+			continue
+		}
+		if val != "max" {
+			v, err := units.RAMInBytes(val)
+			if err != nil || v < 0 {
+				return fmt.Errorf("invalid value for %s: %q", opt, val)
+			}
+			val = strconv.FormatInt(v, 10)
+		}
+		r.Unified[strings.Replace(opt, "-", ".", 1)] = val
+	}
+	if len(r.Unified) == 0 {
+		r.Unified = nil
+	}
+	return nil
+}
+
+// parseDeviceValue parses a <major>:<minor>:<value> option, the value being
+// a size in bytes (with an optional unit) if bytes is set.
+func parseDeviceValue(val string, bytes bool) (major, minor int64, v uint64, err error) {
+	parts := strings.Split(val, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, errors.New("must be <major>:<minor>:<value>")
+	}
+	if major, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if minor, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if bytes {
+		n, err := units.RAMInBytes(parts[2])
+		if err != nil || n < 0 {
+			return 0, 0, 0, fmt.Errorf("invalid size %q", parts[2])
+		}
+		return major, minor, uint64(n), nil
+	}
+	v, err = strconv.ParseUint(parts[2], 10, 64)
+	return major, minor, v, err
+}
+
+// mergeResources merges the per-device, hugepage and rdma resources of r
+// into res, replacing the settings for the same device or page size, and
+// keeping the others.
+func mergeResources(res *configs.Resources, r *specs.LinuxResources) {
+	if r.BlockIO != nil {
+		for _, wd := range r.BlockIO.WeightDevice {
+			var weight, leafWeight uint16
+			if wd.Weight != nil {
+				weight = *wd.Weight
+			}
+			if wd.LeafWeight != nil {
+				leafWeight = *wd.LeafWeight
+			}
+			res.BlkioWeightDevice = mergeWeightDevice(res.BlkioWeightDevice, configs.NewWeightDevice(wd.Major, wd.Minor, weight, leafWeight))
+		}
+		for _, pair := range []struct {
+			devices []specs.LinuxThrottleDevice
+			dest    *[]*configs.ThrottleDevice
+		}{
+			{r.BlockIO.ThrottleReadBpsDevice, &res.BlkioThrottleReadBpsDevice},
+			{r.BlockIO.ThrottleWriteBpsDevice, &res.BlkioThrottleWriteBpsDevice},
+			{r.BlockIO.ThrottleReadIOPSDevice, &res.BlkioThrottleReadIOPSDevice},
+			{r.BlockIO.ThrottleWriteIOPSDevice, &res.BlkioThrottleWriteIOPSDevice},
+		} {
+			for _, td := range pair.devices {
+				*pair.dest = mergeThrottleDevice(*pair.dest, configs.NewThrottleDevice(td.Major, td.Minor, td.Rate))
+			}
+		}
+	}
+
+hugetlb:
+	for _, l := range r.HugepageLimits {
+		for _, cur := range res.HugetlbLimit {
+			if cur.Pagesize == l.Pagesize {
+				cur.Limit = l.Limit
+				continue hugetlb
+			}
+		}
+		res.HugetlbLimit = append(res.HugetlbLimit, &configs.HugepageLimit{Pagesize: l.Pagesize, Limit: l.Limit})
+	}
+
+	for device, limits := range r.Rdma {
+		if res.Rdma == nil {
+			res.Rdma = make(map[string]configs.LinuxRdma)
+		}
+		res.Rdma[device] = configs.LinuxRdma{HcaHandles: limits.HcaHandles, HcaObjects: limits.HcaObjects}
+	}
+}
+
+func mergeWeightDevice(devices []*configs.WeightDevice, wd *configs.WeightDevice) []*configs.WeightDevice {
+	for i, cur := range devices {
+		if cur.Major == wd.Major && cur.Minor == wd.Minor {
+			devices[i] = wd
+			return devices
+		}
+	}
+	return append(devices, wd)
+}
+
+func mergeThrottleDevice(devices []*configs.ThrottleDevice, td *configs.ThrottleDevice) []*configs.ThrottleDevice {
+	for i, cur := range devices {
+		if cur.Major == td.Major && cur.Minor == td.Minor {
+			devices[i] = td
+			return devices
+		}
+	}
+	return append(devices, td)
+}
+
+// validateUpdate checks that the resources being updated (r, and cpuWeight)
+// can be applied by the cgroup manager, that is, on cgroup v2 by fs2 (which
+// the systemd cgroup manager also relies on), to the container's cgroup at
+// paths, res being the new resources of the container.
+func validateUpdate(res *configs.Resources, r *specs.LinuxResources, cpuWeight uint64, paths map[string]string) error {
+	v2 := cgroups.IsCgroup2UnifiedMode()
+	if !v2 {
+		if len(r.Unified) > 0 {
+			return errors.New("unified resources (including memory-high, memory-low and memory-min) require cgroup v2")
+		}
+		if cpuWeight != 0 {
+			return errors.New("cpu-weight requires cgroup v2, use cpu-share instead")
+		}
+	}
+
+	var controllers []string
+	if v2 {
+		data, err := cgroups.ReadFile(paths[""], "cgroup.controllers")
+		if err != nil {
+			return err
+		}
+		controllers = strings.Fields(data)
+	}
+	available := func(controller string) bool {
+		if !v2 {
+			_, ok := paths[controller]
+			return ok
+		}
+		for _, c := range controllers {
+			if c == controller {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(r.HugepageLimits) > 0 {
+		if !available("hugetlb") {
+			return errors.New("can't set hugepage limits: the hugetlb controller is not available")
+		}
+		sizes, err := cgroups.GetHugePageSize()
+		if err != nil {
+			return fmt.Errorf("unable to get the hugepage sizes: %w", err)
+		}
+	limits:
+		for _, l := range r.HugepageLimits {
+			for _, size := range sizes {
+				if l.Pagesize == size {
+					continue limits
+				}
+			}
+			return fmt.Errorf("invalid hugepage size %q: the supported sizes are %s", l.Pagesize, strings.Join(sizes, ", "))
+		}
+	}
+	if len(r.Rdma) > 0 && !available("rdma") {
+		return errors.New("can't set rdma limits: the rdma controller is not available")
+	}
+
+	if !v2 {
+		return nil
+	}
+	if r.BlockIO != nil && len(r.BlockIO.WeightDevice) > 0 {
+		// fs2 sets per-device weights with BFQ only (since Linux 5.4).
+		data, err := cgroups.ReadFile(paths[""], "io.bfq.weight")
+		if err != nil || !strings.Contains(data, "default") {
+			return errors.New("per-device block IO weights require the BFQ IO scheduler on cgroup v2")
+		}
+	}
+	for k, v := range r.Unified {
+		if err := validateUnified(k, v, available); err != nil {
+			return err
+		}
+	}
+	_, err := cgroups.ConvertMemorySwapToCgroupV2Value(res.MemorySwap, res.Memory)
+	return err
+}
+
+// validateUnified checks that the unified resource k can be set to v, given
+// the controllers available to the container.
+func validateUnified(k, v string, available func(controller string) bool) error {
+	c, err := cgroups.ParseUnifiedKey(k)
+	if err != nil {
+		return err
+	}
+	if c != "cgroup" && !available(c) {
+		return fmt.Errorf("unified resource %q can't be set: controller %q not available", k, c)
+	}
+	return cgroups.ValidateUnified(k, v)
+}
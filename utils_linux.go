@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
@@ -144,7 +145,12 @@ func destroy(container libcontainer.Container) {
 }
 
 // setupIO modifies the given process config according to the options.
-func setupIO(process *libcontainer.Process, rootuid, rootgid int, createTTY, detach bool, sockpath string) (*tty, error) {
+func setupIO(process *libcontainer.Process, rootuid, rootgid int, createTTY, detach bool, sockpath string, log *stdioLog, attachPath string, globalArgs []string) (*tty, error) {
+	// when runc will detach and the container is attachable, the stdio (or
+	// the console master) is held by the IO broker instead.
+	if detach && attachPath != "" {
+		return startIOBroker(process, rootuid, rootgid, createTTY, attachPath, globalArgs)
+	}
 	if createTTY {
 		process.Stdin = nil
 		process.Stdout = nil
@@ -185,7 +191,10 @@ func setupIO(process *libcontainer.Process, rootuid, rootgid int, createTTY, det
 		return t, nil
 	}
 	// when runc will detach the caller provides the stdio to runc via runc's 0,1,2
-	// and the container's process inherits runc's stdio.
+	// and the container's process inherits runc's stdio, unless it is logged.
+	if detach && log != nil {
+		return setupLogPipes(process, rootuid, rootgid, log, globalArgs)
+	}
 	if detach {
 		if err := inheritStdio(process); err != nil {
 			return nil, err
@@ -195,6 +204,31 @@ func setupIO(process *libcontainer.Process, rootuid, rootgid int, createTTY, det
 	return setupProcessPipes(process, rootuid, rootgid)
 }
 
+// sendPidfd sends a pidfd referring to the process to the unix socket at
+// sockpath, the same way as the console master is sent to the console socket.
+func sendPidfd(sockpath string, process *libcontainer.Process) error {
+	pidfd, err := process.Pidfd()
+	if err != nil {
+		return fmt.Errorf("unable to get a pidfd for the process: %w", err)
+	}
+	defer pidfd.Close()
+	conn, err := net.Dial("unix", sockpath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("casting to UnixConn failed")
+	}
+	socket, err := uc.File()
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	return utils.SendFd(socket, pidfd.Name(), pidfd.Fd())
+}
+
 // createPidFile creates a file with the processes pid inside it atomically
 // it creates a temp file with the paths filename + '.' infront of it
 // then renames the file
@@ -219,12 +253,20 @@ func createPidFile(path string, process *libcontainer.Process) error {
 	return os.Rename(tmpName, path)
 }
 
-func createContainer(context *cli.Context, id string, spec *specs.Spec) (libcontainer.Container, error) {
+// createContainer creates the container id from spec. If its user namespace
+// is mapped in the "auto" mode, the lock on the ID mappings of the containers
+// is returned as well, to be released once the state of the new container is
+// written.
+func createContainer(context *cli.Context, id string, spec *specs.Spec) (_ libcontainer.Container, idMappingsLock *os.File, retErr error) {
 	rootlessCg, err := shouldUseRootlessCgroupManager(context)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	factory, err := loadFactory(context)
+	if err != nil {
+		return nil, nil, err
 	}
-	config, err := specconv.CreateLibcontainerConfig(&specconv.CreateOpts{
+	opts := &specconv.CreateOpts{
 		CgroupName:       id,
 		UseSystemdCgroup: context.GlobalBool("systemd-cgroup"),
 		NoPivotRoot:      context.Bool("no-pivot"),
@@ -232,16 +274,100 @@ func createContainer(context *cli.Context, id string, spec *specs.Spec) (libcont
 		Spec:             spec,
 		RootlessEUID:     os.Geteuid() != 0,
 		RootlessCgroups:  rootlessCg,
-	})
+	}
+	if _, ok := spec.Annotations[specconv.UsernsAnnotation]; ok {
+		if opts.RootlessEUID {
+			for _, name := range []string{"newuidmap", "newgidmap"} {
+				if _, err := exec.LookPath(name); err != nil {
+					return nil, nil, fmt.Errorf("%s is required to map the user namespace of a rootless container to subordinate IDs: %w", name, err)
+				}
+			}
+		}
+		idMappingsLock, err = lockIDMappings(context)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if retErr != nil {
+				idMappingsLock.Close()
+			}
+		}()
+		opts.UsedUidMappings, opts.UsedGidMappings, err = usedIDMappings(context, factory)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	config, err := specconv.CreateLibcontainerConfig(opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	container, err := factory.Create(id, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return container, idMappingsLock, nil
+}
 
-	factory, err := loadFactory(context)
+// idMappingsLockName is the name of the file, under the root directory, which
+// is locked while the ID mappings of a container in the "auto" mode are chosen
+// and until its state is written, for runc instances creating containers at
+// the same time not to choose the same ones.
+const idMappingsLockName = ".idmappings.lock"
+
+// lockIDMappings takes the lock on the user namespace ID mappings of the
+// containers, waiting for it if needed. It is released by closing the
+// returned file.
+func lockIDMappings(context *cli.Context) (*os.File, error) {
+	root, err := filepath.Abs(context.GlobalString("root"))
 	if err != nil {
 		return nil, err
 	}
-	return factory.Create(id, config)
+	path := filepath.Join(root, idMappingsLockName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: path, Err: err}
+	}
+	return f, nil
+}
+
+// usedIDMappings returns the user namespace ID mappings of the containers which
+// are not stopped, for those of a new container not to overlap them. It is to
+// be called with the lock taken by lockIDMappings held, for the containers
+// being created at the same time to be taken into account.
+func usedIDMappings(context *cli.Context, factory libcontainer.Factory) (uids, gids []configs.IDMap, _ error) {
+	root, err := filepath.Abs(context.GlobalString("root"))
+	if err != nil {
+		return nil, nil, err
+	}
+	list, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	for _, item := range list {
+		if !item.IsDir() {
+			continue
+		}
+		container, err := factory.Load(item.Name())
+		if err != nil {
+			// Being created, or destroyed.
+			continue
+		}
+		status, err := container.Status()
+		if err != nil || status == libcontainer.Stopped {
+			continue
+		}
+		config := container.Config()
+		uids = append(uids, config.UidMappings...)
+		gids = append(gids, config.GidMappings...)
+	}
+	return uids, gids, nil
 }
 
 type runner struct {
@@ -253,11 +379,21 @@ type runner struct {
 	preserveFDs     int
 	pidFile         string
 	consoleSocket   string
+	pidfdSocket     string
 	container       libcontainer.Container
-	action          CtAct
-	notifySocket    *notifySocket
-	criuOpts        *libcontainer.CriuOpts
-	logLevel        string
+	// idMappingsLock, if not nil, is released once the container is
+	// started (see createContainer).
+	idMappingsLock *os.File
+	action         CtAct
+	notifySocket   *notifySocket
+	globalArgs     []string
+	stdioLog       *stdioLog
+	attachPath     string
+	criuOpts       *libcontainer.CriuOpts
+	logLevel       string
+
+	subCgroupPaths     map[string]string
+	subCgroupResources *configs.Resources
 }
 
 func (r *runner) run(config *specs.Process) (int, error) {
@@ -274,6 +410,8 @@ func (r *runner) run(config *specs.Process) (int, error) {
 	if err != nil {
 		return -1, err
 	}
+	process.SubCgroupPaths = r.subCgroupPaths
+	process.SubCgroupResources = r.subCgroupResources
 	if len(r.listenFDs) > 0 {
 		process.Env = append(process.Env, "LISTEN_FDS="+strconv.Itoa(len(r.listenFDs)), "LISTEN_PID=1")
 		process.ExtraFiles = append(process.ExtraFiles, r.listenFDs...)
@@ -299,7 +437,7 @@ func (r *runner) run(config *specs.Process) (int, error) {
 	// with detaching containers, and then we get a tty after the container has
 	// started.
 	handler := newSignalHandler(r.enableSubreaper, r.notifySocket)
-	tty, err := setupIO(process, rootuid, rootgid, config.Terminal, detach, r.consoleSocket)
+	tty, err := setupIO(process, rootuid, rootgid, config.Terminal, detach, r.consoleSocket, r.stdioLog, r.attachPath, r.globalArgs)
 	if err != nil {
 		return -1, err
 	}
@@ -315,6 +453,10 @@ func (r *runner) run(config *specs.Process) (int, error) {
 	default:
 		panic("Unknown action")
 	}
+	if r.idMappingsLock != nil {
+		// The state of the container, with its ID mappings, is written.
+		r.idMappingsLock.Close()
+	}
 	if err != nil {
 		return -1, err
 	}
@@ -332,11 +474,20 @@ func (r *runner) run(config *specs.Process) (int, error) {
 			return -1, err
 		}
 	}
-	status, err := handler.forward(process, tty, detach)
+	if r.pidfdSocket != "" {
+		if err = sendPidfd(r.pidfdSocket, process); err != nil {
+			r.terminate(process)
+			return -1, err
+		}
+	}
+	status, err := handler.forward(r.container, process, tty, detach)
 	if err != nil {
 		r.terminate(process)
 	}
 	if detach {
+		if exitMonitor != nil && err == nil {
+			monitorExit(r.container)
+		}
 		return 0, nil
 	}
 	if err == nil {
@@ -345,6 +496,31 @@ func (r *runner) run(config *specs.Process) (int, error) {
 	return status, err
 }
 
+// globalArgs returns the global options to run runc in the background with,
+// for it to find and handle the container the way we do.
+func globalArgs(context *cli.Context) []string {
+	args := []string{"--root", context.GlobalString("root"), "--rootless", context.GlobalString("rootless")}
+	if context.GlobalBool("systemd-cgroup") {
+		args = append(args, "--systemd-cgroup")
+	}
+	if context.GlobalBool("debug") {
+		args = append(args, "--debug")
+	}
+	if log := context.GlobalString("log"); log != "" {
+		args = append(args, "--log", log, "--log-format", context.GlobalString("log-format"))
+	}
+	return args
+}
+
+// backgroundCommand returns a command running runc with globalArgs and args
+// in a new session, so that it can outlive us.
+func backgroundCommand(globalArgs []string, args ...string) *exec.Cmd {
+	cmd := exec.Command("/proc/self/exe", append(append([]string{}, globalArgs...), args...)...)
+	cmd.Args[0] = os.Args[0]
+	cmd.SysProcAttr = &unix.SysProcAttr{Setsid: true}
+	return cmd
+}
+
 func (r *runner) destroy() {
 	if r.shouldDestroy {
 		destroy(r.container)
@@ -359,12 +535,24 @@ func (r *runner) terminate(p *libcontainer.Process) {
 func (r *runner) checkTerminal(config *specs.Process) error {
 	detach := r.detach || (r.action == CT_ACT_CREATE)
 	// Check command-line for sanity.
+	if r.attachPath != "" {
+		if !detach {
+			return errors.New("cannot make the container attachable if runc will not detach")
+		}
+		if r.consoleSocket != "" || r.stdioLog != nil {
+			return errors.New("cannot use console socket or log path with an attachable container")
+		}
+		return nil
+	}
 	if detach && config.Terminal && r.consoleSocket == "" {
 		return errors.New("cannot allocate tty if runc will detach without setting console socket")
 	}
 	if (!detach || !config.Terminal) && r.consoleSocket != "" {
 		return errors.New("cannot use console socket if runc will not detach or allocate tty")
 	}
+	if (!detach || config.Terminal) && r.stdioLog != nil {
+		return errors.New("cannot use log path if runc will not detach or will allocate tty")
+	}
 	return nil
 }
 
@@ -401,6 +589,19 @@ func startContainer(context *cli.Context, spec *specs.Spec, action CtAct, criuOp
 		return -1, errEmptyID
 	}
 
+	var attachPath string
+	if context.Bool("attachable") {
+		root, err := filepath.Abs(context.GlobalString("root"))
+		if err != nil {
+			return -1, err
+		}
+		attachPath = filepath.Join(root, id, attachSocketName)
+		// The path has to fit in sun_path, including the terminating NUL.
+		if max := len(unix.RawSockaddrUnix{}.Path) - 1; len(attachPath) > max {
+			return -1, fmt.Errorf("attach socket path %s is too long (%d bytes, the maximum being %d), use a shorter --root or container id", attachPath, len(attachPath), max)
+		}
+	}
+
 	notifySocket := newNotifySocket(context, os.Getenv("NOTIFY_SOCKET"), id)
 	if notifySocket != nil {
 		if err := notifySocket.setupSpec(context, spec); err != nil {
@@ -408,10 +609,14 @@ func startContainer(context *cli.Context, spec *specs.Spec, action CtAct, criuOp
 		}
 	}
 
-	container, err := createContainer(context, id, spec)
+	container, idMappingsLock, err := createContainer(context, id, spec)
 	if err != nil {
 		return -1, err
 	}
+	if idMappingsLock != nil {
+		// In case of an error before the container is started.
+		defer idMappingsLock.Close()
+	}
 
 	if notifySocket != nil {
 		if err := notifySocket.setupSocketDirectory(); err != nil {
@@ -435,13 +640,25 @@ func startContainer(context *cli.Context, spec *specs.Spec, action CtAct, criuOp
 		logLevel = "debug"
 	}
 
+	logConfig, err := newStdioLog(context)
+	if err != nil {
+		return -1, err
+	}
+
 	r := &runner{
-		enableSubreaper: !context.Bool("no-subreaper"),
+		// The exit monitor (see runDetached) is only to reap the init
+		// process.
+		enableSubreaper: !context.Bool("no-subreaper") && exitMonitor == nil,
 		shouldDestroy:   !context.Bool("keep"),
 		container:       container,
+		idMappingsLock:  idMappingsLock,
 		listenFDs:       listenFDs,
 		notifySocket:    notifySocket,
+		globalArgs:      globalArgs(context),
+		stdioLog:        logConfig,
+		attachPath:      attachPath,
 		consoleSocket:   context.String("console-socket"),
+		pidfdSocket:     context.String("pidfd-socket"),
 		detach:          context.Bool("detach"),
 		pidFile:         context.String("pid-file"),
 		preserveFDs:     context.Int("preserve-fds"),
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
+)
+
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "wait for a container to exit",
+	ArgsUsage: `<container-id>
+
+Where "<container-id>" is the name for the instance of the container.`,
+	Description: `The wait command blocks until the init process of the container exits, and
+exits with its exit status (128 plus the signal number if it was killed by a
+signal). If the container has already stopped, it exits with the exit status
+recorded in the container's state.
+
+The exit status of a container started by "runc create" or "runc run --detach"
+is recorded by the background runc process which is the parent of its init
+process.`,
+	Action: func(context *cli.Context) error {
+		if err := checkArgs(context, 1, exactArgs); err != nil {
+			return err
+		}
+		container, err := getContainer(context)
+		if err != nil {
+			return err
+		}
+		status, err := container.Wait()
+		if err != nil {
+			return err
+		}
+		os.Exit(status.ExitCode())
+		return nil
+	},
+}
+
+// exitMonitorEnv is set, in the environment of the runc process started by
+// runDetached, to the number of the fd to write to once the container has
+// been started.
+const exitMonitorEnv = "_RUNC_EXIT_MONITOR"
+
+// exitMonitor is, in the runc process started by runDetached, the pipe to
+// write to once the container has been started (see monitorExit).
+var exitMonitor *os.File
+
+// runDetached runs the command creating a container which runc detaches from
+// (runc create, and runc run and runc restore with --detach) in a background
+// runc process, inheriting our stdio and other file descriptors. Being the
+// parent of the container's init process, it then goes on to reap it and to
+// record its exit status (see monitorExit). Unless we are that process, it
+// exits once the container has been started (or with the exit code of the
+// background process, which reports its errors itself), and only returns if
+// the process can't be started.
+func runDetached() error {
+	if fd := os.Getenv(exitMonitorEnv); fd != "" {
+		if err := os.Unsetenv(exitMonitorEnv); err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", exitMonitorEnv, err)
+		}
+		unix.CloseOnExec(n)
+		exitMonitor = os.NewFile(uintptr(n), "exit-monitor")
+		// The socket activation file descriptors were for the process
+		// which started us.
+		if os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getppid()) {
+			if err := os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fds, err := inheritedFds()
+	if err != nil {
+		return err
+	}
+	var files []*os.File
+	for _, fd := range fds {
+		if fd < 3 {
+			continue
+		}
+		for len(files) < fd-3 {
+			files = append(files, nil)
+		}
+		files = append(files, os.NewFile(uintptr(fd), "inherited:"+strconv.Itoa(fd)))
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := backgroundCommand(nil, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(), exitMonitorEnv+"="+strconv.Itoa(3+len(files)))
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("unable to start runc in the background: %w", err)
+	}
+	// The pipe is written to once the container has been started, and
+	// closed without anything written on failure.
+	if n, _ := r.Read(make([]byte, 1)); n == 1 {
+		_ = cmd.Process.Release()
+		os.Exit(0)
+	}
+	_ = cmd.Wait()
+	if code := cmd.ProcessState.ExitCode(); code > 0 {
+		os.Exit(code)
+	}
+	os.Exit(1)
+	return nil
+}
+
+// monitorExit is run by the runc process started by runDetached once the
+// container has been started. It releases the stdio and other file
+// descriptors we inherited, lets the process which started us exit, and waits
+// for the init process of the container to exit, recording its exit status.
+func monitorExit(container libcontainer.Container) {
+	if devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0); err == nil {
+		// The files using them may still be referred to, so the file
+		// descriptors are replaced rather than closed.
+		fds, _ := inheritedFds()
+		for _, fd := range fds {
+			_ = unix.Dup3(int(devNull.Fd()), fd, 0)
+		}
+		devNull.Close()
+	}
+	_, _ = exitMonitor.Write([]byte{0})
+	exitMonitor.Close()
+	// We are no longer forwarding any signal.
+	signal.Reset()
+
+	if _, err := container.Wait(); err != nil && !errors.Is(err, libcontainer.ErrExitStatusUnknown) {
+		logrus.Warnf("container %s: %v", container.ID(), err)
+	}
+}
+
+// inheritedFds returns the file descriptors we inherited (or would pass on to
+// a program we execute): the stdio, and the ones not marked close-on-exec.
+func inheritedFds() ([]int, error) {
+	d, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	var fds []int
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		if fd >= 3 {
+			flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0)
+			if err != nil || flags&unix.FD_CLOEXEC != 0 {
+				continue
+			}
+		}
+		fds = append(fds, fd)
+	}
+	sort.Ints(fds)
+	return fds, nil
+}